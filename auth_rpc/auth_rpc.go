@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
 
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/viper"
@@ -19,6 +20,12 @@ type AuthenticateResponse struct {
 	Data    map[string]interface{} `json:"data,omitempty"`
 }
 
+// AuthenticateParams describes params of Auth.Authenticate, token is given by name or as the first
+// positional param
+type AuthenticateParams struct {
+	Token string `json:"token" validate:"required"`
+}
+
 type AuthRPC struct {
 	params Params
 	logger *zap.Logger
@@ -128,7 +135,7 @@ func (arpc *AuthRPC) onStart(ctx context.Context) error {
 		return errors.New("Not found endpoint")
 	}
 
-	ep.GetAdapter().Register("Auth.Authenticate", arpc.authenticate)
+	arpc.register(ep.GetAdapter())
 
 	return nil
 }
@@ -140,15 +147,23 @@ func (arpc *AuthRPC) onStop(ctx context.Context) error {
 	return nil
 }
 
-func (arpc *AuthRPC) authenticate(c *websocket_server.Context) (interface{}, error) {
+func (arpc *AuthRPC) register(a websocket_server.Adapter) {
+	a.Register("Auth.Authenticate", arpc.authenticate, websocket_server.WithMethodTypes(
+		reflect.TypeOf(&AuthenticateParams{}),
+		reflect.TypeOf(&AuthenticateResponse{}),
+	))
+}
 
-	parameters := c.GetRequest().Params.([]interface{})
+// authenticate is not bound by RegisterTyped, so extra positional params are ignored and error codes
+// stay the same as they were for clients
+func (arpc *AuthRPC) authenticate(c *websocket_server.Context) (interface{}, error) {
 
-	if len(parameters) != 1 {
+	param, ok := c.LookupParam(0, "token")
+	if !ok {
 		return nil, websocket_server.NewError(websocket_server.ErrorCode_InvalidParams_Insufficient_Arguments, nil)
 	}
 
-	token, ok := parameters[0].(string)
+	token, ok := param.(string)
 	if !ok {
 		return nil, websocket_server.NewError(websocket_server.ErrorCode_InvalidParams_Invalid_Arguments, nil)
 	}
@@ -0,0 +1,96 @@
+package auth_rpc_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/weedbox/websocket-modules/auth_rpc"
+	"github.com/weedbox/websocket-modules/jsonrpc"
+	"github.com/weedbox/websocket-modules/websocket_server"
+	"github.com/weedbox/websocket-modules/websocket_servertest"
+)
+
+func newAdapter(t *testing.T) (*websocket_server.RPCAdapter, string) {
+
+	ja := auth_rpc.NewJWTAuthenticator("secret")
+
+	token, err := ja.GenerateToken(&auth_rpc.AuthenticationInfo{
+		Data: map[string]interface{}{"id": "u1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ra := websocket_server.NewRPCAdapter(websocket_server.WithRPCBackend(&jsonrpc.JSONRPC{}))
+	auth_rpc.RegisterAuthenticate(ra, ja)
+
+	return ra, token
+}
+
+func TestAuthenticate(t *testing.T) {
+
+	ra, token := newAdapter(t)
+
+	// Token is accepted by name or by position, extra positional params are ignored
+	for _, params := range []interface{}{map[string]interface{}{"token": token}, []interface{}{token}, []interface{}{token, "extra"}} {
+
+		c := websocket_servertest.NewClient(ra)
+		defer c.Close()
+
+		var res auth_rpc.AuthenticateResponse
+		if err := c.InvokeInto(context.Background(), "Auth.Authenticate", params, &res); err != nil {
+			t.Fatal(err)
+		}
+
+		if !res.Success || res.Data["id"] != "u1" {
+			t.Fatalf("unexpected response %+v", res)
+		}
+
+		if c.GetMeta().GetString("id") != "u1" {
+			t.Fatalf("metadata was not set %v", c.GetMeta().Get("id"))
+		}
+	}
+}
+
+func TestAuthenticateFailed(t *testing.T) {
+
+	ra, _ := newAdapter(t)
+
+	c := websocket_servertest.NewClient(ra)
+	defer c.Close()
+
+	var res auth_rpc.AuthenticateResponse
+	if err := c.InvokeInto(context.Background(), "Auth.Authenticate", []interface{}{"bad"}, &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Success || res.Data != nil || c.GetMeta().Get("id") != nil {
+		t.Fatalf("unexpected response %+v", res)
+	}
+
+	cases := []struct {
+		params interface{}
+		code   websocket_server.RPCErrorCode
+	}{
+		{nil, websocket_server.ErrorCode_InvalidParams_Insufficient_Arguments},
+		{[]interface{}{}, websocket_server.ErrorCode_InvalidParams_Insufficient_Arguments},
+		{map[string]interface{}{}, websocket_server.ErrorCode_InvalidParams_Insufficient_Arguments},
+		{[]interface{}{1}, websocket_server.ErrorCode_InvalidParams_Invalid_Arguments},
+
+		// Empty token is invalid rather than unauthenticated
+		{map[string]interface{}{"token": ""}, websocket_server.ErrorCode_InvalidParams},
+		{[]interface{}{""}, websocket_server.ErrorCode_InvalidParams},
+	}
+
+	for _, tc := range cases {
+
+		_, err := c.Invoke(context.Background(), "Auth.Authenticate", tc.params)
+
+		// Codes are mapped to the same JSON-RPC code, messages tell them apart
+		var rpcErr *websocket_server.RPCError
+		if !errors.As(err, &rpcErr) || rpcErr.Message != websocket_server.GetErrorMessage(tc.code) {
+			t.Fatalf("%v: unexpected error %v", tc.params, err)
+		}
+	}
+}
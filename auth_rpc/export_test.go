@@ -0,0 +1,17 @@
+package auth_rpc
+
+import (
+	"github.com/weedbox/websocket-modules/websocket_server"
+	"go.uber.org/zap"
+)
+
+// RegisterAuthenticate registers Auth.Authenticate without starting module
+func RegisterAuthenticate(a websocket_server.Adapter, authenticator Authenticator) {
+
+	arpc := &AuthRPC{
+		logger:        zap.NewNop(),
+		authenticator: authenticator,
+	}
+
+	arpc.register(a)
+}
@@ -0,0 +1,145 @@
+package cborrpc
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/weedbox/websocket-modules/jsonrpc"
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+// CBOR (RFC 8949) messages use the same members as JSON-RPC 2.0 objects without "jsonrpc" version:
+//
+//	request:      {"id": 1, "method": "Foo", "params": [...]}
+//	notification: {"method": "Foo", "params": {...}}
+//	response:     {"id": 1, "result": ...}
+//	error:        {"id": 1, "error": {"code": -32601, "message": "Method not found"}}
+//
+// Batches are arrays of messages. Byte strings are passed to handlers as []byte. Error codes are
+// mapped the same way as the jsonrpc backend.
+type CBORRPC struct {
+	encOptions cbor.EncOptions
+	decOptions cbor.DecOptions
+	encMode    cbor.EncMode
+	decMode    cbor.DecMode
+}
+
+type CBORRPCOpt func(*CBORRPC)
+
+// WithEncOptions replaces encoding options, Core Deterministic Encoding is used by default
+func WithEncOptions(opts cbor.EncOptions) CBORRPCOpt {
+	return func(cr *CBORRPC) {
+		cr.encOptions = opts
+	}
+}
+
+func WithDecOptions(opts cbor.DecOptions) CBORRPCOpt {
+	return func(cr *CBORRPC) {
+		cr.decOptions = opts
+	}
+}
+
+func New(opts ...CBORRPCOpt) (*CBORRPC, error) {
+
+	cr := &CBORRPC{
+		encOptions: cbor.CoreDetEncOptions(),
+		decOptions: cbor.DecOptions{
+			DupMapKey: cbor.DupMapKeyEnforcedAPF,
+			IntDec:    cbor.IntDecConvertSigned,
+		},
+	}
+
+	for _, o := range opts {
+		o(cr)
+	}
+
+	// Members of messages are looked up by string keys
+	if cr.decOptions.DefaultMapType == nil {
+		cr.decOptions.DefaultMapType = reflect.TypeOf(map[string]interface{}(nil))
+	}
+
+	em, err := cr.encOptions.EncMode()
+	if err != nil {
+		return nil, err
+	}
+
+	dm, err := cr.decOptions.DecMode()
+	if err != nil {
+		return nil, err
+	}
+
+	cr.encMode = em
+	cr.decMode = dm
+
+	return cr, nil
+}
+
+// ConvertErrorCode reports the same error codes as JSON-RPC
+func (cr *CBORRPC) ConvertErrorCode(code websocket_server.RPCErrorCode) int64 {
+	return int64(jsonrpc.GetErrorCode(code))
+}
+
+func (cr *CBORRPC) IsBinary() bool {
+	return true
+}
+
+func (cr *CBORRPC) ParseRequest(r io.Reader) (*websocket_server.RPCRequest, error) {
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		return nil, websocket_server.NewError(websocket_server.ErrorCode_ParseError, nil)
+	}
+
+	var message interface{}
+	if err := cr.decMode.Unmarshal(data, &message); err != nil {
+		return nil, websocket_server.NewError(websocket_server.ErrorCode_ParseError, nil)
+	}
+
+	return jsonrpc.ParseObject(message)
+}
+
+func (cr *CBORRPC) PrepareRequest(req *websocket_server.RPCRequest) ([]byte, error) {
+	return cr.encMode.Marshal(newRequestObject(req))
+}
+
+func (cr *CBORRPC) PrepareResponse(res *websocket_server.RPCResponse) ([]byte, error) {
+
+	data, err := cr.encMode.Marshal(newResponseObject(res))
+	if err != nil {
+		return cr.encMode.Marshal(newErrorObject(res.ID, err))
+	}
+
+	return data, nil
+}
+
+func (cr *CBORRPC) PrepareBatchResponse(responses []*websocket_server.RPCResponse) ([]byte, error) {
+
+	// Members are encoded already, so they are embedded as raw messages
+	entries := make([]cbor.RawMessage, 0, len(responses))
+	for _, res := range responses {
+
+		data, err := cr.PrepareResponse(res)
+		if err != nil {
+			return []byte(""), err
+		}
+
+		entries = append(entries, cbor.RawMessage(data))
+	}
+
+	var buf bytes.Buffer
+	if err := cr.encMode.NewEncoder(&buf).Encode(entries); err != nil {
+		return []byte(""), err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (cr *CBORRPC) PrepareNotification(eventName string, payload interface{}) ([]byte, error) {
+	return cr.encMode.Marshal(jsonrpc.NewNotificationObject(eventName, newPayload(payload)))
+}
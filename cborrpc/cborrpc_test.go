@@ -0,0 +1,221 @@
+package cborrpc_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/weedbox/websocket-modules/cborrpc"
+	"github.com/weedbox/websocket-modules/websocket_server"
+	"github.com/weedbox/websocket-modules/websocket_servertest"
+)
+
+type reading struct {
+	Sensor string `json:"sensor" validate:"required"`
+	Value  int    `json:"value"`
+	Raw    []byte `json:"raw"`
+}
+
+type response struct {
+	ID     interface{} `cbor:"id"`
+	Result interface{} `cbor:"result"`
+	Error  *struct {
+		Code    int         `cbor:"code"`
+		Message string      `cbor:"message"`
+		Data    interface{} `cbor:"data"`
+	} `cbor:"error"`
+}
+
+func newBackend(t *testing.T) *cborrpc.CBORRPC {
+
+	cr, err := cborrpc.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cr
+}
+
+func TestRequestRoundTrip(t *testing.T) {
+
+	cr := newBackend(t)
+
+	data, err := cr.PrepareRequest(&websocket_server.RPCRequest{
+		ID:     websocket_server.NewStringID("r1"),
+		Method: "Report",
+		Params: []interface{}{"t1", 21, []byte{1, 2}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := cr.ParseRequest(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if req.ID != websocket_server.NewStringID("r1") || req.Method != "Report" {
+		t.Fatalf("unexpected request %+v", req)
+	}
+
+	// Byte strings are kept as bytes
+	if raw, ok := req.Params.([]interface{})[2].([]byte); !ok || !bytes.Equal(raw, []byte{1, 2}) {
+		t.Fatalf("unexpected params %#v", req.Params)
+	}
+
+	var r reading
+	if err := websocket_server.BindParams(req, &r); err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Sensor != "t1" || r.Value != 21 || !bytes.Equal(r.Raw, []byte{1, 2}) {
+		t.Fatalf("unexpected params %+v", r)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+
+	cr := newBackend(t)
+
+	var rpcErr *websocket_server.RPCError
+
+	for _, data := range [][]byte{nil, {0xff}, {0xa2, 0x61, 0x61, 0x01, 0x61, 0x61, 0x02}} {
+		_, err := cr.ParseRequest(bytes.NewReader(data))
+		if !errors.As(err, &rpcErr) || rpcErr.Code != websocket_server.ErrorCode_ParseError {
+			t.Fatalf("%x: unexpected error %v", data, err)
+		}
+	}
+}
+
+func TestDeterministicResponse(t *testing.T) {
+
+	cr := newBackend(t)
+
+	res := &websocket_server.RPCResponse{
+		ID:     websocket_server.NewIntID(1),
+		Result: map[string]interface{}{"bb": 1, "a": 2, "ccc": 3, "d": 4},
+	}
+
+	first, err := cr.PrepareResponse(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		data, err := cr.PrepareResponse(res)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(data, first) {
+			t.Fatalf("encoding is not deterministic %x %x", data, first)
+		}
+	}
+
+	var decoded response
+	if err := cbor.Unmarshal(first, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.ID != uint64(1) || decoded.Error != nil {
+		t.Fatalf("unexpected response %+v", decoded)
+	}
+}
+
+func TestErrorResponse(t *testing.T) {
+
+	cr := newBackend(t)
+
+	data, err := cr.PrepareResponse(&websocket_server.RPCResponse{
+		ID:    websocket_server.NewIntID(2),
+		Error: websocket_server.NewError(websocket_server.ErrorCode_InvalidParams_Insufficient_Arguments, []string{"sensor"}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var res response
+	if err := cbor.Unmarshal(data, &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Error == nil || res.Error.Code != -32602 || res.Error.Message != "Insufficient arguments" || !reflect.DeepEqual(res.Error.Data, []interface{}{"sensor"}) {
+		t.Fatalf("unexpected response %+v", res)
+	}
+
+	data, err = cr.PrepareBatchResponse([]*websocket_server.RPCResponse{
+		{ID: websocket_server.NewIntID(1), Result: "a"},
+		{ID: websocket_server.NewIntID(2), Result: "b"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var batch []response
+	if err := cbor.Unmarshal(data, &batch); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(batch) != 2 || batch[0].Result != "a" || batch[1].ID != uint64(2) {
+		t.Fatalf("unexpected batch %+v", batch)
+	}
+}
+
+func TestIDPayload(t *testing.T) {
+
+	cr := newBackend(t)
+
+	data, err := cr.PrepareNotification(websocket_server.StreamChunkEvent, &websocket_server.StreamChunk{
+		ID:   websocket_server.NewStringID("s"),
+		Seq:  1,
+		Data: "x",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var v interface{}
+	if err := cbor.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	// ID is encoded in its native form
+	expected := map[interface{}]interface{}{"method": websocket_server.StreamChunkEvent, "params": map[interface{}]interface{}{"id": "s", "seq": uint64(1), "data": "x"}}
+	if !reflect.DeepEqual(v, expected) {
+		t.Fatalf("unexpected notification %#v", v)
+	}
+
+	data, err = cr.PrepareResponse(&websocket_server.RPCResponse{ID: websocket_server.NewIntID(-3), Result: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := cr.ParseRequest(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if req.Response == nil || req.Response.ID != websocket_server.NewIntID(-3) {
+		t.Fatalf("unexpected response %+v", req)
+	}
+}
+
+func TestInvoke(t *testing.T) {
+
+	ra := websocket_server.NewRPCAdapter(websocket_server.WithRPCBackend(newBackend(t)))
+	websocket_server.RegisterTyped(ra, "Report", func(c *websocket_server.Context, r *reading) (int, error) {
+		return r.Value + len(r.Raw), nil
+	})
+
+	var n int
+	if err := websocket_servertest.InvokeInto(context.Background(), ra, "Report", map[string]interface{}{"sensor": "t", "value": 1, "raw": []byte{1, 2}}, &n); err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 3 {
+		t.Fatalf("unexpected result %d", n)
+	}
+}
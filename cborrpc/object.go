@@ -0,0 +1,82 @@
+package cborrpc
+
+import (
+	"github.com/fxamacker/cbor/v2"
+	"github.com/weedbox/websocket-modules/jsonrpc"
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+var idEncMode, _ = cbor.CoreDetEncOptions().EncMode()
+
+// id encodes ID in its native form since websocket_server knows nothing about CBOR
+type id websocket_server.ID
+
+func (i id) MarshalCBOR() ([]byte, error) {
+	return idEncMode.Marshal(websocket_server.ID(i).Value())
+}
+
+type requestObject struct {
+	ID     id          `cbor:"id"`
+	Method string      `cbor:"method"`
+	Params interface{} `cbor:"params,omitempty"`
+}
+
+type responseObject struct {
+	ID     id          `cbor:"id"`
+	Result interface{} `cbor:"result"`
+}
+
+type errorObject struct {
+	ID    id                       `cbor:"id"`
+	Error *jsonrpc.ErrorObjectInfo `cbor:"error"`
+}
+
+type streamChunk struct {
+	ID   id          `cbor:"id"`
+	Seq  int64       `cbor:"seq"`
+	Data interface{} `cbor:"data"`
+}
+
+func newRequestObject(req *websocket_server.RPCRequest) *requestObject {
+
+	obj := jsonrpc.NewRequestObject(req)
+
+	return &requestObject{
+		ID:     id(obj.ID),
+		Method: obj.Method,
+		Params: obj.Params,
+	}
+}
+
+func newResponseObject(res *websocket_server.RPCResponse) interface{} {
+
+	if res.Error != nil {
+		return newErrorObject(res.ID, res.Error)
+	}
+
+	return &responseObject{
+		ID:     id(res.ID),
+		Result: res.Result,
+	}
+}
+
+func newErrorObject(i websocket_server.ID, err error) *errorObject {
+	return &errorObject{
+		ID:    id(i),
+		Error: jsonrpc.NewErrorObject(i, err).Error,
+	}
+}
+
+// newPayload replaces payloads which carry ID
+func newPayload(payload interface{}) interface{} {
+
+	if chunk, ok := payload.(*websocket_server.StreamChunk); ok {
+		return &streamChunk{
+			ID:   id(chunk.ID),
+			Seq:  chunk.Seq,
+			Data: chunk.Data,
+		}
+	}
+
+	return payload
+}
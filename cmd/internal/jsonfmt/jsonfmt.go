@@ -0,0 +1,129 @@
+// Package jsonfmt prints JSON for command line tools. MarshalIndent of jsoniter misplaces nested
+// maps and interfaces, so values are encoded compactly and indented here.
+package jsonfmt
+
+import (
+	"bytes"
+	"errors"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+var ErrInvalidJSON = errors.New("jsonfmt: invalid json")
+
+const indent = "  "
+
+// MarshalIndent encodes value with two spaces of indentation
+func MarshalIndent(v interface{}) ([]byte, error) {
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return Indent(data)
+}
+
+// Indent formats JSON with two spaces of indentation, numbers and order of keys are kept
+func Indent(data []byte) ([]byte, error) {
+
+	if !valid(data) {
+		return nil, ErrInvalidJSON
+	}
+
+	var buf bytes.Buffer
+	depth := 0
+	inString := false
+	escaped := false
+
+	newline := func() {
+		buf.WriteByte('\n')
+		for i := 0; i < depth; i++ {
+			buf.WriteString(indent)
+		}
+	}
+
+	for i := 0; i < len(data); i++ {
+
+		c := data[i]
+
+		if inString {
+			buf.WriteByte(c)
+
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+
+			continue
+		}
+
+		switch c {
+		case ' ', '\t', '\r', '\n':
+		case '"':
+			inString = true
+			buf.WriteByte(c)
+		case '{', '[':
+			buf.WriteByte(c)
+
+			// Empty object and array stay in one line
+			next := nextToken(data, i+1)
+			if next == '}' || next == ']' {
+				continue
+			}
+
+			depth++
+			newline()
+		case '}', ']':
+			if prev := lastByte(&buf); prev != '{' && prev != '[' {
+				depth--
+				newline()
+			}
+
+			buf.WriteByte(c)
+		case ',':
+			buf.WriteByte(c)
+			newline()
+		case ':':
+			buf.WriteString(": ")
+		default:
+			buf.WriteByte(c)
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// valid accepts a single value, Valid of jsoniter rejects numbers at top level
+func valid(data []byte) bool {
+	var v interface{}
+	return json.Unmarshal(data, &v) == nil
+}
+
+func nextToken(data []byte, i int) byte {
+
+	for ; i < len(data); i++ {
+		switch data[i] {
+		case ' ', '\t', '\r', '\n':
+		default:
+			return data[i]
+		}
+	}
+
+	return 0
+}
+
+func lastByte(buf *bytes.Buffer) byte {
+
+	if buf.Len() == 0 {
+		return 0
+	}
+
+	return buf.Bytes()[buf.Len()-1]
+}
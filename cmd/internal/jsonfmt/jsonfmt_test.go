@@ -0,0 +1,45 @@
+package jsonfmt
+
+import "testing"
+
+func TestIndent(t *testing.T) {
+
+	cases := map[string]string{
+		`1`:            `1`,
+		`{}`:           `{}`,
+		` [ ] `:        `[]`,
+		`{"a":[]}`:     "{\n  \"a\": []\n}",
+		`[1, {"b":2}]`: "[\n  1,\n  {\n    \"b\": 2\n  }\n]",
+		`{"s":"a,b:{\"[x]\"}","n":12345678901234567890,"m":{"x":{"y":[1]}}}`: "{\n  \"s\": \"a,b:{\\\"[x]\\\"}\",\n  \"n\": 12345678901234567890,\n  \"m\": {\n    \"x\": {\n      \"y\": [\n        1\n      ]\n    }\n  }\n}",
+	}
+
+	for in, expected := range cases {
+
+		out, err := Indent([]byte(in))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(out) != expected {
+			t.Fatalf("%s: unexpected output\n%s", in, out)
+		}
+	}
+
+	for _, in := range []string{`{"a":`, `1 2`, `{"a":1}}`, `x`} {
+		if _, err := Indent([]byte(in)); err != ErrInvalidJSON {
+			t.Fatalf("%s: expected invalid json, got %v", in, err)
+		}
+	}
+}
+
+func TestMarshalIndent(t *testing.T) {
+
+	out, err := MarshalIndent(map[string]interface{}{"b": []interface{}{map[string]int{"c": 1}}, "a": nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(out) != "{\n  \"a\": null,\n  \"b\": [\n    {\n      \"c\": 1\n    }\n  ]\n}" {
+		t.Fatalf("unexpected output\n%s", out)
+	}
+}
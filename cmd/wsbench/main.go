@@ -0,0 +1,451 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/weedbox/websocket-modules/cmd/internal/jsonfmt"
+	"github.com/weedbox/websocket-modules/websocket_client"
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+type mixFlags []string
+
+func (m *mixFlags) String() string {
+	return strings.Join(*m, ", ")
+}
+
+func (m *mixFlags) Set(v string) error {
+	*m = append(*m, v)
+	return nil
+}
+
+type config struct {
+	URL         string      `json:"url"`
+	Connections int         `json:"connections"`
+	RampUp      duration    `json:"rampUp"`
+	Duration    duration    `json:"duration"`
+	Rate        float64     `json:"rate"`
+	Timeout     duration    `json:"timeout"`
+	Mix         []*mixEntry `json:"mix"`
+	Token       string      `json:"-"`
+	AuthMethod  string      `json:"authMethod,omitempty"`
+	Event       string      `json:"event,omitempty"`
+	EventField  string      `json:"eventField,omitempty"`
+}
+
+// duration is reported like "30s"
+type duration time.Duration
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+type mixEntry struct {
+	Method string      `json:"method"`
+	Weight int         `json:"weight"`
+	Params interface{} `json:"params,omitempty"`
+}
+
+func main() {
+
+	cfg := &config{}
+	var mix mixFlags
+
+	flag.IntVar(&cfg.Connections, "c", 100, "number of connections")
+	flag.DurationVar((*time.Duration)(&cfg.RampUp), "ramp", 0, "period in which connections are opened evenly")
+	flag.DurationVar((*time.Duration)(&cfg.Duration), "d", 30*time.Second, "duration of test including ramp-up")
+	flag.Float64Var(&cfg.Rate, "rate", 0, "calls per second of each connection, zero calls as fast as possible")
+	flag.DurationVar((*time.Duration)(&cfg.Timeout), "timeout", 10*time.Second, "timeout of each call")
+	flag.Var(&mix, "mix", "METHOD[:WEIGHT[:PARAMS]] which is called by the weight, repeatable")
+	flag.StringVar(&cfg.Token, "token", "", "token which is authenticated by each connection")
+	flag.StringVar(&cfg.AuthMethod, "auth-method", "Auth.Authenticate", "method which authenticates token")
+	flag.StringVar(&cfg.Event, "event", "", "notification whose fan-out delay is measured")
+	flag.StringVar(&cfg.EventField, "event-field", "ts", "field of notification which has the time it was sent in unix epoch")
+	output := flag.String("o", "", "output file of JSON report, stdout if not specified")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: wsbench [flags] URL\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 || cfg.Connections <= 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg.URL = flag.Arg(0)
+
+	if err := run(cfg, mix, *output); err != nil {
+		fmt.Fprintln(os.Stderr, "wsbench:", err)
+		os.Exit(1)
+	}
+}
+
+func parseMix(mix mixFlags) ([]*mixEntry, error) {
+
+	entries := make([]*mixEntry, 0, len(mix))
+	for _, m := range mix {
+
+		parts := strings.SplitN(m, ":", 3)
+
+		e := &mixEntry{
+			Method: parts[0],
+			Weight: 1,
+		}
+
+		if len(parts) > 1 && len(parts[1]) > 0 {
+			w, err := strconv.Atoi(parts[1])
+			if err != nil || w <= 0 {
+				return nil, fmt.Errorf("invalid weight of %s", m)
+			}
+
+			e.Weight = w
+		}
+
+		if len(parts) > 2 {
+			if err := json.Unmarshal([]byte(parts[2]), &e.Params); err != nil {
+				return nil, fmt.Errorf("invalid params of %s: %w", e.Method, err)
+			}
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+func run(cfg *config, mix mixFlags, output string) error {
+
+	entries, err := parseMix(mix)
+	if err != nil {
+		return err
+	}
+
+	if len(entries) == 0 && len(cfg.Event) == 0 {
+		return errors.New("either -mix or -event is required")
+	}
+
+	cfg.Mix = entries
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(cfg.Duration))
+	defer cancel()
+
+	b := newBench(cfg)
+
+	go b.progress(ctx)
+
+	start := time.Now()
+	b.run(ctx)
+	elapsed := time.Since(start)
+
+	report := b.report(elapsed)
+
+	data, err := jsonfmt.MarshalIndent(report)
+	if err != nil {
+		return err
+	}
+
+	data = append(data, '\n')
+
+	if len(output) == 0 {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+
+	return os.WriteFile(output, data, 0644)
+}
+
+type bench struct {
+	// Counters for progress
+	established int64
+	calls       int64
+	failures    int64
+	events      int64
+
+	cfg         *config
+	totalWeight int
+	workers     []*worker
+}
+
+type worker struct {
+	bench       *bench
+	id          int
+	rand        *rand.Rand
+	connectTime time.Duration
+	attempted   bool
+	connected   bool
+	dropped     bool
+	stats       map[string]*methodStats
+
+	// Notifications are received by reader of client
+	eventMutex  sync.Mutex
+	eventCount  int64
+	eventDelays []time.Duration
+}
+
+func newBench(cfg *config) *bench {
+
+	b := &bench{
+		cfg:     cfg,
+		workers: make([]*worker, cfg.Connections),
+	}
+
+	for _, e := range cfg.Mix {
+		b.totalWeight += e.Weight
+	}
+
+	for i := range b.workers {
+		b.workers[i] = &worker{
+			bench: b,
+			id:    i,
+			rand:  rand.New(rand.NewSource(time.Now().UnixNano() + int64(i))),
+			stats: make(map[string]*methodStats),
+		}
+	}
+
+	return b
+}
+
+func (b *bench) run(ctx context.Context) {
+
+	var wg sync.WaitGroup
+
+	for i, w := range b.workers {
+
+		// Connections are opened evenly during ramp-up
+		delay := time.Duration(0)
+		if b.cfg.RampUp > 0 {
+			delay = time.Duration(b.cfg.RampUp) * time.Duration(i) / time.Duration(len(b.workers))
+		}
+
+		wg.Add(1)
+		go func(w *worker, delay time.Duration) {
+			defer wg.Done()
+
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return
+			}
+
+			w.run(ctx)
+		}(w, delay)
+	}
+
+	wg.Wait()
+}
+
+func (b *bench) progress(ctx context.Context) {
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var lastCalls int64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			calls := atomic.LoadInt64(&b.calls)
+			fmt.Fprintf(os.Stderr, "connections=%d calls/s=%d failures=%d events=%d\n",
+				atomic.LoadInt64(&b.established),
+				calls-lastCalls,
+				atomic.LoadInt64(&b.failures),
+				atomic.LoadInt64(&b.events),
+			)
+
+			lastCalls = calls
+		}
+	}
+}
+
+func (w *worker) run(ctx context.Context) {
+
+	cfg := w.bench.cfg
+
+	w.attempted = true
+
+	start := time.Now()
+	c, err := websocket_client.Dial(ctx, cfg.URL)
+	if err != nil {
+		return
+	}
+
+	defer c.Close()
+
+	connectTime := time.Since(start)
+
+	if len(cfg.Event) > 0 {
+		c.On(cfg.Event, w.receiveEvent)
+	}
+
+	// Connection is not established until authentication succeeded
+	if len(cfg.Token) > 0 && !w.authenticate(ctx, c) {
+		return
+	}
+
+	w.connectTime = connectTime
+	w.connected = true
+	atomic.AddInt64(&w.bench.established, 1)
+
+	var ticker *time.Ticker
+	if cfg.Rate > 0 {
+		ticker = time.NewTicker(time.Duration(float64(time.Second) / cfg.Rate))
+		defer ticker.Stop()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.Done():
+			w.dropped = true
+			return
+		default:
+		}
+
+		// Connection only listens to notifications if there is no method to call
+		if w.bench.totalWeight == 0 {
+			select {
+			case <-ctx.Done():
+			case <-c.Done():
+				w.dropped = true
+			}
+
+			return
+		}
+
+		if ticker != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+
+		e := w.pick()
+
+		var res jsoniter.RawMessage
+		w.call(ctx, c, e.Method, e.Params, &res)
+	}
+}
+
+func (w *worker) authenticate(ctx context.Context, c *websocket_client.Client) bool {
+
+	cfg := w.bench.cfg
+
+	var res struct {
+		Success bool `json:"success"`
+	}
+
+	if err := w.call(ctx, c, cfg.AuthMethod, map[string]interface{}{"token": cfg.Token}, &res); err != nil {
+		return false
+	}
+
+	return res.Success
+}
+
+func (w *worker) pick() *mixEntry {
+
+	n := w.rand.Intn(w.bench.totalWeight)
+	for _, e := range w.bench.cfg.Mix {
+		if n < e.Weight {
+			return e
+		}
+
+		n -= e.Weight
+	}
+
+	return w.bench.cfg.Mix[len(w.bench.cfg.Mix)-1]
+}
+
+func (w *worker) call(ctx context.Context, c *websocket_client.Client, method string, params interface{}, result interface{}) error {
+
+	callCtx, cancel := context.WithTimeout(ctx, time.Duration(w.bench.cfg.Timeout))
+	defer cancel()
+
+	start := time.Now()
+	err := c.Call(callCtx, method, params, result)
+	elapsed := time.Since(start)
+
+	// Calls which were interrupted by the end of test are not counted
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	s, ok := w.stats[method]
+	if !ok {
+		s = newMethodStats()
+		w.stats[method] = s
+	}
+
+	s.calls++
+	atomic.AddInt64(&w.bench.calls, 1)
+
+	var rpcErr *websocket_server.RPCError
+	switch {
+	case err == nil:
+		s.latencies = append(s.latencies, elapsed)
+		return nil
+	case errors.Is(err, context.DeadlineExceeded):
+		s.timeouts++
+	case errors.As(err, &rpcErr):
+		s.errors++
+		s.errorCodes[int64(rpcErr.Code)]++
+	default:
+		s.errors++
+	}
+
+	atomic.AddInt64(&w.bench.failures, 1)
+
+	return err
+}
+
+func (w *worker) receiveEvent(params jsoniter.RawMessage) {
+
+	now := time.Now()
+	atomic.AddInt64(&w.bench.events, 1)
+
+	var payload map[string]interface{}
+	jsoniter.Unmarshal(params, &payload)
+
+	w.eventMutex.Lock()
+	defer w.eventMutex.Unlock()
+
+	w.eventCount++
+
+	if ts, ok := payload[w.bench.cfg.EventField].(float64); ok {
+		w.eventDelays = append(w.eventDelays, now.Sub(epochTime(ts)))
+	}
+}
+
+// epochTime accepts seconds, milliseconds, microseconds or nanoseconds by magnitude
+func epochTime(ts float64) time.Time {
+	switch {
+	case ts > 1e17:
+		return time.Unix(0, int64(ts))
+	case ts > 1e14:
+		return time.UnixMicro(int64(ts))
+	case ts > 1e11:
+		return time.UnixMilli(int64(ts))
+	}
+
+	return time.Unix(0, int64(ts*1e9))
+}
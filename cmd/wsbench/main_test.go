@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/weedbox/websocket-modules/jsonrpc"
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+func serve(t *testing.T) string {
+
+	ra := websocket_server.NewRPCAdapter(websocket_server.WithRPCBackend(&jsonrpc.JSONRPC{}))
+	ra.Register("Auth.Authenticate", func(c *websocket_server.Context) (interface{}, error) {
+		token, _ := c.LookupParam(0, "token")
+		return map[string]interface{}{"success": token == "good"}, nil
+	})
+	ra.Register("ping", func(c *websocket_server.Context) (interface{}, error) {
+		return "pong", nil
+	})
+
+	options := websocket_server.NewOptions()
+	options.Adapter = ra
+	ep := websocket_server.NewEndpoint("/ws", options)
+
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	r.GET("/ws", func(c *gin.Context) {
+		ep.Establish(c)
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &http.Server{Handler: r}
+	go srv.Serve(ln)
+	t.Cleanup(func() {
+		srv.Close()
+	})
+
+	return "ws://" + ln.Addr().String() + "/ws"
+}
+
+func runBench(t *testing.T, token string) *Report {
+
+	mix, err := parseMix(mixFlags{"ping:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config{
+		URL:         serve(t),
+		Connections: 3,
+		Duration:    duration(200 * time.Millisecond),
+		Rate:        20,
+		Timeout:     duration(time.Second),
+		Mix:         mix,
+		Token:       token,
+		AuthMethod:  "Auth.Authenticate",
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Duration))
+	defer cancel()
+
+	b := newBench(cfg)
+
+	start := time.Now()
+	b.run(ctx)
+
+	return b.report(time.Since(start))
+}
+
+func TestBench(t *testing.T) {
+
+	r := runBench(t, "good")
+
+	if r.Connections.Established != 3 || r.Connections.Failed != 0 {
+		t.Fatalf("unexpected connections %+v", r.Connections)
+	}
+
+	ping, ok := r.Methods["ping"]
+	if !ok || ping.Total == 0 || ping.Errors != 0 {
+		t.Fatalf("unexpected calls %+v", ping)
+	}
+}
+
+func TestBenchAuthenticationFailed(t *testing.T) {
+
+	r := runBench(t, "bad")
+
+	if r.Connections.Established != 0 || r.Connections.Failed != 3 {
+		t.Fatalf("unexpected connections %+v", r.Connections)
+	}
+
+	if _, ok := r.Methods["ping"]; ok {
+		t.Fatal("methods were called without authentication")
+	}
+}
+
+func TestParseMix(t *testing.T) {
+
+	entries, err := parseMix(mixFlags{"ping", `echo:3:{"a":"b:c"}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if entries[0].Weight != 1 || entries[1].Weight != 3 {
+		t.Fatalf("unexpected weights %d %d", entries[0].Weight, entries[1].Weight)
+	}
+
+	if params, ok := entries[1].Params.(map[string]interface{}); !ok || params["a"] != "b:c" {
+		t.Fatalf("unexpected params %v", entries[1].Params)
+	}
+
+	for _, m := range []string{"ping:0", "ping:x", "ping:1:{"} {
+		if _, err := parseMix(mixFlags{m}); err == nil {
+			t.Fatalf("%s was accepted", m)
+		}
+	}
+}
+
+func TestReportDuration(t *testing.T) {
+
+	data, err := json.Marshal(&config{Duration: duration(30 * time.Second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cfg map[string]interface{}
+	json.Unmarshal(data, &cfg)
+
+	if cfg["duration"] != "30s" {
+		t.Fatalf("unexpected duration %v", cfg["duration"])
+	}
+}
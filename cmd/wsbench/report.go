@@ -0,0 +1,108 @@
+package main
+
+import (
+	"time"
+)
+
+type Report struct {
+	Config        *config                `json:"config"`
+	Elapsed       float64                `json:"elapsed"`
+	Connections   *ConnectionReport      `json:"connections"`
+	Calls         *CallReport            `json:"calls"`
+	Methods       map[string]*CallReport `json:"methods"`
+	Notifications *NotificationReport    `json:"notifications,omitempty"`
+}
+
+type ConnectionReport struct {
+	Target      int             `json:"target"`
+	Established int             `json:"established"`
+	Failed      int             `json:"failed"`
+	Dropped     int             `json:"dropped"`
+	ConnectTime *LatencySummary `json:"connectTime,omitempty"`
+}
+
+type CallReport struct {
+	Total      int64            `json:"total"`
+	Succeeded  int64            `json:"succeeded"`
+	Errors     int64            `json:"errors"`
+	Timeouts   int64            `json:"timeouts"`
+	ErrorRate  float64          `json:"errorRate"`
+	Rate       float64          `json:"rate"`
+	ErrorCodes map[string]int64 `json:"errorCodes,omitempty"`
+	Latency    *LatencySummary  `json:"latency,omitempty"`
+}
+
+type NotificationReport struct {
+	Event    string          `json:"event"`
+	Received int64           `json:"received"`
+	Rate     float64         `json:"rate"`
+	Delay    *LatencySummary `json:"delay,omitempty"`
+}
+
+func (b *bench) report(elapsed time.Duration) *Report {
+
+	r := &Report{
+		Config:  b.cfg,
+		Elapsed: elapsed.Seconds(),
+		Connections: &ConnectionReport{
+			Target: len(b.workers),
+		},
+		Methods: make(map[string]*CallReport),
+	}
+
+	total := newMethodStats()
+	methods := make(map[string]*methodStats)
+	connectTimes := make([]time.Duration, 0, len(b.workers))
+	delays := make([]time.Duration, 0)
+	var events int64
+
+	for _, w := range b.workers {
+
+		switch {
+		case w.connected:
+			r.Connections.Established++
+			connectTimes = append(connectTimes, w.connectTime)
+		case w.attempted:
+			r.Connections.Failed++
+		}
+
+		if w.dropped {
+			r.Connections.Dropped++
+		}
+
+		for method, s := range w.stats {
+
+			m, ok := methods[method]
+			if !ok {
+				m = newMethodStats()
+				methods[method] = m
+			}
+
+			m.merge(s)
+			total.merge(s)
+		}
+
+		w.eventMutex.Lock()
+		events += w.eventCount
+		delays = append(delays, w.eventDelays...)
+		w.eventMutex.Unlock()
+	}
+
+	r.Connections.ConnectTime = summarize(connectTimes)
+	r.Calls = total.report(elapsed)
+
+	for method, m := range methods {
+		r.Methods[method] = m.report(elapsed)
+	}
+
+	if len(b.cfg.Event) > 0 {
+		r.Notifications = &NotificationReport{
+			Event:    b.cfg.Event,
+			Received: events,
+			Rate:     float64(events) / elapsed.Seconds(),
+			Delay:    summarize(delays),
+		}
+	}
+
+	return r
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"time"
+)
+
+// LatencySummary is reported in milliseconds
+type LatencySummary struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+func summarize(samples []time.Duration) *LatencySummary {
+
+	if len(samples) == 0 {
+		return nil
+	}
+
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i] < samples[j]
+	})
+
+	var total time.Duration
+	for _, s := range samples {
+		total += s
+	}
+
+	percentile := func(p float64) float64 {
+		i := int(p*float64(len(samples))+0.5) - 1
+		if i < 0 {
+			i = 0
+		}
+
+		if i >= len(samples) {
+			i = len(samples) - 1
+		}
+
+		return ms(samples[i])
+	}
+
+	return &LatencySummary{
+		Min:  ms(samples[0]),
+		Mean: ms(total / time.Duration(len(samples))),
+		P50:  percentile(0.50),
+		P90:  percentile(0.90),
+		P95:  percentile(0.95),
+		P99:  percentile(0.99),
+		Max:  ms(samples[len(samples)-1]),
+	}
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// methodStats is owned by a single worker, they are merged after the run
+type methodStats struct {
+	calls      int64
+	errors     int64
+	timeouts   int64
+	errorCodes map[int64]int64
+	latencies  []time.Duration
+}
+
+func newMethodStats() *methodStats {
+	return &methodStats{
+		errorCodes: make(map[int64]int64),
+		latencies:  make([]time.Duration, 0, 1024),
+	}
+}
+
+func (ms *methodStats) merge(other *methodStats) {
+
+	ms.calls += other.calls
+	ms.errors += other.errors
+	ms.timeouts += other.timeouts
+	ms.latencies = append(ms.latencies, other.latencies...)
+
+	for code, n := range other.errorCodes {
+		ms.errorCodes[code] += n
+	}
+}
+
+func (ms *methodStats) report(elapsed time.Duration) *CallReport {
+
+	r := &CallReport{
+		Total:      ms.calls,
+		Succeeded:  ms.calls - ms.errors - ms.timeouts,
+		Errors:     ms.errors,
+		Timeouts:   ms.timeouts,
+		ErrorCodes: make(map[string]int64, len(ms.errorCodes)),
+		Latency:    summarize(ms.latencies),
+	}
+
+	if ms.calls > 0 {
+		r.ErrorRate = float64(ms.errors+ms.timeouts) / float64(ms.calls)
+	}
+
+	if elapsed > 0 {
+		r.Rate = float64(ms.calls) / elapsed.Seconds()
+	}
+
+	for code, n := range ms.errorCodes {
+		r.ErrorCodes[strconv.FormatInt(code, 10)] = n
+	}
+
+	return r
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/weedbox/websocket-modules/codegen"
+)
+
+func main() {
+
+	dir := flag.String("dir", ".", "directory where packages are resolved")
+	output := flag.String("output", "", "output file, stdout if not specified")
+	pkgName := flag.String("package", "client", "package name of generated code")
+	typeName := flag.String("type", "Client", "name of generated client type")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: wsrpc-gogen [flags] [packages]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	patterns := flag.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+
+	if err := run(*dir, patterns, *output, *pkgName, *typeName); err != nil {
+		fmt.Fprintln(os.Stderr, "wsrpc-gogen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(dir string, patterns []string, output string, pkgName string, typeName string) error {
+
+	methods, err := codegen.ScanPackages(dir, patterns...)
+	if err != nil {
+		return err
+	}
+
+	if len(methods) == 0 {
+		return fmt.Errorf("no typed methods were found in %v", patterns)
+	}
+
+	code, err := codegen.GenerateGo(methods,
+		codegen.WithPackageName(pkgName),
+		codegen.WithTypeName(typeName),
+	)
+	if err != nil {
+		return err
+	}
+
+	if len(output) == 0 {
+		_, err := os.Stdout.Write(code)
+		return err
+	}
+
+	return os.WriteFile(output, code, 0644)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/weedbox/websocket-modules/codegen"
+)
+
+func main() {
+
+	input := flag.String("input", "", "OpenRPC document, which is a file, URL like http://localhost/ws/openrpc.json or \"-\" for stdin")
+	output := flag.String("output", "", "output file, stdout if not specified")
+	className := flag.String("class", "Client", "name of generated client class")
+	flag.Parse()
+
+	if len(*input) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(*input, *output, *className); err != nil {
+		fmt.Fprintln(os.Stderr, "wsrpc-tsgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(input string, output string, className string) error {
+
+	doc, err := codegen.Load(input)
+	if err != nil {
+		return err
+	}
+
+	code, err := codegen.GenerateTypeScript(doc, codegen.WithClassName(className))
+	if err != nil {
+		return err
+	}
+
+	if len(output) == 0 {
+		_, err := os.Stdout.Write(code)
+		return err
+	}
+
+	return os.WriteFile(output, code, 0644)
+}
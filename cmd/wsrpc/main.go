@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/weedbox/websocket-modules/cmd/internal/jsonfmt"
+	"github.com/weedbox/websocket-modules/websocket_client"
+	"github.com/weedbox/websocket-modules/websocket_server"
+	"golang.org/x/term"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+// paramsJSON keeps numbers as they were written, so large integers are not rounded to float64
+var paramsJSON = jsoniter.Config{
+	EscapeHTML:             true,
+	SortMapKeys:            true,
+	ValidateJsonRawMessage: true,
+	UseNumber:              true,
+}.Froze()
+
+// errCallFailed is returned after error response was printed
+var errCallFailed = errors.New("call failed")
+
+type headerFlags []string
+
+func (h *headerFlags) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *headerFlags) Set(v string) error {
+	*h = append(*h, v)
+	return nil
+}
+
+type config struct {
+	url        string
+	token      string
+	authMethod string
+	params     string
+	timeout    time.Duration
+	listen     bool
+	stream     bool
+	headers    headerFlags
+}
+
+func main() {
+
+	cfg := &config{}
+
+	flag.StringVar(&cfg.token, "token", "", "token which is authenticated before calling methods")
+	flag.StringVar(&cfg.authMethod, "auth-method", "Auth.Authenticate", "method which authenticates token")
+	flag.StringVar(&cfg.params, "params", "", "params in JSON, \"-\" reads them from stdin")
+	flag.DurationVar(&cfg.timeout, "timeout", 30*time.Second, "timeout of each call")
+	flag.BoolVar(&cfg.listen, "listen", false, "print notifications until interrupted")
+	flag.BoolVar(&cfg.stream, "stream", false, "print partial results of streaming method")
+	flag.Var(&cfg.headers, "H", "header of handshake like \"Authorization: Bearer xxx\", repeatable")
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintf(out, "usage: wsrpc [flags] URL [METHOD [PARAMS...]]\n\n")
+		fmt.Fprintf(out, "PARAMS are a JSON value, key=value pairs which are sent by name or values which are sent by\n")
+		fmt.Fprintf(out, "position. Values are parsed as JSON if possible. REPL is started if METHOD is not specified.\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg.url = flag.Arg(0)
+
+	if err := run(cfg, flag.Args()[1:]); err != nil {
+		if !errors.Is(err, errCallFailed) {
+			fmt.Fprintln(os.Stderr, "wsrpc:", err)
+		}
+
+		os.Exit(1)
+	}
+}
+
+func run(cfg *config, args []string) error {
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	header := http.Header{}
+	for _, h := range cfg.headers {
+		parts := strings.SplitN(h, ":", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid header %q", h)
+		}
+
+		header.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+	}
+
+	c, err := websocket_client.Dial(ctx, cfg.url, websocket_client.WithHeader(header))
+	if err != nil {
+		return err
+	}
+
+	defer c.Close()
+
+	if len(cfg.token) > 0 {
+		if err := authenticate(ctx, c, cfg); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case len(args) > 0:
+		if cfg.listen {
+			c.OnAny(func(method string, params jsoniter.RawMessage) {
+				printNotification(os.Stdout, method, params)
+			})
+		}
+
+		params, err := parseParams(cfg.params, args[1:])
+		if err != nil {
+			return err
+		}
+
+		if err := call(ctx, os.Stdout, c, cfg, args[0], params); err != nil {
+			return err
+		}
+
+		if !cfg.listen {
+			return nil
+		}
+	case cfg.listen:
+		c.OnAny(func(method string, params jsoniter.RawMessage) {
+			printNotification(os.Stdout, method, params)
+		})
+	default:
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return errors.New("METHOD is required if stdin is not a terminal")
+		}
+
+		return repl(ctx, c, cfg)
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-c.Done():
+		return c.Err()
+	}
+}
+
+func authenticate(ctx context.Context, c *websocket_client.Client, cfg *config) error {
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.timeout)
+	defer cancel()
+
+	var res struct {
+		Success bool `json:"success"`
+	}
+
+	if err := c.Call(ctx, cfg.authMethod, map[string]interface{}{"token": cfg.token}, &res); err != nil {
+		return fmt.Errorf("authentication: %w", err)
+	}
+
+	if !res.Success {
+		return errors.New("authentication failed")
+	}
+
+	return nil
+}
+
+func call(ctx context.Context, w io.Writer, c *websocket_client.Client, cfg *config, method string, params interface{}) error {
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.timeout)
+	defer cancel()
+
+	var onChunk websocket_client.ChunkHandler
+	if cfg.stream {
+		onChunk = func(data jsoniter.RawMessage, seq int64) error {
+			fmt.Fprintf(w, "chunk #%d: %s\n", seq, pretty(data))
+			return nil
+		}
+	}
+
+	var result jsoniter.RawMessage
+	err := c.CallStream(ctx, method, params, &result, onChunk)
+
+	var rpcErr *websocket_server.RPCError
+	switch {
+	case errors.As(err, &rpcErr):
+		fmt.Fprintf(w, "error %d: %s\n", rpcErr.Code, rpcErr.Message)
+		if rpcErr.Data != nil {
+			data, _ := json.Marshal(rpcErr.Data)
+			fmt.Fprintf(w, "%s\n", pretty(data))
+		}
+
+		return errCallFailed
+	case err != nil:
+		return err
+	}
+
+	fmt.Fprintf(w, "%s\n", pretty(result))
+
+	return nil
+}
+
+func printNotification(w io.Writer, method string, params jsoniter.RawMessage) {
+	fmt.Fprintf(w, "<- %s %s\n", method, pretty(params))
+}
+
+func pretty(data []byte) string {
+
+	if len(data) == 0 {
+		return "null"
+	}
+
+	// Numbers and order of keys are kept as they are
+	out, err := jsonfmt.Indent(data)
+	if err != nil {
+		return string(data)
+	}
+
+	return string(out)
+}
+
+// parseParams builds params from JSON or arguments, nil is returned if there is no params
+func parseParams(raw string, args []string) (interface{}, error) {
+
+	if raw == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+
+		raw = string(data)
+	}
+
+	if len(strings.TrimSpace(raw)) > 0 {
+		if len(args) > 0 {
+			return nil, errors.New("params are specified by both -params and arguments")
+		}
+
+		return parseJSON(raw)
+	}
+
+	return parseArgs(args)
+}
+
+func parseJSON(raw string) (interface{}, error) {
+
+	var params interface{}
+	if err := paramsJSON.Unmarshal([]byte(raw), &params); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+
+	return params, nil
+}
+
+func parseArgs(args []string) (interface{}, error) {
+
+	if len(args) == 0 {
+		return nil, nil
+	}
+
+	// Single JSON object or array is sent as it is
+	if len(args) == 1 && (strings.HasPrefix(args[0], "{") || strings.HasPrefix(args[0], "[")) {
+		return parseJSON(args[0])
+	}
+
+	named := make(map[string]interface{})
+	positional := make([]interface{}, 0)
+	for _, arg := range args {
+
+		if key, value, ok := strings.Cut(arg, "="); ok && len(key) > 0 {
+			named[key] = parseValue(value)
+			continue
+		}
+
+		positional = append(positional, parseValue(arg))
+	}
+
+	switch {
+	case len(named) > 0 && len(positional) > 0:
+		return nil, errors.New("params are unable to be given by both name and position")
+	case len(named) > 0:
+		return named, nil
+	}
+
+	return positional, nil
+}
+
+func parseValue(s string) interface{} {
+
+	var v interface{}
+	if err := paramsJSON.Unmarshal([]byte(s), &v); err == nil {
+		return v
+	}
+
+	return s
+}
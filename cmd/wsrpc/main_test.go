@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	stdjson "encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weedbox/websocket-modules/jsonrpc"
+	"github.com/weedbox/websocket-modules/websocket_server"
+	"github.com/weedbox/websocket-modules/websocket_servertest"
+)
+
+func TestParseArgs(t *testing.T) {
+
+	cases := []struct {
+		args     []string
+		expected interface{}
+	}{
+		{nil, nil},
+		{[]string{"a=3", `b="x y"`, "c=hi"}, map[string]interface{}{"a": stdjson.Number("3"), "b": "x y", "c": "hi"}},
+		{[]string{"5", "hi", "true"}, []interface{}{stdjson.Number("5"), "hi", true}},
+		{[]string{`{"b":1}`}, map[string]interface{}{"b": stdjson.Number("1")}},
+		{[]string{`[1,2.5]`}, []interface{}{stdjson.Number("1"), stdjson.Number("2.5")}},
+
+		// Large integers are not rounded
+		{[]string{`{"id":9007199254740993}`}, map[string]interface{}{"id": stdjson.Number("9007199254740993")}},
+		{[]string{"id=9007199254740993"}, map[string]interface{}{"id": stdjson.Number("9007199254740993")}},
+	}
+
+	for _, c := range cases {
+
+		params, err := parseArgs(c.args)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(params, c.expected) {
+			t.Fatalf("%v: unexpected params %#v", c.args, params)
+		}
+	}
+
+	// Numbers are sent as they were written
+	params, _ := parseParams(`{"id":9007199254740993}`, nil)
+	if data, err := json.Marshal(params); err != nil || string(data) != `{"id":9007199254740993}` {
+		t.Fatalf("unexpected encoding %s %v", data, err)
+	}
+
+	if _, err := parseArgs([]string{"a=1", "2"}); err == nil {
+		t.Fatal("params given by both name and position were accepted")
+	}
+
+	if _, err := parseParams(`{"a":1}`, []string{"b=2"}); err == nil {
+		t.Fatal("params given by both -params and arguments were accepted")
+	}
+}
+
+func TestPretty(t *testing.T) {
+
+	if s := pretty([]byte(`{"n":12345678901234567890,"a":[1]}`)); s != "{\n  \"n\": 12345678901234567890,\n  \"a\": [\n    1\n  ]\n}" {
+		t.Fatalf("unexpected output %s", s)
+	}
+
+	if s := pretty(nil); s != "null" {
+		t.Fatalf("unexpected output %s", s)
+	}
+
+	if s := pretty([]byte("not json")); s != "not json" {
+		t.Fatalf("unexpected output %s", s)
+	}
+}
+
+func TestCall(t *testing.T) {
+
+	s := websocket_servertest.NewRPCServer(websocket_server.WithRPCBackend(&jsonrpc.JSONRPC{}))
+	defer s.Close()
+
+	ra := s.GetRPCAdapter()
+	ra.Register("Auth.Authenticate", func(c *websocket_server.Context) (interface{}, error) {
+		token, _ := c.LookupParam(0, "token")
+		return map[string]interface{}{"success": token == "good"}, nil
+	})
+	ra.Register("Fail", func(c *websocket_server.Context) (interface{}, error) {
+		return nil, websocket_server.NewError(websocket_server.ErrorCode_InvalidParams, "bad")
+	})
+	ra.RegisterStream("Count", func(c *websocket_server.Context) (interface{}, error) {
+		c.GetStream().Send("a")
+		return 1, nil
+	})
+
+	c, err := s.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx := context.Background()
+	cfg := &config{
+		authMethod: "Auth.Authenticate",
+		timeout:    time.Second,
+		stream:     true,
+	}
+
+	cfg.token = "bad"
+	if err := authenticate(ctx, c, cfg); err == nil {
+		t.Fatal("bad token was accepted")
+	}
+
+	cfg.token = "good"
+	if err := authenticate(ctx, c, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := call(ctx, &out, c, cfg, "Count", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.String() != "chunk #1: \"a\"\n1\n" {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+
+	out.Reset()
+	if err := call(ctx, &out, c, cfg, "Fail", nil); !errors.Is(err, errCallFailed) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if !strings.HasPrefix(out.String(), "error 3000: Invalid params\n\"bad\"") {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/weedbox/websocket-modules/websocket_client"
+	"golang.org/x/term"
+)
+
+const replHelp = `Commands:
+  METHOD [PARAMS...]   call method, params are given like command-line arguments
+  .methods             list methods which are reported by System.ListMethods
+  .stream on|off       print partial results of streaming methods
+  .help                show this help
+  .exit                quit, Ctrl-D works as well
+`
+
+type session struct {
+	ctx     context.Context
+	client  *websocket_client.Client
+	cfg     *config
+	term    *term.Terminal
+	methods []string
+}
+
+func repl(ctx context.Context, c *websocket_client.Client, cfg *config) error {
+
+	state, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return err
+	}
+
+	defer term.Restore(int(os.Stdin.Fd()), state)
+
+	s := &session{
+		ctx:    ctx,
+		client: c,
+		cfg:    cfg,
+		term: term.NewTerminal(struct {
+			io.Reader
+			io.Writer
+		}{os.Stdin, os.Stdout}, "wsrpc> "),
+	}
+
+	s.term.AutoCompleteCallback = s.complete
+
+	// Terminal redraws prompt after notifications which arrive while typing
+	c.OnAny(func(method string, params jsoniter.RawMessage) {
+		printNotification(s.term, method, params)
+	})
+
+	s.loadMethods()
+
+	go func() {
+		select {
+		case <-c.Done():
+			if !errors.Is(c.Err(), websocket_client.ErrClientClosed) {
+				fmt.Fprintf(s.term, "connection closed: %v\n", c.Err())
+			}
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		line, err := s.term.ReadLine()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		err = s.execute(strings.TrimSpace(line))
+		switch {
+		case errors.Is(err, io.EOF):
+			return nil
+		case err != nil && !errors.Is(err, errCallFailed):
+			fmt.Fprintf(s.term, "%v\n", err)
+		}
+	}
+}
+
+func (s *session) execute(line string) error {
+
+	if len(line) == 0 {
+		return nil
+	}
+
+	fields := strings.Fields(line)
+
+	switch fields[0] {
+	case ".exit", ".quit":
+		return io.EOF
+	case ".help":
+		fmt.Fprint(s.term, replHelp)
+		return nil
+	case ".methods":
+		s.loadMethods()
+		for _, m := range s.methods {
+			fmt.Fprintf(s.term, "  %s\n", m)
+		}
+
+		return nil
+	case ".stream":
+		s.cfg.stream = len(fields) < 2 || fields[1] != "off"
+		return nil
+	}
+
+	if strings.HasPrefix(fields[0], ".") {
+		return fmt.Errorf("unknown command %s, try .help", fields[0])
+	}
+
+	method, rest, _ := strings.Cut(line, " ")
+
+	params, err := parseArgs(splitArgs(strings.TrimSpace(rest)))
+	if err != nil {
+		return err
+	}
+
+	return call(s.ctx, s.term, s.client, s.cfg, method, params)
+}
+
+func (s *session) loadMethods() {
+
+	ctx, cancel := context.WithTimeout(s.ctx, s.cfg.timeout)
+	defer cancel()
+
+	var methods []struct {
+		Name string `json:"name"`
+	}
+
+	// Completion is unavailable if server does not support introspection
+	if err := s.client.Call(ctx, "System.ListMethods", nil, &methods); err != nil {
+		return
+	}
+
+	s.methods = s.methods[:0]
+	for _, m := range methods {
+		s.methods = append(s.methods, m.Name)
+	}
+
+	sort.Strings(s.methods)
+}
+
+// complete fills method name by the longest common prefix of candidates when tab is pressed
+func (s *session) complete(line string, pos int, key rune) (string, int, bool) {
+
+	if key != '\t' || pos != len(line) || strings.Contains(line, " ") {
+		return "", 0, false
+	}
+
+	candidates := make([]string, 0)
+	for _, m := range s.methods {
+		if strings.HasPrefix(m, line) {
+			candidates = append(candidates, m)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return "", 0, false
+	}
+
+	prefix := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+
+	if len(candidates) == 1 {
+		prefix += " "
+	}
+
+	return prefix, len(prefix), true
+}
+
+// splitArgs splits line by spaces, JSON values and quoted strings are kept as a single argument
+func splitArgs(line string) []string {
+
+	args := make([]string, 0)
+
+	var cur strings.Builder
+	depth := 0
+	quoted := false
+	escaped := false
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case quoted:
+		case r == '{' || r == '[':
+			depth++
+		case r == '}' || r == ']':
+			depth--
+		case r == ' ' && depth == 0:
+			if cur.Len() > 0 {
+				args = append(args, cur.String())
+				cur.Reset()
+			}
+
+			continue
+		}
+
+		cur.WriteRune(r)
+	}
+
+	if cur.Len() > 0 {
+		args = append(args, cur.String())
+	}
+
+	return args
+}
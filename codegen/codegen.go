@@ -0,0 +1,188 @@
+package codegen
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+var (
+	ErrInvalidDocument = errors.New("codegen: invalid OpenRPC document")
+)
+
+// Load reads OpenRPC document from URL which is served next to endpoint, file or stdin if source is "-"
+func Load(source string) (*websocket_server.OpenRPCDocument, error) {
+
+	var r io.Reader
+	switch {
+	case source == "-":
+		r = os.Stdin
+	case strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://"):
+		res, err := http.Get(source)
+		if err != nil {
+			return nil, err
+		}
+
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("codegen: unexpected status %s", res.Status)
+		}
+
+		r = res.Body
+	default:
+		f, err := os.Open(source)
+		if err != nil {
+			return nil, err
+		}
+
+		defer f.Close()
+
+		r = f
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(data)
+}
+
+func Parse(data []byte) (*websocket_server.OpenRPCDocument, error) {
+
+	var doc websocket_server.OpenRPCDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	if len(doc.OpenRPC) == 0 || !strings.HasPrefix(doc.OpenRPC, "1.") {
+		return nil, ErrInvalidDocument
+	}
+
+	return &doc, nil
+}
+
+// ErrorCode is an entry of error code enum, name is derived from message
+type ErrorCode struct {
+	Name    string
+	Code    int64
+	Message string
+}
+
+// GetErrorCodes returns error codes of document in order of RPCErrorCode
+func GetErrorCodes(doc *websocket_server.OpenRPCDocument) []*ErrorCode {
+
+	if doc.Components == nil {
+		return nil
+	}
+
+	type entry struct {
+		key int64
+		e   *websocket_server.OpenRPCError
+	}
+
+	entries := make([]entry, 0, len(doc.Components.Errors))
+	for key, e := range doc.Components.Errors {
+		k, _ := strconv.ParseInt(key, 10, 64)
+		entries = append(entries, entry{key: k, e: e})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+
+	used := make(map[string]int)
+	codes := make([]*ErrorCode, 0, len(entries))
+	for _, en := range entries {
+
+		name := PascalCase(en.e.Message)
+		if len(name) == 0 {
+			name = fmt.Sprintf("Error%d", en.key)
+		}
+
+		// Messages are not guaranteed to be unique
+		used[name]++
+		if used[name] > 1 {
+			name = fmt.Sprintf("%s%d", name, used[name])
+		}
+
+		codes = append(codes, &ErrorCode{
+			Name:    name,
+			Code:    en.e.Code,
+			Message: en.e.Message,
+		})
+	}
+
+	return codes
+}
+
+func splitWords(s string) []string {
+
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	return words
+}
+
+// PascalCase converts name like "System.ListMethods" into "SystemListMethods"
+func PascalCase(s string) string {
+
+	var b strings.Builder
+	for _, w := range splitWords(s) {
+		runes := []rune(w)
+		b.WriteRune(unicode.ToUpper(runes[0]))
+		b.WriteString(string(runes[1:]))
+	}
+
+	name := b.String()
+	if len(name) > 0 && unicode.IsDigit(rune(name[0])) {
+		return "_" + name
+	}
+
+	return name
+}
+
+// CamelCase converts name like "System.ListMethods" into "systemListMethods"
+func CamelCase(s string) string {
+
+	name := PascalCase(s)
+	if len(name) == 0 || name[0] == '_' {
+		return name
+	}
+
+	runes := []rune(name)
+
+	// Leading acronym is lowered entirely, like "RPCCall" into "rpcCall"
+	i := 0
+	for i < len(runes) && unicode.IsUpper(runes[i]) {
+		i++
+	}
+
+	if i > 1 && i < len(runes) {
+		i--
+	}
+
+	for j := 0; j < i; j++ {
+		runes[j] = unicode.ToLower(runes[j])
+	}
+
+	return string(runes)
+}
+
+// IsControlMethod reports whether method is used by protocol itself, such as "$/cancelRequest"
+func IsControlMethod(name string) bool {
+	return strings.HasPrefix(name, "$/") || name == websocket_server.DiscoverMethod
+}
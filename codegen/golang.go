@@ -0,0 +1,489 @@
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const serverPackage = "github.com/weedbox/websocket-modules/websocket_server"
+
+const clientPackage = "github.com/weedbox/websocket-modules/websocket_client"
+
+// TypedMethod is a method which was registered by websocket_server.RegisterTyped
+type TypedMethod struct {
+	Name        string
+	Package     string
+	Params      types.Type
+	Result      types.Type
+	Description string
+	Deprecated  bool
+	Position    token.Position
+}
+
+type listedPackage struct {
+	ImportPath string
+	Dir        string
+	GoFiles    []string
+}
+
+// ScanPackages type-checks packages matched by patterns in dir and finds typed handler registrations.
+// Registrations whose method name is not a constant are skipped.
+func ScanPackages(dir string, patterns ...string) ([]*TypedMethod, error) {
+
+	pkgs, err := listPackages(dir, patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	imp := importer.ForCompiler(fset, "source", nil)
+
+	methods := make(map[string]*TypedMethod)
+	for _, lp := range pkgs {
+
+		files := make([]*ast.File, 0, len(lp.GoFiles))
+		for _, name := range lp.GoFiles {
+			f, err := parser.ParseFile(fset, filepath.Join(lp.Dir, name), nil, 0)
+			if err != nil {
+				return nil, err
+			}
+
+			files = append(files, f)
+		}
+
+		info := &types.Info{
+			Types:     make(map[ast.Expr]types.TypeAndValue),
+			Uses:      make(map[*ast.Ident]types.Object),
+			Instances: make(map[*ast.Ident]types.Instance),
+		}
+
+		conf := types.Config{Importer: imp}
+		if _, err := conf.Check(lp.ImportPath, fset, files, info); err != nil {
+			return nil, err
+		}
+
+		for _, f := range files {
+			ast.Inspect(f, func(n ast.Node) bool {
+
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+
+				if m := typedMethod(fset, info, call); m != nil {
+					m.Package = lp.ImportPath
+					if _, ok := methods[m.Name]; !ok {
+						methods[m.Name] = m
+					}
+				}
+
+				return true
+			})
+		}
+	}
+
+	list := make([]*TypedMethod, 0, len(methods))
+	for _, m := range methods {
+		list = append(list, m)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	return list, nil
+}
+
+func listPackages(dir string, patterns []string) ([]*listedPackage, error) {
+
+	args := append([]string{"list", "-json"}, patterns...)
+
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("codegen: go list: %s", strings.TrimSpace(stderr.String()))
+	}
+
+	pkgs := make([]*listedPackage, 0)
+
+	// Output is a stream of JSON objects
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for dec.More() {
+		var lp listedPackage
+		if err := dec.Decode(&lp); err != nil {
+			return nil, err
+		}
+
+		pkgs = append(pkgs, &lp)
+	}
+
+	return pkgs, nil
+}
+
+// calleeIdent returns identifier of function which is called, type arguments are unwrapped
+func calleeIdent(expr ast.Expr) *ast.Ident {
+
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return e
+	case *ast.SelectorExpr:
+		return e.Sel
+	case *ast.IndexExpr:
+		return calleeIdent(e.X)
+	case *ast.IndexListExpr:
+		return calleeIdent(e.X)
+	}
+
+	return nil
+}
+
+func isServerFunc(info *types.Info, ident *ast.Ident, name string) bool {
+
+	if ident == nil || ident.Name != name {
+		return false
+	}
+
+	fn, ok := info.Uses[ident].(*types.Func)
+
+	return ok && fn.Pkg() != nil && fn.Pkg().Path() == serverPackage
+}
+
+func constantString(info *types.Info, expr ast.Expr) (string, bool) {
+
+	tv, ok := info.Types[expr]
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+		return "", false
+	}
+
+	return constant.StringVal(tv.Value), true
+}
+
+func typedMethod(fset *token.FileSet, info *types.Info, call *ast.CallExpr) *TypedMethod {
+
+	ident := calleeIdent(call.Fun)
+	if !isServerFunc(info, ident, "RegisterTyped") || len(call.Args) < 3 {
+		return nil
+	}
+
+	inst, ok := info.Instances[ident]
+	if !ok || inst.TypeArgs.Len() != 2 {
+		return nil
+	}
+
+	name, ok := constantString(info, call.Args[1])
+	if !ok {
+		return nil
+	}
+
+	m := &TypedMethod{
+		Name:     name,
+		Params:   inst.TypeArgs.At(0),
+		Result:   inst.TypeArgs.At(1),
+		Position: fset.Position(call.Pos()),
+	}
+
+	// Metadata options are recognized if they are given inline
+	for _, arg := range call.Args[3:] {
+
+		opt, ok := arg.(*ast.CallExpr)
+		if !ok {
+			continue
+		}
+
+		optIdent := calleeIdent(opt.Fun)
+		switch {
+		case isServerFunc(info, optIdent, "WithDescription") && len(opt.Args) == 1:
+			m.Description, _ = constantString(info, opt.Args[0])
+		case isServerFunc(info, optIdent, "WithDeprecated"):
+			m.Deprecated = true
+		}
+	}
+
+	return m
+}
+
+type GoOpt func(*goGenerator)
+
+func WithPackageName(name string) GoOpt {
+	return func(g *goGenerator) {
+		g.packageName = name
+	}
+}
+
+func WithTypeName(name string) GoOpt {
+	return func(g *goGenerator) {
+		g.typeName = name
+	}
+}
+
+type goGenerator struct {
+	packageName string
+	typeName    string
+
+	// Types of scanned packages are declared again, others are imported
+	local    map[string]bool
+	declared map[string]*types.Named
+	order    []*types.Named
+	imports  map[string]string
+}
+
+// GenerateGo creates client stubs which call methods with the same param and result types. Named types
+// of packages which registered methods are copied, others are imported.
+func GenerateGo(methods []*TypedMethod, opts ...GoOpt) ([]byte, error) {
+
+	g := &goGenerator{
+		packageName: "client",
+		typeName:    "Client",
+		local:       make(map[string]bool),
+		declared:    make(map[string]*types.Named),
+		imports:     make(map[string]string),
+	}
+
+	for _, o := range opts {
+		o(g)
+	}
+
+	for _, m := range methods {
+		g.local[m.Package] = true
+	}
+
+	for _, m := range methods {
+		for _, t := range []types.Type{m.Params, m.Result} {
+			if err := g.collect(t); err != nil {
+				return nil, fmt.Errorf("codegen: %s: %w", m.Position, err)
+			}
+		}
+	}
+
+	var body bytes.Buffer
+
+	for _, named := range g.order {
+		g.declare(&body, named)
+	}
+
+	fmt.Fprintf(&body, "\ntype %s struct {\n\tclient *websocket_client.Client\n}\n", g.typeName)
+	fmt.Fprintf(&body, "\nfunc New%s(c *websocket_client.Client) *%s {\n\treturn &%s{client: c}\n}\n", g.typeName, g.typeName, g.typeName)
+
+	used := make(map[string]bool)
+	for _, m := range methods {
+
+		name := PascalCase(m.Name)
+		for used[name] || len(name) == 0 {
+			name += "_"
+		}
+
+		used[name] = true
+
+		body.WriteString("\n")
+		if len(m.Description) > 0 {
+			fmt.Fprintf(&body, "// %s %s\n", name, sentence(m.Description))
+		}
+
+		if m.Deprecated {
+			if len(m.Description) > 0 {
+				body.WriteString("//\n")
+			}
+
+			fmt.Fprintf(&body, "// Deprecated: %s is deprecated by server.\n", m.Name)
+		}
+
+		params := g.typeString(m.Params)
+		result := g.typeString(m.Result)
+
+		fmt.Fprintf(&body, "func (c *%s) %s(ctx context.Context, params %s) (%s, error) {\n", g.typeName, name, params, result)
+		fmt.Fprintf(&body, "\tvar result %s\n", result)
+		fmt.Fprintf(&body, "\terr := c.client.Call(ctx, %q, params, &result)\n", m.Name)
+		fmt.Fprintf(&body, "\treturn result, err\n}\n")
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by wsrpc-gogen. DO NOT EDIT.\n\npackage %s\n\nimport (\n\t\"context\"\n\n", g.packageName)
+
+	paths := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		if alias := g.imports[path]; alias != filepath.Base(path) {
+			fmt.Fprintf(&out, "\t%s %q\n", alias, path)
+			continue
+		}
+
+		fmt.Fprintf(&out, "\t%q\n", path)
+	}
+
+	fmt.Fprintf(&out, "\t%q\n)\n", clientPackage)
+	out.Write(body.Bytes())
+
+	return format.Source(out.Bytes())
+}
+
+// isLocal reports whether types of package should be copied, packages which registered methods and
+// internal packages are unable to be imported by callers
+func (g *goGenerator) isLocal(pkg *types.Package) bool {
+	return g.local[pkg.Path()] || strings.Contains(pkg.Path()+"/", "/internal/")
+}
+
+func (g *goGenerator) collect(t types.Type) error {
+
+	switch v := t.(type) {
+	case *types.Pointer:
+		return g.collect(v.Elem())
+	case *types.Slice:
+		return g.collect(v.Elem())
+	case *types.Array:
+		return g.collect(v.Elem())
+	case *types.Map:
+		if err := g.collect(v.Key()); err != nil {
+			return err
+		}
+
+		return g.collect(v.Elem())
+	case *types.Chan:
+		return fmt.Errorf("channel type %s is not supported", v)
+	case *types.Signature:
+		return fmt.Errorf("function type %s is not supported", v)
+	case *types.Struct:
+		for i := 0; i < v.NumFields(); i++ {
+			if err := g.collect(v.Field(i).Type()); err != nil {
+				return err
+			}
+		}
+	case *types.Named:
+
+		pkg := v.Obj().Pkg()
+		if pkg == nil || !g.isLocal(pkg) {
+			return nil
+		}
+
+		if v.TypeArgs().Len() > 0 || v.TypeParams().Len() > 0 {
+			return fmt.Errorf("generic type %s is not supported", v)
+		}
+
+		name := v.Obj().Name()
+		if prev, ok := g.declared[name]; ok {
+			if prev.Obj().Pkg().Path() != pkg.Path() {
+				return fmt.Errorf("type name %s is declared in both %s and %s", name, prev.Obj().Pkg().Path(), pkg.Path())
+			}
+
+			return nil
+		}
+
+		g.declared[name] = v
+		g.order = append(g.order, v)
+
+		return g.collect(v.Underlying())
+	}
+
+	return nil
+}
+
+func (g *goGenerator) qualifier(pkg *types.Package) string {
+
+	if g.isLocal(pkg) {
+		return ""
+	}
+
+	if alias, ok := g.imports[pkg.Path()]; ok {
+		return alias
+	}
+
+	// Package names may conflict with each other or with imports of generated file
+	alias := pkg.Name()
+	for i := 2; g.aliasInUse(alias); i++ {
+		alias = fmt.Sprintf("%s%d", pkg.Name(), i)
+	}
+
+	g.imports[pkg.Path()] = alias
+
+	return alias
+}
+
+func (g *goGenerator) aliasInUse(alias string) bool {
+
+	if alias == "context" || alias == "websocket_client" {
+		return true
+	}
+
+	for _, a := range g.imports {
+		if a == alias {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (g *goGenerator) typeString(t types.Type) string {
+	return types.TypeString(t, g.qualifier)
+}
+
+func (g *goGenerator) declare(w *bytes.Buffer, named *types.Named) {
+
+	st, ok := named.Underlying().(*types.Struct)
+	if !ok {
+		fmt.Fprintf(w, "\ntype %s %s\n", named.Obj().Name(), g.typeString(named.Underlying()))
+		return
+	}
+
+	fmt.Fprintf(w, "\ntype %s struct {\n", named.Obj().Name())
+	for i := 0; i < st.NumFields(); i++ {
+
+		f := st.Field(i)
+
+		// Unexported fields are never encoded
+		if !f.Exported() {
+			continue
+		}
+
+		if f.Embedded() {
+			fmt.Fprintf(w, "\t%s", g.typeString(f.Type()))
+		} else {
+			fmt.Fprintf(w, "\t%s %s", f.Name(), g.typeString(f.Type()))
+		}
+
+		if tag := st.Tag(i); len(tag) > 0 {
+			if strings.Contains(tag, "`") {
+				fmt.Fprintf(w, " %s", strconv.Quote(tag))
+			} else {
+				fmt.Fprintf(w, " `%s`", tag)
+			}
+		}
+
+		w.WriteString("\n")
+	}
+
+	w.WriteString("}\n")
+}
+
+// sentence lowers the first letter of description so it follows the name in doc comment
+func sentence(desc string) string {
+
+	runes := []rune(desc)
+	if len(runes) > 1 && unicode.IsUpper(runes[0]) && unicode.IsLower(runes[1]) {
+		runes[0] = unicode.ToLower(runes[0])
+	}
+
+	return string(runes)
+}
@@ -0,0 +1,76 @@
+package codegen_test
+
+import (
+	"go/types"
+	"strings"
+	"testing"
+
+	"github.com/weedbox/websocket-modules/codegen"
+)
+
+func TestGenerateGo(t *testing.T) {
+
+	// Dependencies are type-checked from source
+	if testing.Short() {
+		t.Skip("scanning packages is slow")
+	}
+
+	methods, err := codegen.ScanPackages(".", "./testdata/service")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(methods) != 2 || methods[0].Name != "Game.Move" || methods[1].Name != "Game.Span" {
+		t.Fatalf("unexpected methods %v", methods)
+	}
+
+	if methods[0].Description != "Moves point" || !methods[1].Deprecated {
+		t.Fatalf("unexpected metadata %+v %+v", methods[0], methods[1])
+	}
+
+	code, err := codegen.GenerateGo(methods, codegen.WithPackageName("game"), codegen.WithTypeName("GameClient"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := string(code)
+
+	expected := []string{
+		"package game",
+		`"time"`,
+		"type Point struct {",
+		"Start  time.Time `json:\"start\"`",
+		"func NewGameClient(c *websocket_client.Client) *GameClient {",
+		"// GameMove moves point",
+		"func (c *GameClient) GameMove(ctx context.Context, params *Point) (*Point, error) {",
+		`err := c.client.Call(ctx, "Game.Move", params, &result)`,
+		"// Deprecated: Game.Span is deprecated by server.",
+		"func (c *GameClient) GameSpan(ctx context.Context, params *Span) ([]Point, error) {",
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(src, e) {
+			t.Fatalf("generated code does not contain %q:\n%s", e, src)
+		}
+	}
+
+	// Unexported fields are never encoded
+	if strings.Contains(src, "hidden") || strings.Contains(src, "Dynamic") {
+		t.Fatalf("unexpected generated code:\n%s", src)
+	}
+}
+
+func TestGenerateGoUnsupportedType(t *testing.T) {
+
+	methods := []*codegen.TypedMethod{
+		{
+			Name:   "Watch",
+			Params: types.NewChan(types.SendRecv, types.Typ[types.Int]),
+			Result: types.Typ[types.Bool],
+		},
+	}
+
+	if _, err := codegen.GenerateGo(methods); err == nil {
+		t.Fatal("channel type was accepted")
+	}
+}
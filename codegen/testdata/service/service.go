@@ -0,0 +1,37 @@
+package service
+
+import (
+	"time"
+
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+const MoveMethod = "Game.Move"
+
+type Point struct {
+	X      int `json:"x" validate:"required"`
+	Y      int `json:"y"`
+	hidden int
+}
+
+type Span struct {
+	Start  time.Time `json:"start"`
+	Points []Point   `json:"points"`
+}
+
+func Register(ra *websocket_server.RPCAdapter) {
+
+	websocket_server.RegisterTyped(ra, MoveMethod, func(c *websocket_server.Context, p *Point) (*Point, error) {
+		return p, nil
+	}, websocket_server.WithDescription("Moves point"))
+
+	websocket_server.RegisterTyped(ra, "Game.Span", func(c *websocket_server.Context, s *Span) ([]Point, error) {
+		return s.Points, nil
+	}, websocket_server.WithDeprecated())
+
+	// Name which is not constant is unable to be generated
+	name := "Game.Dynamic"
+	websocket_server.RegisterTyped(ra, name, func(c *websocket_server.Context, p *Point) (bool, error) {
+		return true, nil
+	})
+}
@@ -0,0 +1,522 @@
+package codegen
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+var tsIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// Members of runtime client which should not be shadowed by generated methods
+var tsReserved = map[string]bool{
+	"call":        true,
+	"notify":      true,
+	"on":          true,
+	"handle":      true,
+	"close":       true,
+	"constructor": true,
+	"send":        true,
+	"receive":     true,
+	"dispatch":    true,
+	"answer":      true,
+	"ws":          true,
+	"seq":         true,
+	"pending":     true,
+	"handlers":    true,
+	"requests":    true,
+	"ready":       true,
+}
+
+type TypeScriptOpt func(*typeScriptGenerator)
+
+func WithClassName(name string) TypeScriptOpt {
+	return func(g *typeScriptGenerator) {
+		g.className = name
+	}
+}
+
+type typeScriptGenerator struct {
+	doc       *websocket_server.OpenRPCDocument
+	className string
+	b         strings.Builder
+}
+
+// GenerateTypeScript creates TypeScript client with typed methods, event subscriptions and error codes.
+// The client speaks JSON-RPC 2.0 over WebSocket without dependencies.
+func GenerateTypeScript(doc *websocket_server.OpenRPCDocument, opts ...TypeScriptOpt) ([]byte, error) {
+
+	g := &typeScriptGenerator{
+		doc:       doc,
+		className: "Client",
+	}
+
+	for _, o := range opts {
+		o(g)
+	}
+
+	if !tsIdentifier.MatchString(g.className) {
+		return nil, fmt.Errorf("codegen: invalid class name %q", g.className)
+	}
+
+	g.printf("// Code generated by wsrpc-tsgen. DO NOT EDIT.\n")
+	if doc.Info != nil {
+		g.printf("// %s %s\n", doc.Info.Title, doc.Info.Version)
+	}
+
+	g.printf("\n")
+	g.errorCodes()
+	g.b.WriteString(tsRuntime)
+	g.types()
+	g.client()
+
+	return []byte(g.b.String()), nil
+}
+
+func (g *typeScriptGenerator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.b, format, args...)
+}
+
+func (g *typeScriptGenerator) errorCodes() {
+
+	g.printf("export enum RPCErrorCode {\n")
+	for _, e := range GetErrorCodes(g.doc) {
+		g.printf("  %s = %d,\n", e.Name, e.Code)
+	}
+	g.printf("}\n")
+}
+
+func (g *typeScriptGenerator) methods() []*websocket_server.OpenRPCMethod {
+
+	methods := make([]*websocket_server.OpenRPCMethod, 0, len(g.doc.Methods))
+	for _, m := range g.doc.Methods {
+		if !IsControlMethod(m.Name) {
+			methods = append(methods, m)
+		}
+	}
+
+	sort.Slice(methods, func(i, j int) bool {
+		return methods[i].Name < methods[j].Name
+	})
+
+	return methods
+}
+
+func (g *typeScriptGenerator) types() {
+
+	for _, m := range g.methods() {
+
+		name := PascalCase(m.Name)
+
+		if params := paramsSchema(m); params != nil {
+			g.comment("", m.Description)
+			g.declare(name+"Params", params)
+		}
+
+		if m.Result != nil {
+			g.declare(name+"Result", m.Result.Schema)
+		}
+	}
+
+	for _, e := range g.doc.Events {
+		if params := paramsSchema(e); params != nil {
+			g.comment("", e.Description)
+			g.declare(PascalCase(e.Name)+"Event", params)
+		}
+	}
+}
+
+// declare emits interface for objects and type alias for others
+func (g *typeScriptGenerator) declare(name string, s *websocket_server.Schema) {
+
+	if s != nil && s.Type == "object" && len(s.Properties) > 0 {
+		g.printf("\nexport interface %s %s\n", name, tsType(s, ""))
+		return
+	}
+
+	g.printf("\nexport type %s = %s;\n", name, tsType(s, ""))
+}
+
+func (g *typeScriptGenerator) comment(indent string, text string) {
+
+	if len(text) == 0 {
+		return
+	}
+
+	g.printf("\n%s/** %s */", indent, strings.ReplaceAll(text, "*/", "* /"))
+}
+
+func (g *typeScriptGenerator) client() {
+
+	g.printf("\nexport class %s extends RPCClient {\n", g.className)
+
+	used := make(map[string]bool)
+	for _, m := range g.methods() {
+
+		name := memberName(CamelCase(m.Name), used)
+		typeName := PascalCase(m.Name)
+		params := paramsSchema(m)
+
+		if len(m.Description) > 0 || m.Deprecated {
+			g.printf("\n  /**\n")
+			if len(m.Description) > 0 {
+				g.printf("   * %s\n", strings.ReplaceAll(m.Description, "*/", "* /"))
+			}
+			if m.Deprecated {
+				g.printf("   * @deprecated\n")
+			}
+			g.printf("   */\n")
+		} else {
+			g.printf("\n")
+		}
+
+		args := make([]string, 0, 2)
+		value := "undefined"
+		if params != nil {
+
+			arg := "params: " + typeName + "Params"
+			if optionalParams(params) {
+				arg += " = {}"
+			}
+
+			args = append(args, arg)
+			value = "params"
+
+			// Single param which is not an array is given by position
+			if m.ParamStructure == "by-position" && params.Type != "array" {
+				value = "[params]"
+			}
+		}
+
+		// Methods without result are notifications
+		if m.Result == nil {
+			g.printf("  %s(%s): void {\n", name, strings.Join(args, ", "))
+			g.printf("    this.notify(%q, %s);\n", m.Name, value)
+			g.printf("  }\n")
+			continue
+		}
+
+		args = append(args, "options?: CallOptions")
+		g.printf("  %s(%s): Promise<%sResult> {\n", name, strings.Join(args, ", "), typeName)
+		g.printf("    return this.call<%sResult>(%q, %s, options);\n", typeName, m.Name, value)
+		g.printf("  }\n")
+	}
+
+	for _, e := range g.doc.Events {
+
+		name := memberName("on"+PascalCase(e.Name), used)
+
+		payload := "unknown"
+		if paramsSchema(e) != nil {
+			payload = PascalCase(e.Name) + "Event"
+		}
+
+		g.comment("  ", e.Description)
+		g.printf("\n  %s(handler: (payload: %s) => void): () => void {\n", name, payload)
+		g.printf("    return this.on(%q, handler as (payload: unknown) => void);\n", e.Name)
+		g.printf("  }\n")
+	}
+
+	g.printf("}\n")
+}
+
+func memberName(name string, used map[string]bool) string {
+
+	for tsReserved[name] || used[name] {
+		name += "_"
+	}
+
+	used[name] = true
+
+	return name
+}
+
+// paramsSchema combines params of method into a single schema, nil if method has no params
+func paramsSchema(m *websocket_server.OpenRPCMethod) *websocket_server.Schema {
+
+	if len(m.Params) == 0 {
+		return nil
+	}
+
+	if m.ParamStructure == "by-position" && len(m.Params) == 1 {
+		return m.Params[0].Schema
+	}
+
+	s := &websocket_server.Schema{
+		Type:       "object",
+		Properties: make(map[string]*websocket_server.Schema),
+	}
+
+	for _, p := range m.Params {
+
+		ps := *p.Schema
+		if len(ps.Description) == 0 {
+			ps.Description = p.Description
+		}
+
+		s.Properties[p.Name] = &ps
+		if p.Required {
+			s.Required = append(s.Required, p.Name)
+		}
+	}
+
+	return s
+}
+
+func optionalParams(s *websocket_server.Schema) bool {
+	return s.Type == "object" && len(s.Required) == 0
+}
+
+func tsType(s *websocket_server.Schema, indent string) string {
+
+	if s == nil {
+		return "unknown"
+	}
+
+	if len(s.Enum) > 0 {
+		values := make([]string, 0, len(s.Enum))
+		for _, v := range s.Enum {
+			data, _ := json.Marshal(v)
+			values = append(values, string(data))
+		}
+
+		return strings.Join(values, " | ")
+	}
+
+	switch s.Type {
+	case "string":
+		return "string"
+	case "integer", "number":
+		return "number"
+	case "boolean":
+		return "boolean"
+	case "array":
+		return "Array<" + tsType(s.Items, indent) + ">"
+	case "object":
+
+		if len(s.Properties) == 0 {
+			if s.AdditionalProperties != nil {
+				return "Record<string, " + tsType(s.AdditionalProperties, indent) + ">"
+			}
+
+			return "Record<string, unknown>"
+		}
+
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		required := make(map[string]bool, len(s.Required))
+		for _, name := range s.Required {
+			required[name] = true
+		}
+
+		var b strings.Builder
+		b.WriteString("{\n")
+		for _, name := range names {
+
+			ps := s.Properties[name]
+			if len(ps.Description) > 0 {
+				fmt.Fprintf(&b, "%s  /** %s */\n", indent, strings.ReplaceAll(ps.Description, "*/", "* /"))
+			}
+
+			key := name
+			if !tsIdentifier.MatchString(key) {
+				data, _ := json.Marshal(name)
+				key = string(data)
+			}
+
+			if !required[name] {
+				key += "?"
+			}
+
+			fmt.Fprintf(&b, "%s  %s: %s;\n", indent, key, tsType(ps, indent+"  "))
+		}
+
+		b.WriteString(indent + "}")
+
+		return b.String()
+	}
+
+	return "unknown"
+}
+
+const tsRuntime = `
+export class RPCError extends Error {
+  constructor(public code: number, message: string, public data?: unknown) {
+    super(message);
+    this.name = "RPCError";
+  }
+}
+
+export interface CallOptions {
+  /** Cancels request by $/cancelRequest */
+  signal?: AbortSignal;
+
+  /** Receives partial results of streaming methods */
+  onChunk?: (data: unknown, seq: number) => void;
+}
+
+/** Answers calls which are initiated by server, RPCError is replied as it is */
+export type RequestHandler = (params: unknown) => unknown | Promise<unknown>;
+
+interface Pending {
+  resolve: (value: unknown) => void;
+  reject: (reason: unknown) => void;
+  onChunk?: (data: unknown, seq: number) => void;
+}
+
+export class RPCClient {
+  private ws: WebSocket;
+  private seq = 0;
+  private pending = new Map<number, Pending>();
+  private handlers = new Map<string, Set<(payload: unknown) => void>>();
+  private requests = new Map<string, RequestHandler>();
+  private ready: Promise<void>;
+
+  constructor(target: string | WebSocket) {
+    this.ws = typeof target === "string" ? new WebSocket(target) : target;
+    this.ready = new Promise((resolve, reject) => {
+      if (this.ws.readyState === WebSocket.OPEN) {
+        resolve();
+        return;
+      }
+      this.ws.addEventListener("open", () => resolve(), { once: true });
+      this.ws.addEventListener("error", () => reject(new Error("connection failed")), { once: true });
+    });
+    this.ws.addEventListener("message", (ev: MessageEvent) => this.receive(ev.data));
+    this.ws.addEventListener("close", () => {
+      for (const p of this.pending.values()) {
+        p.reject(new Error("connection closed"));
+      }
+      this.pending.clear();
+    });
+  }
+
+  async call<T>(method: string, params?: unknown, options?: CallOptions): Promise<T> {
+    await this.ready;
+
+    const id = ++this.seq;
+    return new Promise<T>((resolve, reject) => {
+      const signal = options?.signal;
+      if (signal?.aborted) {
+        reject(new Error("request aborted"));
+        return;
+      }
+
+      const abort = () => this.send({ jsonrpc: "2.0", method: "$/cancelRequest", params: { id } });
+      signal?.addEventListener("abort", abort, { once: true });
+
+      this.pending.set(id, {
+        resolve: (value) => {
+          signal?.removeEventListener("abort", abort);
+          resolve(value as T);
+        },
+        reject: (reason) => {
+          signal?.removeEventListener("abort", abort);
+          reject(reason);
+        },
+        onChunk: options?.onChunk,
+      });
+
+      this.send({ jsonrpc: "2.0", id, method, params });
+    });
+  }
+
+  notify(method: string, params?: unknown): void {
+    this.ready.then(() => this.send({ jsonrpc: "2.0", method, params }));
+  }
+
+  on(event: string, handler: (payload: unknown) => void): () => void {
+    let set = this.handlers.get(event);
+    if (!set) {
+      set = new Set();
+      this.handlers.set(event, set);
+    }
+    set.add(handler);
+    return () => set!.delete(handler);
+  }
+
+  handle(method: string, handler: RequestHandler): () => void {
+    this.requests.set(method, handler);
+    return () => this.requests.delete(method);
+  }
+
+  close(): void {
+    this.ws.close();
+  }
+
+  private send(message: unknown): void {
+    this.ws.send(JSON.stringify(message));
+  }
+
+  private receive(data: unknown): void {
+    const messages = JSON.parse(String(data));
+    for (const msg of Array.isArray(messages) ? messages : [messages]) {
+      this.dispatch(msg);
+    }
+  }
+
+  private dispatch(msg: any): void {
+    // Partial result of streaming method, chunks are acknowledged for flow control
+    if (msg.method === "$/stream") {
+      const { id, seq, data } = msg.params;
+      const p = this.pending.get(id);
+      if (p) {
+        p.onChunk?.(data, seq);
+        this.send({ jsonrpc: "2.0", method: "$/streamAck", params: { id, seq } });
+      }
+      return;
+    }
+
+    if (msg.method !== undefined) {
+      // Calls initiated by server carry an id and must be answered
+      if (msg.id !== undefined && msg.id !== null) {
+        this.answer(msg);
+        return;
+      }
+      this.handlers.get(msg.method)?.forEach((h) => h(msg.params));
+      return;
+    }
+
+    const p = this.pending.get(msg.id);
+    if (!p) {
+      return;
+    }
+
+    this.pending.delete(msg.id);
+
+    if (msg.error) {
+      p.reject(new RPCError(msg.error.code, msg.error.message, msg.error.data));
+      return;
+    }
+
+    p.resolve(msg.result);
+  }
+
+  private async answer(msg: any): Promise<void> {
+    const handler = this.requests.get(msg.method);
+    if (!handler) {
+      this.send({ jsonrpc: "2.0", id: msg.id, error: { code: -32601, message: "Method not found" } });
+      return;
+    }
+
+    try {
+      const result = await handler(msg.params);
+      this.send({ jsonrpc: "2.0", id: msg.id, result: result === undefined ? null : result });
+    } catch (err) {
+      const error =
+        err instanceof RPCError
+          ? { code: err.code, message: err.message, data: err.data }
+          : { code: -32603, message: "Internal error", data: err instanceof Error ? err.message : String(err) };
+      this.send({ jsonrpc: "2.0", id: msg.id, error });
+    }
+  }
+}
+`
@@ -0,0 +1,89 @@
+package codegen_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/weedbox/websocket-modules/codegen"
+	"github.com/weedbox/websocket-modules/jsonrpc"
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+type addParams struct {
+	A int `json:"a" validate:"required"`
+	B int `json:"b"`
+}
+
+type tickEvent struct {
+	N int `json:"n"`
+}
+
+func TestGenerateTypeScript(t *testing.T) {
+
+	ra := websocket_server.NewRPCAdapter(websocket_server.WithRPCBackend(&jsonrpc.JSONRPC{}))
+	websocket_server.RegisterTyped(ra, "Math.Add", func(c *websocket_server.Context, p *addParams) (int, error) {
+		return p.A + p.B, nil
+	})
+
+	doc := ra.GenerateOpenRPC()
+	doc.Events = append(doc.Events, &websocket_server.OpenRPCMethod{
+		Name: "tick",
+		Params: []*websocket_server.OpenRPCContentDescriptor{
+			{Name: "payload", Schema: websocket_server.GenerateSchema(reflect.TypeOf(tickEvent{}))},
+		},
+	})
+
+	code, err := codegen.GenerateTypeScript(doc, codegen.WithClassName("MathClient"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := string(code)
+
+	expected := []string{
+		"export class MathClient extends RPCClient {",
+		"mathAdd(params: MathAddParams, options?: CallOptions): Promise<MathAddResult> {",
+		`return this.call<MathAddResult>("Math.Add", params, options);`,
+		"onTick(handler: (payload: TickEvent) => void): () => void {",
+
+		// Calls initiated by server are answered by runtime
+		"handle(method: string, handler: RequestHandler): () => void {",
+		`error: { code: -32601, message: "Method not found" }`,
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(src, e) {
+			t.Fatalf("generated code does not contain %q:\n%s", e, src)
+		}
+	}
+}
+
+func TestGenerateTypeScriptReservedNames(t *testing.T) {
+
+	ra := websocket_server.NewRPCAdapter(websocket_server.WithRPCBackend(&jsonrpc.JSONRPC{}))
+	for _, method := range []string{"handle", "send", "receive", "dispatch", "answer", "ready"} {
+		ra.Register(method, func(c *websocket_server.Context) (interface{}, error) {
+			return nil, nil
+		})
+	}
+
+	code, err := codegen.GenerateTypeScript(ra.GenerateOpenRPC())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Members of runtime client are not overridden
+	for _, method := range []string{"handle", "send", "receive", "dispatch", "answer", "ready"} {
+		if e := fmt.Sprintf("%s_(options?: CallOptions)", method); !strings.Contains(string(code), e) {
+			t.Fatalf("generated code does not contain %q:\n%s", e, code)
+		}
+	}
+}
+
+func TestGenerateTypeScriptInvalidClassName(t *testing.T) {
+	if _, err := codegen.GenerateTypeScript(&websocket_server.OpenRPCDocument{}, codegen.WithClassName("1Client")); err == nil {
+		t.Fatal("invalid class name was accepted")
+	}
+}
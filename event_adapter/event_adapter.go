@@ -0,0 +1,333 @@
+package event_adapter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+// ErrorEvent is emitted to client when its message is not able to be handled
+const ErrorEvent = "$/error"
+
+const DefaultAckTimeout = 30 * time.Second
+
+var (
+	ErrAckTimeout = errors.New("event: ack timeout")
+)
+
+type EventAdapterOpt func(*EventAdapter)
+type EventHandler func(*websocket_server.Context) (interface{}, error)
+type AckFunc func(data interface{}, err error)
+
+// Message is the envelope of events in both directions:
+//
+//	event:          {"event": "chat", "data": {...}}
+//	event with ack: {"event": "chat", "data": {...}, "ack": 1}
+//	ack:            {"ack": 1, "data": ...}
+//	failed ack:     {"ack": 1, "error": {"code": 2000, "message": "Method not found"}}
+type Message struct {
+	Event string                     `json:"event,omitempty"`
+	Data  interface{}                `json:"data,omitempty"`
+	Ack   *websocket_server.ID       `json:"ack,omitempty"`
+	Error *websocket_server.RPCError `json:"error,omitempty"`
+}
+
+type EventAdapter struct {
+	ackSeq int64
+
+	requestQueue *websocket_server.RequestQueue
+	handlers     map[string]EventHandler
+	ackTimeout   time.Duration
+	sessions     map[uuid.UUID]*session
+	mutex        sync.RWMutex
+	sessionMutex sync.Mutex
+}
+
+// WithAckTimeout sets how long to wait for acknowledgements from client, zero means forever
+func WithAckTimeout(timeout time.Duration) EventAdapterOpt {
+	return func(ea *EventAdapter) {
+		ea.ackTimeout = timeout
+	}
+}
+
+func New(opts ...EventAdapterOpt) *EventAdapter {
+
+	ea := &EventAdapter{
+		requestQueue: websocket_server.NewRequestQueue(),
+		handlers:     make(map[string]EventHandler),
+		ackTimeout:   DefaultAckTimeout,
+		sessions:     make(map[uuid.UUID]*session),
+	}
+
+	for _, o := range opts {
+		o(ea)
+	}
+
+	ea.requestQueue.Consume(ea.consume)
+
+	return ea
+}
+
+// On registers handler for the event. Object data is given as named params so typed handlers bind its
+// members, other data is the first param. The returned value will be sent back as acknowledgement if
+// client asked for it.
+func (ea *EventAdapter) On(event string, fn EventHandler) {
+	ea.mutex.Lock()
+	defer ea.mutex.Unlock()
+	ea.handlers[event] = fn
+}
+
+func (ea *EventAdapter) Off(event string) {
+	ea.mutex.Lock()
+	defer ea.mutex.Unlock()
+	delete(ea.handlers, event)
+}
+
+func (ea *EventAdapter) getHandler(event string) (EventHandler, bool) {
+	ea.mutex.RLock()
+	defer ea.mutex.RUnlock()
+	fn, ok := ea.handlers[event]
+	return fn, ok
+}
+
+// GetData returns data of event no matter how it was given to handler
+func GetData(c *websocket_server.Context) interface{} {
+
+	if c.GetRequest().HasNamedParams() {
+		return c.GetNamedParams()
+	}
+
+	return c.Param(0)
+}
+
+// Emit sends event to client without waiting for acknowledgement
+func (ea *EventAdapter) Emit(c websocket_server.Client, event string, data interface{}) error {
+	return c.Notify(event, data)
+}
+
+// EmitWithAck sends event to client, fn will be called once client acknowledged, timed out or disconnected
+func (ea *EventAdapter) EmitWithAck(c websocket_server.Client, event string, data interface{}, fn AckFunc) error {
+	_, err := ea.emitWithAck(c, event, data, fn)
+	return err
+}
+
+func (ea *EventAdapter) emitWithAck(c websocket_server.Client, event string, data interface{}, fn AckFunc) (int64, error) {
+
+	id := atomic.AddInt64(&ea.ackSeq, 1)
+	ack := websocket_server.NewIntID(id)
+
+	msg, err := json.Marshal(&Message{
+		Event: event,
+		Data:  data,
+		Ack:   &ack,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if !ea.getSession(c).await(id, fn, ea.ackTimeout) {
+		return 0, websocket_server.ErrConnectionClosed
+	}
+
+	if err := c.Send(msg); err != nil {
+		ea.getSession(c).cancel(id)
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Call emits event and waits for acknowledgement from client
+func (ea *EventAdapter) Call(ctx context.Context, c websocket_server.Client, method string, params interface{}) (interface{}, error) {
+
+	type result struct {
+		data interface{}
+		err  error
+	}
+
+	done := make(chan result, 1)
+
+	id, err := ea.emitWithAck(c, method, params, func(data interface{}, err error) {
+		done <- result{
+			data: data,
+			err:  err,
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case r := <-done:
+		return r.data, r.err
+	case <-ctx.Done():
+		ea.getSession(c).cancel(id)
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, websocket_server.ErrCallTimeout
+		}
+
+		return nil, ctx.Err()
+	}
+}
+
+func (ea *EventAdapter) HandleMessage(c websocket_server.Client) error {
+
+	data, err := io.ReadAll(c.GetReader())
+	if err != nil {
+		return err
+	}
+
+	var msg Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return c.Notify(ErrorEvent, websocket_server.NewError(websocket_server.ErrorCode_ParseError, nil))
+	}
+
+	// Acknowledgement of event which was emitted by server
+	if len(msg.Event) == 0 {
+
+		if msg.Ack == nil {
+			return c.Notify(ErrorEvent, websocket_server.NewError(websocket_server.ErrorCode_InvalidRequest, "event is required"))
+		}
+
+		if id, ok := msg.Ack.Int64(); ok {
+			ea.getSession(c).resolve(id, msg.Data, msg.Error)
+		}
+
+		return nil
+	}
+
+	req := &websocket_server.RPCRequest{
+		Method:       msg.Event,
+		Params:       []interface{}{msg.Data},
+		Notification: true,
+	}
+
+	if data, ok := msg.Data.(map[string]interface{}); ok {
+		req.Params = make([]interface{}, 0)
+		req.NamedParams = data
+	}
+
+	if msg.Ack != nil {
+		req.ID = *msg.Ack
+		req.Notification = false
+	}
+
+	ea.requestQueue.Push(websocket_server.NewContext(c, req))
+
+	return nil
+}
+
+func (ea *EventAdapter) consume(c *websocket_server.Context) error {
+
+	defer c.Cancel()
+
+	returnedValue, err := ea.handleEvent(c)
+
+	req := c.GetRequest()
+
+	// Client is not waiting for acknowledgement
+	if req.Notification || c.GetClient().GetContext().Err() != nil {
+		return err
+	}
+
+	res := &websocket_server.RPCResponse{
+		ID:     req.ID,
+		Result: returnedValue,
+	}
+
+	if err != nil {
+		res.Error = websocket_server.ToRPCError(err)
+	}
+
+	return c.Respond(res)
+}
+
+func (ea *EventAdapter) handleEvent(c *websocket_server.Context) (interface{}, error) {
+
+	fn, ok := ea.getHandler(c.GetRequest().Method)
+	if !ok {
+		return nil, websocket_server.ErrMethodNotFound
+	}
+
+	return fn(c)
+}
+
+// PrepareResponse creates acknowledgement message
+func (ea *EventAdapter) PrepareResponse(res *websocket_server.RPCResponse) ([]byte, error) {
+
+	msg := &Message{
+		Ack: &res.ID,
+	}
+
+	if res.Error != nil {
+		msg.Error = websocket_server.ToRPCError(res.Error)
+	} else {
+		msg.Data = res.Result
+	}
+
+	return json.Marshal(msg)
+}
+
+func (ea *EventAdapter) PrepareNotification(eventName string, payload interface{}) ([]byte, error) {
+	return json.Marshal(&Message{
+		Event: eventName,
+		Data:  payload,
+	})
+}
+
+// Register is an alias of On for Adapter interface, options are unused
+func (ea *EventAdapter) Register(method string, fn websocket_server.RPCFunc, opts ...websocket_server.RPCMethodOpt) error {
+	ea.On(method, EventHandler(fn))
+	return nil
+}
+
+func (ea *EventAdapter) Unregister(method string) {
+	ea.Off(method)
+}
+
+func (ea *EventAdapter) IsBinary() bool {
+	return false
+}
+
+func (ea *EventAdapter) getSession(c websocket_server.Client) *session {
+
+	ea.sessionMutex.Lock()
+	defer ea.sessionMutex.Unlock()
+
+	s, ok := ea.sessions[c.GetClientID()]
+	if !ok {
+		s = newSession()
+
+		// Client was closed already
+		if c.GetContext().Err() != nil {
+			s.close()
+			return s
+		}
+
+		ea.sessions[c.GetClientID()] = s
+	}
+
+	return s
+}
+
+func (ea *EventAdapter) Release(c websocket_server.Client) {
+
+	ea.sessionMutex.Lock()
+	s, ok := ea.sessions[c.GetClientID()]
+	delete(ea.sessions, c.GetClientID())
+	ea.sessionMutex.Unlock()
+
+	if ok {
+		s.close()
+	}
+}
@@ -0,0 +1,294 @@
+package event_adapter_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws/wsutil"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/weedbox/websocket-modules/event_adapter"
+	"github.com/weedbox/websocket-modules/websocket_server"
+	"github.com/weedbox/websocket-modules/websocket_servertest"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+type chat struct {
+	Room string `json:"room" validate:"required"`
+	Text string `json:"text"`
+}
+
+type peer struct {
+	t      *testing.T
+	conn   net.Conn
+	server *websocket_servertest.Server
+}
+
+func connect(t *testing.T, ea *event_adapter.EventAdapter) *peer {
+
+	options := websocket_server.NewOptions()
+	options.Adapter = ea
+
+	s := websocket_servertest.NewServer(options)
+
+	conn, err := s.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		conn.Close()
+		s.Close()
+	})
+
+	return &peer{
+		t:      t,
+		conn:   conn,
+		server: s,
+	}
+}
+
+func (p *peer) client() websocket_server.Client {
+
+	clients := p.server.GetClients()
+	if len(clients) != 1 {
+		p.t.Fatalf("unexpected clients %v", clients)
+	}
+
+	return clients[0]
+}
+
+func (p *peer) send(msg string) {
+	if err := wsutil.WriteClientText(p.conn, []byte(msg)); err != nil {
+		p.t.Fatal(err)
+	}
+}
+
+func (p *peer) read() interface{} {
+
+	p.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	data, err := wsutil.ReadServerText(p.conn)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		p.t.Fatalf("invalid message %s", data)
+	}
+
+	return v
+}
+
+func (p *peer) expect(expected string) {
+
+	p.t.Helper()
+
+	var e interface{}
+	if err := json.Unmarshal([]byte(expected), &e); err != nil {
+		p.t.Fatal(err)
+	}
+
+	if msg := p.read(); !reflect.DeepEqual(msg, e) {
+		data, _ := json.Marshal(msg)
+		p.t.Fatalf("unexpected message %s, expected %s", data, expected)
+	}
+}
+
+func (p *peer) expectNothing() {
+
+	p.t.Helper()
+
+	p.conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+
+	if data, err := wsutil.ReadServerText(p.conn); err == nil {
+		p.t.Fatalf("unexpected message %s", data)
+	}
+}
+
+// emit sends event in background since pipe blocks until peer reads, result receives error of either
+// sending or acknowledgement
+func emit(ea *event_adapter.EventAdapter, c websocket_server.Client) <-chan error {
+
+	result := make(chan error, 2)
+
+	go func() {
+		err := ea.EmitWithAck(c, "ping", nil, func(data interface{}, err error) {
+			result <- err
+		})
+		if err != nil {
+			result <- err
+		}
+	}()
+
+	return result
+}
+
+func TestEvent(t *testing.T) {
+
+	ea := event_adapter.New()
+	ea.On("echo", func(c *websocket_server.Context) (interface{}, error) {
+		return event_adapter.GetData(c), nil
+	})
+
+	p := connect(t, ea)
+
+	p.send(`{"event":"echo","data":{"a":1},"ack":1}`)
+	p.expect(`{"ack":1,"data":{"a":1}}`)
+
+	p.send(`{"event":"echo","data":"x","ack":"s"}`)
+	p.expect(`{"ack":"s","data":"x"}`)
+
+	// Event without ack is not acknowledged
+	p.send(`{"event":"echo","data":"x"}`)
+	p.expectNothing()
+
+	p.send(`{"event":"missing","ack":2}`)
+	p.expect(`{"ack":2,"error":{"code":2000,"message":"Method not found"}}`)
+
+	ea.Off("echo")
+
+	p.send(`{"event":"echo","ack":3}`)
+	p.expect(`{"ack":3,"error":{"code":2000,"message":"Method not found"}}`)
+}
+
+func TestTypedEvent(t *testing.T) {
+
+	ea := event_adapter.New()
+	websocket_server.RegisterTyped(ea, "chat", func(c *websocket_server.Context, m *chat) (string, error) {
+		return m.Room + ":" + m.Text, nil
+	})
+
+	p := connect(t, ea)
+
+	p.send(`{"event":"chat","data":{"room":"r","text":"hi"},"ack":1}`)
+	p.expect(`{"ack":1,"data":"r:hi"}`)
+
+	p.send(`{"event":"chat","data":{"text":"hi"},"ack":2}`)
+	p.expect(`{"ack":2,"error":{"code":3002,"message":"Insufficient arguments","data":["room"]}}`)
+}
+
+func TestInvalidMessage(t *testing.T) {
+
+	p := connect(t, event_adapter.New())
+
+	p.send(`{`)
+	p.expect(`{"event":"$/error","data":{"code":1001,"message":"Parse error"}}`)
+
+	p.send(`{"data":1}`)
+	p.expect(`{"event":"$/error","data":{"code":1000,"message":"Invalid Request","data":"event is required"}}`)
+
+	// Acknowledgement which is unexpected is ignored
+	p.send(`{"ack":9,"data":1}`)
+	p.expectNothing()
+}
+
+func TestCall(t *testing.T) {
+
+	ea := event_adapter.New()
+	p := connect(t, ea)
+
+	result := make(chan interface{}, 1)
+	go func() {
+		res, err := ea.Call(context.Background(), p.client(), "ping", "x")
+		if err != nil {
+			result <- err
+			return
+		}
+		result <- res
+	}()
+
+	msg, ok := p.read().(map[string]interface{})
+	if !ok || msg["event"] != "ping" || msg["data"] != "x" {
+		t.Fatalf("unexpected message %v", msg)
+	}
+
+	ack, _ := json.Marshal(msg["ack"])
+	p.send(`{"ack":` + string(ack) + `,"data":"pong"}`)
+
+	if res := <-result; res != "pong" {
+		t.Fatalf("unexpected result %v", res)
+	}
+
+	// Error of client is passed to caller
+	go func() {
+		_, err := ea.Call(context.Background(), p.client(), "ping", nil)
+		result <- err
+	}()
+
+	msg = p.read().(map[string]interface{})
+	ack, _ = json.Marshal(msg["ack"])
+	p.send(`{"ack":` + string(ack) + `,"error":{"code":2000,"message":"Method not found"}}`)
+
+	var rpcErr *websocket_server.RPCError
+	if err, _ := (<-result).(error); !errors.As(err, &rpcErr) || rpcErr.Code != websocket_server.ErrorCode_NotFound {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestCallTimeout(t *testing.T) {
+
+	ea := event_adapter.New()
+	p := connect(t, ea)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := ea.Call(ctx, p.client(), "ping", nil)
+		result <- err
+	}()
+
+	// Event is never acknowledged
+	p.read()
+
+	if err := <-result; !errors.Is(err, websocket_server.ErrCallTimeout) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestAckTimeout(t *testing.T) {
+
+	ea := event_adapter.New(event_adapter.WithAckTimeout(20 * time.Millisecond))
+	p := connect(t, ea)
+
+	result := emit(ea, p.client())
+	p.read()
+
+	if err := <-result; !errors.Is(err, event_adapter.ErrAckTimeout) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestAckDisconnected(t *testing.T) {
+
+	ea := event_adapter.New()
+	p := connect(t, ea)
+	c := p.client()
+
+	result := emit(ea, c)
+	p.read()
+	p.conn.Close()
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, websocket_server.ErrConnectionClosed) {
+			t.Fatalf("unexpected error %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ack was not released")
+	}
+
+	// Client which is gone is unable to be waited for
+	<-c.GetContext().Done()
+
+	if err := ea.EmitWithAck(c, "ping", nil, func(interface{}, error) {}); !errors.Is(err, websocket_server.ErrConnectionClosed) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
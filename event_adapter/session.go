@@ -0,0 +1,109 @@
+package event_adapter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+type pendingAck struct {
+	fn    AckFunc
+	timer *time.Timer
+}
+
+// session keeps acknowledgements which are awaited from a client
+type session struct {
+	mutex   sync.Mutex
+	pending map[int64]*pendingAck
+	closed  bool
+}
+
+func newSession() *session {
+	return &session{
+		pending: make(map[int64]*pendingAck),
+	}
+}
+
+func (s *session) await(id int64, fn AckFunc, timeout time.Duration) bool {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return false
+	}
+
+	p := &pendingAck{
+		fn: fn,
+	}
+
+	if timeout > 0 {
+		p.timer = time.AfterFunc(timeout, func() {
+			if p, ok := s.take(id); ok {
+				p.fn(nil, ErrAckTimeout)
+			}
+		})
+	}
+
+	s.pending[id] = p
+
+	return true
+}
+
+func (s *session) take(id int64) (*pendingAck, bool) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	p, ok := s.pending[id]
+	if !ok {
+		return nil, false
+	}
+
+	delete(s.pending, id)
+
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+
+	return p, true
+}
+
+func (s *session) cancel(id int64) {
+	s.take(id)
+}
+
+func (s *session) resolve(id int64, data interface{}, rpcErr *websocket_server.RPCError) {
+
+	p, ok := s.take(id)
+	if !ok {
+		return
+	}
+
+	// Callback must not block the poller
+	if rpcErr != nil {
+		go p.fn(nil, rpcErr)
+		return
+	}
+
+	go p.fn(data, nil)
+}
+
+func (s *session) close() {
+
+	s.mutex.Lock()
+	pending := s.pending
+	s.pending = make(map[int64]*pendingAck)
+	s.closed = true
+	s.mutex.Unlock()
+
+	for _, p := range pending {
+
+		if p.timer != nil {
+			p.timer.Stop()
+		}
+
+		go p.fn(nil, websocket_server.ErrConnectionClosed)
+	}
+}
@@ -0,0 +1,384 @@
+package graphql_adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/google/uuid"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/weedbox/websocket-modules/auth_rpc"
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+// Subprotocol should be accepted by endpoint for GraphQL clients
+const Subprotocol = "graphql-transport-ws"
+
+const (
+	DefaultInitTimeout = 3 * time.Second
+	DefaultTokenField  = "token"
+)
+
+// Close codes which are defined by the protocol
+const (
+	CloseCode_BadRequest          ws.StatusCode = 4400
+	CloseCode_Unauthorized                      = 4401
+	CloseCode_Forbidden                         = 4403
+	CloseCode_InitTimeout                       = 4408
+	CloseCode_SubscriberExists                  = 4409
+	CloseCode_TooManyInitRequests               = 4429
+)
+
+var (
+	ErrNoExecutor = errors.New("graphql: no executor")
+)
+
+type GraphQLAdapterOpt func(*GraphQLAdapter)
+
+// Executor runs operations, the channel should be closed after the last result. Single result is
+// expected for queries and mutations, and context is cancelled once client completed the operation.
+type Executor interface {
+	Execute(c *websocket_server.Context, req *Request) (<-chan *Result, error)
+}
+
+type ExecutorFunc func(c *websocket_server.Context, req *Request) (<-chan *Result, error)
+
+func (fn ExecutorFunc) Execute(c *websocket_server.Context, req *Request) (<-chan *Result, error) {
+	return fn(c, req)
+}
+
+type GraphQLAdapter struct {
+	executor      Executor
+	authenticator auth_rpc.Authenticator
+	tokenField    string
+	initTimeout   time.Duration
+	sessions      map[uuid.UUID]*session
+	done          chan struct{}
+	closeOnce     sync.Once
+	sessionMutex  sync.RWMutex
+}
+
+func WithExecutor(executor Executor) GraphQLAdapterOpt {
+	return func(ga *GraphQLAdapter) {
+		ga.executor = executor
+	}
+}
+
+// WithAuthenticator verifies token in payload of connection_init, authentication data will be stored in client meta
+func WithAuthenticator(authenticator auth_rpc.Authenticator) GraphQLAdapterOpt {
+	return func(ga *GraphQLAdapter) {
+		ga.authenticator = authenticator
+	}
+}
+
+func WithTokenField(field string) GraphQLAdapterOpt {
+	return func(ga *GraphQLAdapter) {
+		ga.tokenField = field
+	}
+}
+
+func WithInitTimeout(timeout time.Duration) GraphQLAdapterOpt {
+	return func(ga *GraphQLAdapter) {
+		ga.initTimeout = timeout
+	}
+}
+
+func New(opts ...GraphQLAdapterOpt) *GraphQLAdapter {
+
+	ga := &GraphQLAdapter{
+		tokenField:  DefaultTokenField,
+		initTimeout: DefaultInitTimeout,
+		sessions:    make(map[uuid.UUID]*session),
+		done:        make(chan struct{}),
+	}
+
+	for _, o := range opts {
+		o(ga)
+	}
+
+	if ga.initTimeout > 0 {
+		go ga.watch(ga.initTimeout / 2)
+	}
+
+	return ga
+}
+
+// Close stops checking initialisation timeout, connected clients are not closed
+func (ga *GraphQLAdapter) Close() {
+	ga.closeOnce.Do(func() {
+		close(ga.done)
+	})
+}
+
+// GetRequest returns the operation which is executed with the context
+func GetRequest(c *websocket_server.Context) *Request {
+	req, _ := c.Param(0).(*Request)
+	return req
+}
+
+// Attach starts counting initialisation timeout before the first message arrives
+func (ga *GraphQLAdapter) Attach(c websocket_server.Client) {
+	ga.getSession(c)
+}
+
+func (ga *GraphQLAdapter) HandleMessage(c websocket_server.Client) error {
+
+	data, err := io.ReadAll(c.GetReader())
+	if err != nil {
+		return err
+	}
+
+	s := ga.getSession(c)
+
+	var msg rawMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return ga.fail(s, CloseCode_BadRequest, "Invalid message received")
+	}
+
+	switch msg.Type {
+	case MessageType_ConnectionInit:
+		if !s.init() {
+			return ga.fail(s, CloseCode_TooManyInitRequests, "Too many initialisation requests")
+		}
+
+		// Authenticator may take a while, the poller should not be blocked
+		go ga.initialize(s, msg.Payload)
+
+		return nil
+	case MessageType_Ping:
+		return s.send(&Message{Type: MessageType_Pong})
+	case MessageType_Pong:
+		return nil
+	case MessageType_Subscribe:
+		return ga.subscribe(s, &msg)
+	case MessageType_Complete:
+		s.complete(msg.ID)
+		return nil
+	}
+
+	return ga.fail(s, CloseCode_BadRequest, "Invalid message received")
+}
+
+func (ga *GraphQLAdapter) initialize(s *session, payload jsoniter.RawMessage) {
+
+	if ga.authenticator != nil {
+		if err := ga.authenticate(s, payload); err != nil {
+			ga.fail(s, CloseCode_Forbidden, "Forbidden")
+			return
+		}
+	}
+
+	s.acknowledge()
+	s.send(&Message{Type: MessageType_ConnectionAck})
+}
+
+func (ga *GraphQLAdapter) authenticate(s *session, payload jsoniter.RawMessage) error {
+
+	params := make(map[string]interface{})
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &params); err != nil {
+			return err
+		}
+	}
+
+	token, _ := params[ga.tokenField].(string)
+	if len(token) == 0 {
+		return auth_rpc.ErrInvalidToken
+	}
+
+	info, err := ga.authenticator.Authenticate(token)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range info.Data {
+		s.client.GetMeta().Set(k, v)
+	}
+
+	return nil
+}
+
+func (ga *GraphQLAdapter) subscribe(s *session, msg *rawMessage) error {
+
+	if !s.isAcknowledged() {
+		return ga.fail(s, CloseCode_Unauthorized, "Unauthorized")
+	}
+
+	var req Request
+	if len(msg.ID) == 0 || len(msg.Payload) == 0 || json.Unmarshal(msg.Payload, &req) != nil || len(req.Query) == 0 {
+		return ga.fail(s, CloseCode_BadRequest, "Invalid message received")
+	}
+
+	rpcReq := &websocket_server.RPCRequest{
+		ID:     websocket_server.NewStringID(msg.ID),
+		Method: req.OperationName,
+		Params: []interface{}{&req},
+	}
+
+	c := websocket_server.NewContext(s.client, rpcReq)
+	if !s.track(msg.ID, c) {
+		c.Cancel()
+		return ga.fail(s, CloseCode_SubscriberExists, fmt.Sprintf("Subscriber for %s already exists", msg.ID))
+	}
+
+	// Subscriptions are long-lived so every operation runs on its own
+	go ga.execute(s, msg.ID, c, &req)
+
+	return nil
+}
+
+func (ga *GraphQLAdapter) execute(s *session, id string, c *websocket_server.Context, req *Request) {
+
+	defer c.Cancel()
+
+	if ga.executor == nil {
+		ga.reject(s, id, c, ErrNoExecutor)
+		return
+	}
+
+	results, err := ga.executor.Execute(c, req)
+	if err != nil {
+		ga.reject(s, id, c, err)
+		return
+	}
+
+	for {
+		select {
+		case <-c.Done():
+			return
+		case r, ok := <-results:
+			if !ok {
+
+				// No complete message if client completed the operation already
+				if s.untrack(id, c) {
+					s.send(&Message{ID: id, Type: MessageType_Complete})
+				}
+
+				return
+			}
+
+			if err := s.send(&Message{ID: id, Type: MessageType_Next, Payload: r}); err != nil {
+				s.untrack(id, c)
+				return
+			}
+		}
+	}
+}
+
+// reject terminates operation with error message, no complete message follows it
+func (ga *GraphQLAdapter) reject(s *session, id string, c *websocket_server.Context, err error) {
+
+	if !s.untrack(id, c) {
+		return
+	}
+
+	var errs Errors
+	var e *Error
+	switch {
+	case errors.As(err, &errs):
+	case errors.As(err, &e):
+		errs = Errors{e}
+	default:
+		errs = Errors{{Message: err.Error()}}
+	}
+
+	s.send(&Message{ID: id, Type: MessageType_Error, Payload: errs})
+}
+
+// fail closes connection with the code, client and session are released right away so that it is
+// able to be called outside the poller
+func (ga *GraphQLAdapter) fail(s *session, code ws.StatusCode, reason string) error {
+	s.client.CloseWithStatus(code, reason)
+	return fmt.Errorf("graphql: %s", reason)
+}
+
+func (ga *GraphQLAdapter) watch(tick time.Duration) {
+
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ga.done:
+			return
+		case <-ticker.C:
+		}
+
+		expired := make([]*session, 0)
+
+		ga.sessionMutex.RLock()
+		for _, s := range ga.sessions {
+			if s.initExpired(ga.initTimeout) {
+				expired = append(expired, s)
+			}
+		}
+		ga.sessionMutex.RUnlock()
+
+		for _, s := range expired {
+			ga.fail(s, CloseCode_InitTimeout, "Connection initialisation timeout")
+		}
+	}
+}
+
+func (ga *GraphQLAdapter) getSession(c websocket_server.Client) *session {
+
+	ga.sessionMutex.Lock()
+	defer ga.sessionMutex.Unlock()
+
+	s, ok := ga.sessions[c.GetClientID()]
+	if !ok {
+		s = newSession(c)
+
+		// Client was closed already
+		if c.GetContext().Err() != nil {
+			return s
+		}
+
+		ga.sessions[c.GetClientID()] = s
+	}
+
+	return s
+}
+
+func (ga *GraphQLAdapter) Release(c websocket_server.Client) {
+
+	ga.sessionMutex.Lock()
+	s, ok := ga.sessions[c.GetClientID()]
+	delete(ga.sessions, c.GetClientID())
+	ga.sessionMutex.Unlock()
+
+	if ok {
+		s.close()
+	}
+}
+
+// Call is not supported since server is unable to start operations
+func (ga *GraphQLAdapter) Call(ctx context.Context, c websocket_server.Client, method string, params interface{}) (interface{}, error) {
+	return nil, websocket_server.ErrAdapterNotImplemented
+}
+
+func (ga *GraphQLAdapter) PrepareNotification(eventName string, payload interface{}) ([]byte, error) {
+	return []byte(""), websocket_server.ErrAdapterNotImplemented
+}
+
+func (ga *GraphQLAdapter) PrepareResponse(res *websocket_server.RPCResponse) ([]byte, error) {
+	return []byte(""), websocket_server.ErrAdapterNotImplemented
+}
+
+// Register is not supported, operations are resolved by executor
+func (ga *GraphQLAdapter) Register(method string, fn websocket_server.RPCFunc, opts ...websocket_server.RPCMethodOpt) error {
+	return websocket_server.ErrAdapterNotImplemented
+}
+
+func (ga *GraphQLAdapter) Unregister(method string) {
+}
+
+func (ga *GraphQLAdapter) IsBinary() bool {
+	return false
+}
@@ -0,0 +1,244 @@
+package graphql_adapter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/weedbox/websocket-modules/graphql_adapter"
+	"github.com/weedbox/websocket-modules/websocket_server"
+	"github.com/weedbox/websocket-modules/websocket_servertest"
+)
+
+type message struct {
+	ID      string              `json:"id"`
+	Type    string              `json:"type"`
+	Payload jsoniter.RawMessage `json:"payload"`
+}
+
+func waitMessages(t *testing.T, c *websocket_servertest.Client, n int) []*message {
+
+	deadline := time.Now().Add(2 * time.Second)
+
+	for {
+		frames := c.GetFrames()
+		if len(frames) >= n {
+
+			msgs := make([]*message, 0, len(frames))
+			for _, f := range frames {
+				var msg message
+				if err := jsoniter.Unmarshal(f, &msg); err != nil {
+					t.Fatal(err)
+				}
+
+				msgs = append(msgs, &msg)
+			}
+
+			return msgs
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d messages, got %d", n, len(frames))
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func waitClosed(t *testing.T, c *websocket_servertest.Client, code ws.StatusCode) {
+
+	deadline := time.Now().Add(2 * time.Second)
+
+	for !c.IsClosed() {
+		if time.Now().After(deadline) {
+			t.Fatal("client was not closed")
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if st := c.GetCloseStatus(); st == nil || st.Code != code {
+		t.Fatalf("unexpected close status %v", st)
+	}
+}
+
+func initClient(t *testing.T, ga *graphql_adapter.GraphQLAdapter) *websocket_servertest.Client {
+
+	c := websocket_servertest.NewClient(ga)
+	c.Receive([]byte(`{"type":"connection_init"}`))
+
+	msgs := waitMessages(t, c, 1)
+	if msgs[0].Type != graphql_adapter.MessageType_ConnectionAck {
+		t.Fatalf("unexpected message %s", msgs[0].Type)
+	}
+
+	c.Reset()
+
+	return c
+}
+
+func TestAuthentication(t *testing.T) {
+
+	a := websocket_servertest.NewAuthenticator()
+	a.Add("good", map[string]interface{}{"user": "bob"})
+
+	ga := graphql_adapter.New(graphql_adapter.WithAuthenticator(a))
+
+	c := websocket_servertest.NewClient(ga)
+	c.Receive([]byte(`{"type":"connection_init","payload":{"token":"good"}}`))
+
+	msgs := waitMessages(t, c, 1)
+	if msgs[0].Type != graphql_adapter.MessageType_ConnectionAck || c.GetMeta().Get("user") != "bob" {
+		t.Fatalf("unexpected message %s", msgs[0].Type)
+	}
+
+	c = websocket_servertest.NewClient(ga)
+	c.Receive([]byte(`{"type":"connection_init","payload":{"token":"bad"}}`))
+
+	waitClosed(t, c, graphql_adapter.CloseCode_Forbidden)
+}
+
+func TestInitTimeoutReleasesClient(t *testing.T) {
+
+	disconnected := make(chan websocket_server.Client, 1)
+
+	s := websocket_servertest.NewServer(nil)
+	s.GetOptions().Adapter = graphql_adapter.New(graphql_adapter.WithInitTimeout(50 * time.Millisecond))
+	s.GetOptions().OnDisconnected = func(c websocket_server.Client) error {
+		disconnected <- c
+		return nil
+	}
+	defer s.Close()
+
+	conn, err := s.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Client never sends connection_init
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	frame, err := ws.ReadFrame(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code, _ := ws.ParseCloseFrameData(frame.Payload); frame.Header.OpCode != ws.OpClose || code != graphql_adapter.CloseCode_InitTimeout {
+		t.Fatalf("unexpected frame %v %d", frame.Header.OpCode, code)
+	}
+
+	select {
+	case <-disconnected:
+	case <-time.After(2 * time.Second):
+		t.Fatal("client was not released")
+	}
+
+	if len(s.GetClients()) != 0 {
+		t.Fatal("client was not removed")
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+
+	ga := graphql_adapter.New(graphql_adapter.WithExecutor(graphql_adapter.ExecutorFunc(func(c *websocket_server.Context, req *graphql_adapter.Request) (<-chan *graphql_adapter.Result, error) {
+
+		results := make(chan *graphql_adapter.Result, 2)
+		results <- &graphql_adapter.Result{Data: map[string]interface{}{"n": 1}}
+		results <- &graphql_adapter.Result{Data: map[string]interface{}{"n": 2}}
+		close(results)
+
+		return results, nil
+	})))
+
+	c := initClient(t, ga)
+	c.Receive([]byte(`{"id":"1","type":"subscribe","payload":{"query":"subscription { n }"}}`))
+
+	msgs := waitMessages(t, c, 3)
+	if msgs[0].Type != graphql_adapter.MessageType_Next || string(msgs[0].Payload) != `{"data":{"n":1}}` {
+		t.Fatalf("unexpected message %s %s", msgs[0].Type, msgs[0].Payload)
+	}
+
+	if msgs[2].Type != graphql_adapter.MessageType_Complete || msgs[2].ID != "1" {
+		t.Fatalf("unexpected message %s", msgs[2].Type)
+	}
+}
+
+func blockingExecutor(cancelled chan struct{}) graphql_adapter.ExecutorFunc {
+	return func(c *websocket_server.Context, req *graphql_adapter.Request) (<-chan *graphql_adapter.Result, error) {
+
+		go func() {
+			<-c.Done()
+			close(cancelled)
+		}()
+
+		return make(chan *graphql_adapter.Result), nil
+	}
+}
+
+func TestCompleteCancelsOperation(t *testing.T) {
+
+	cancelled := make(chan struct{})
+	ga := graphql_adapter.New(graphql_adapter.WithExecutor(blockingExecutor(cancelled)))
+
+	c := initClient(t, ga)
+	c.Receive([]byte(`{"id":"1","type":"subscribe","payload":{"query":"subscription { n }"}}`))
+	c.Receive([]byte(`{"id":"1","type":"complete"}`))
+
+	select {
+	case <-cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("operation was not cancelled")
+	}
+
+	if c.IsClosed() {
+		t.Fatal("client was closed")
+	}
+}
+
+func TestDuplicateOperation(t *testing.T) {
+
+	cancelled := make(chan struct{})
+	ga := graphql_adapter.New(graphql_adapter.WithExecutor(blockingExecutor(cancelled)))
+
+	c := initClient(t, ga)
+	c.Receive([]byte(`{"id":"1","type":"subscribe","payload":{"query":"subscription { n }"}}`))
+
+	// Operation ID is in use until it is completed
+	if err := c.Receive([]byte(`{"id":"1","type":"subscribe","payload":{"query":"subscription { n }"}}`)); err == nil {
+		t.Fatal("duplicate operation was accepted")
+	}
+
+	waitClosed(t, c, graphql_adapter.CloseCode_SubscriberExists)
+
+	// Operations are cancelled once client is released
+	select {
+	case <-cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("operation was not cancelled")
+	}
+}
+
+func TestSubscribeBeforeAck(t *testing.T) {
+
+	c := websocket_servertest.NewClient(graphql_adapter.New())
+	c.Receive([]byte(`{"id":"1","type":"subscribe","payload":{"query":"{ n }"}}`))
+
+	waitClosed(t, c, graphql_adapter.CloseCode_Unauthorized)
+}
+
+func TestClose(t *testing.T) {
+
+	ga := graphql_adapter.New(graphql_adapter.WithInitTimeout(20 * time.Millisecond))
+	ga.Close()
+	ga.Close()
+
+	c := websocket_servertest.NewClient(ga)
+
+	// Initialisation timeout is no longer checked
+	time.Sleep(200 * time.Millisecond)
+
+	if c.IsClosed() {
+		t.Fatal("client was closed after adapter was closed")
+	}
+}
@@ -0,0 +1,76 @@
+package graphql_adapter
+
+import (
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+type MessageType string
+
+const (
+	MessageType_ConnectionInit MessageType = "connection_init"
+	MessageType_ConnectionAck              = "connection_ack"
+	MessageType_Ping                       = "ping"
+	MessageType_Pong                       = "pong"
+	MessageType_Subscribe                  = "subscribe"
+	MessageType_Next                       = "next"
+	MessageType_Error                      = "error"
+	MessageType_Complete                   = "complete"
+)
+
+type Message struct {
+	ID      string      `json:"id,omitempty"`
+	Type    MessageType `json:"type"`
+	Payload interface{} `json:"payload,omitempty"`
+}
+
+type rawMessage struct {
+	ID      string              `json:"id"`
+	Type    MessageType         `json:"type"`
+	Payload jsoniter.RawMessage `json:"payload"`
+}
+
+// Request is the payload of subscribe message
+type Request struct {
+	OperationName string                 `json:"operationName,omitempty"`
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	Extensions    map[string]interface{} `json:"extensions,omitempty"`
+}
+
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type Error struct {
+	Message    string                 `json:"message"`
+	Locations  []Location             `json:"locations,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Errors can be returned by executor to reject operation with multiple errors before execution
+type Errors []*Error
+
+func (errs Errors) Error() string {
+
+	messages := make([]string, 0, len(errs))
+	for _, e := range errs {
+		messages = append(messages, e.Message)
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// Result is the payload of next message
+type Result struct {
+	Data       interface{}            `json:"data,omitempty"`
+	Errors     []*Error               `json:"errors,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
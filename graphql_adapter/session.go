@@ -0,0 +1,125 @@
+package graphql_adapter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+type session struct {
+	client    websocket_server.Client
+	createdAt time.Time
+
+	mutex        sync.Mutex
+	initReceived bool
+	acknowledged bool
+	operations   map[string]*websocket_server.Context
+}
+
+func newSession(c websocket_server.Client) *session {
+	return &session{
+		client:     c,
+		createdAt:  time.Now(),
+		operations: make(map[string]*websocket_server.Context),
+	}
+}
+
+func (s *session) send(msg *Message) error {
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	return s.client.Send(data)
+}
+
+// init returns false if connection_init was received already
+func (s *session) init() bool {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.initReceived {
+		return false
+	}
+
+	s.initReceived = true
+
+	return true
+}
+
+func (s *session) acknowledge() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.acknowledged = true
+}
+
+func (s *session) isAcknowledged() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.acknowledged
+}
+
+// initExpired reports whether connection was not acknowledged in time
+func (s *session) initExpired(timeout time.Duration) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return !s.acknowledged && time.Since(s.createdAt) > timeout
+}
+
+// track returns false if the operation ID is in use
+func (s *session) track(id string, c *websocket_server.Context) bool {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.operations[id]; ok {
+		return false
+	}
+
+	s.operations[id] = c
+
+	return true
+}
+
+// untrack returns false if the operation was completed already
+func (s *session) untrack(id string, c *websocket_server.Context) bool {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if cur, ok := s.operations[id]; !ok || cur != c {
+		return false
+	}
+
+	delete(s.operations, id)
+
+	return true
+}
+
+// complete stops operation which was completed by client
+func (s *session) complete(id string) {
+
+	s.mutex.Lock()
+	c, ok := s.operations[id]
+	delete(s.operations, id)
+	s.mutex.Unlock()
+
+	if ok {
+		c.Cancel()
+	}
+}
+
+func (s *session) close() {
+
+	s.mutex.Lock()
+	operations := s.operations
+	s.operations = make(map[string]*websocket_server.Context)
+	s.mutex.Unlock()
+
+	for _, c := range operations {
+		c.Cancel()
+	}
+}
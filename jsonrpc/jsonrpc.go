@@ -1,9 +1,10 @@
 package jsonrpc
 
 import (
+	"bytes"
 	"io"
-	"reflect"
 	"sync"
+	"time"
 
 	jsoniter "github.com/json-iterator/go"
 	"github.com/weedbox/websocket-modules/websocket_server"
@@ -22,25 +23,100 @@ const (
 	JSONRPCError_ServerError                     = -32000
 )
 
-var JSONRPCErrorMap = []JSONRPCErrorCode{
-	JSONRPCError_InvalidRequest,
-	JSONRPCError_NotFound,
-	JSONRPCError_InvalidParams,
-	JSONRPCError_InternalError,
-	JSONRPCError_ServerError,
+// JSONRPCErrorMap converts error codes to standard JSON-RPC error codes, codes which are not
+// listed here will be sent as is.
+var JSONRPCErrorMap = map[websocket_server.RPCErrorCode]JSONRPCErrorCode{
+	websocket_server.ErrorCode_ParseError:                           JSONRPCError_ParseError,
+	websocket_server.ErrorCode_InvalidRequest:                       JSONRPCError_InvalidRequest,
+	websocket_server.ErrorCode_NotFound:                             JSONRPCError_NotFound,
+	websocket_server.ErrorCode_InvalidParams:                        JSONRPCError_InvalidParams,
+	websocket_server.ErrorCode_InvalidParams_Invalid_Arguments:      JSONRPCError_InvalidParams,
+	websocket_server.ErrorCode_InvalidParams_Insufficient_Arguments: JSONRPCError_InvalidParams,
+	websocket_server.ErrorCode_InternalError:                        JSONRPCError_InternalError,
+	websocket_server.ErrorCode_ServerError:                          JSONRPCError_ServerError,
+}
+
+var errorMapMutex sync.RWMutex
+
+// MapErrorCode specifies wire error code of application error code for JSON-RPC
+func MapErrorCode(code websocket_server.RPCErrorCode, jsonCode JSONRPCErrorCode) {
+	errorMapMutex.Lock()
+	JSONRPCErrorMap[code] = jsonCode
+	errorMapMutex.Unlock()
+}
+
+func GetErrorCode(code websocket_server.RPCErrorCode) JSONRPCErrorCode {
+
+	errorMapMutex.RLock()
+	defer errorMapMutex.RUnlock()
+
+	if c, ok := JSONRPCErrorMap[code]; ok {
+		return c
+	}
+
+	// using customized error code by default
+	return JSONRPCErrorCode(code)
+}
+
+// GetRPCErrorCode converts wire error code back, the most generic one is chosen if there are multiple candidates
+func GetRPCErrorCode(jsonCode JSONRPCErrorCode) websocket_server.RPCErrorCode {
+
+	errorMapMutex.RLock()
+	defer errorMapMutex.RUnlock()
+
+	found := false
+	var code websocket_server.RPCErrorCode
+	for c, jc := range JSONRPCErrorMap {
+		if jc == jsonCode && (!found || c < code) {
+			code = c
+			found = true
+		}
+	}
+
+	if !found {
+		return websocket_server.RPCErrorCode(jsonCode)
+	}
+
+	return code
 }
 
 type JSONRPCRequest struct {
-	JSONRPC string      `json:"jsonrpc"`
-	ID      int64       `json:"id"`
-	Method  string      `json:"method"`
-	Params  interface{} `json:"params"`
+	JSONRPC string              `json:"jsonrpc"`
+	ID      websocket_server.ID `json:"id"`
+	Method  string              `json:"method"`
+	Params  interface{}         `json:"params"`
+
+	// Optional deadline in milliseconds which is specified by client
+	Timeout int64 `json:"timeout,omitempty"`
+
+	// Response to call which was initiated by server
+	Result OptionalValue     `json:"result"`
+	Error  *JSONRPCErrorInfo `json:"error,omitempty"`
+}
+
+// OptionalValue keeps raw value and records whether the member is present even if it is null
+type OptionalValue struct {
+	Present bool
+	Raw     jsoniter.RawMessage
+}
+
+func (v *OptionalValue) UnmarshalJSON(data []byte) error {
+	v.Present = true
+	v.Raw = append(v.Raw[:0], data...)
+	return nil
+}
+
+type JSONRPCOutgoingRequest struct {
+	JSONRPC string              `json:"jsonrpc"`
+	ID      websocket_server.ID `json:"id"`
+	Method  string              `json:"method"`
+	Params  interface{}         `json:"params,omitempty"`
 }
 
 type JSONRPCResponse struct {
-	JSONRPC string      `json:"jsonrpc"`
-	ID      int64       `json:"id"`
-	Result  interface{} `json:"result"`
+	JSONRPC string              `json:"jsonrpc"`
+	ID      websocket_server.ID `json:"id"`
+	Result  interface{}         `json:"result"`
 }
 
 type JSONRPCErrorInfo struct {
@@ -50,9 +126,9 @@ type JSONRPCErrorInfo struct {
 }
 
 type JSONRPCError struct {
-	JSONRPC string           `json:"jsonrpc"`
-	ID      int64            `json:"id"`
-	Error   JSONRPCErrorInfo `json:"error"`
+	JSONRPC string              `json:"jsonrpc"`
+	ID      websocket_server.ID `json:"id"`
+	Error   JSONRPCErrorInfo    `json:"error"`
 }
 
 type NotificationEntry struct {
@@ -88,47 +164,169 @@ var notificationPool = sync.Pool{
 
 func (je *JSONRPC) ParseRequest(r io.Reader) (*websocket_server.RPCRequest, error) {
 
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || !json.Valid(data) {
+		return nil, websocket_server.NewError(websocket_server.ErrorCode_ParseError, nil)
+	}
+
+	if data[0] != '[' {
+		return je.parseRequest(data), nil
+	}
+
+	// Batch
+	var entries []jsoniter.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, websocket_server.NewError(websocket_server.ErrorCode_ParseError, nil)
+	}
+
+	if len(entries) == 0 {
+		return nil, websocket_server.NewError(websocket_server.ErrorCode_InvalidRequest, "empty batch")
+	}
+
+	batch := &websocket_server.RPCRequest{
+		Batch: make([]*websocket_server.RPCRequest, 0, len(entries)),
+	}
+
+	for _, entry := range entries {
+		batch.Batch = append(batch.Batch, je.parseRequest(entry))
+	}
+
+	return batch, nil
+}
+
+// parseRequest always returns request, the error will be attached to the request if it is invalid
+func (je *JSONRPC) parseRequest(data []byte) *websocket_server.RPCRequest {
+
 	// Allocate request object
 	jreq := rpcRequestPool.Get().(*JSONRPCRequest)
+	jreq.JSONRPC = ""
+	jreq.ID = websocket_server.ID{}
+	jreq.Method = ""
 	jreq.Params = nil
+	jreq.Timeout = 0
+	jreq.Result.Present = false
+	jreq.Error = nil
+
+	defer rpcRequestPool.Put(jreq)
 
 	// Attempt to decode
-	err := json.NewDecoder(r).Decode(jreq)
-	if err == io.EOF {
+	err := json.Unmarshal(data, jreq)
+	if err != nil {
+		return je.invalidRequest(je.detectID(data), "invalid request object")
+	}
 
-		rpcRequestPool.Put(jreq)
+	if jreq.JSONRPC != "2.0" {
+		return je.invalidRequest(jreq.ID, "unsupported jsonrpc version")
+	}
 
-		// One value is expected in the message.
-		err = io.ErrUnexpectedEOF
+	if len(jreq.Method) == 0 && (jreq.Result.Present || jreq.Error != nil) {
+		return je.parseResponse(jreq)
+	}
 
-		return nil, err
+	if len(jreq.Method) == 0 {
+		return je.invalidRequest(jreq.ID, "method is required")
 	}
 
-	if err != nil {
-		rpcRequestPool.Put(jreq)
-		return nil, err
+	// Create standard request
+	req := &websocket_server.RPCRequest{
+		ID:      jreq.ID,
+		Method:  jreq.Method,
+		Params:  make([]interface{}, 0),
+		Timeout: time.Duration(jreq.Timeout) * time.Millisecond,
+
+		// Request without id member is a notification
+		Notification: jreq.ID.IsAbsent(),
 	}
 
 	// Prepare parameters
-	v := reflect.ValueOf(jreq.Params)
-	params := make([]interface{}, 0)
-	switch v.Kind() {
-	case reflect.Slice:
-		fallthrough
-	case reflect.Array:
-		params = jreq.Params.([]interface{})
+	switch params := jreq.Params.(type) {
+	case nil:
+	case []interface{}:
+		req.Params = params
+	case map[string]interface{}:
+		req.NamedParams = params
 	default:
-		params = append(params, jreq.Params)
+		// Params must be a structured value, notification is still not responded
+		req.Error = websocket_server.NewError(websocket_server.ErrorCode_InvalidRequest, "params must be an array or object")
 	}
 
-	// Create standard request
-	req := &websocket_server.RPCRequest{
-		ID:     jreq.ID,
-		Method: jreq.Method,
-		Params: params,
+	return req
+}
+
+func (je *JSONRPC) parseResponse(jreq *JSONRPCRequest) *websocket_server.RPCRequest {
+
+	res := &websocket_server.RPCResponse{
+		ID: jreq.ID,
+	}
+
+	if jreq.Error != nil {
+		res.Error = &websocket_server.RPCError{
+			Code:    GetRPCErrorCode(jreq.Error.Code),
+			Message: jreq.Error.Message,
+			Data:    jreq.Error.Data,
+		}
+	} else if err := json.Unmarshal(jreq.Result.Raw, &res.Result); err != nil {
+		return je.invalidRequest(jreq.ID, "invalid result")
+	}
+
+	return &websocket_server.RPCRequest{
+		ID:       jreq.ID,
+		Response: res,
+	}
+}
+
+func (je *JSONRPC) detectID(data []byte) websocket_server.ID {
+
+	var entry struct {
+		ID websocket_server.ID `json:"id"`
+	}
+
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return websocket_server.NullID
+	}
+
+	return entry.ID
+}
+
+func (je *JSONRPC) invalidRequest(id websocket_server.ID, reason string) *websocket_server.RPCRequest {
+
+	if id.IsAbsent() {
+		id = websocket_server.NullID
+	}
+
+	return &websocket_server.RPCRequest{
+		ID:    id,
+		Error: websocket_server.NewError(websocket_server.ErrorCode_InvalidRequest, reason),
+	}
+}
+
+func (je *JSONRPC) ConvertErrorCode(code websocket_server.RPCErrorCode) int64 {
+	return int64(GetErrorCode(code))
+}
+
+func (je *JSONRPC) IsBinary() bool {
+	return false
+}
+
+func (je *JSONRPC) PrepareRequest(req *websocket_server.RPCRequest) ([]byte, error) {
+
+	entry := &JSONRPCOutgoingRequest{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Method:  req.Method,
+		Params:  req.Params,
 	}
 
-	return req, nil
+	if req.HasNamedParams() {
+		entry.Params = req.NamedParams
+	}
+
+	return json.Marshal(entry)
 }
 
 func (je *JSONRPC) PrepareResponse(res *websocket_server.RPCResponse) ([]byte, error) {
@@ -146,23 +344,36 @@ func (je *JSONRPC) PrepareResponse(res *websocket_server.RPCResponse) ([]byte, e
 	return data, nil
 }
 
-func (je *JSONRPC) createErrorFromObject(id int64, err interface{}) ([]byte, error) {
+func (je *JSONRPC) PrepareBatchResponse(responses []*websocket_server.RPCResponse) ([]byte, error) {
+
+	var buf bytes.Buffer
+
+	buf.WriteByte('[')
 
-	switch err.(type) {
-	case *websocket_server.RPCError:
-		rpcError := err.(*websocket_server.RPCError)
+	for i, res := range responses {
 
-		// using customized error code by default
-		code := JSONRPCErrorCode(rpcError.Code)
-		if JSONRPCErrorCode(len(JSONRPCErrorMap)) > code {
-			// Convert to standard JSON-RPC error code
-			code = JSONRPCErrorMap[code]
+		data, err := je.PrepareResponse(res)
+		if err != nil {
+			return []byte(""), err
 		}
 
-		return je.createError(id, code, rpcError.Message, rpcError.Data)
-	default:
-		return je.createError(id, JSONRPCError_InternalError, "Internal error", err.(error).Error())
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		buf.Write(data)
 	}
+
+	buf.WriteByte(']')
+
+	return buf.Bytes(), nil
+}
+
+func (je *JSONRPC) createErrorFromObject(id websocket_server.ID, err error) ([]byte, error) {
+
+	rpcError := websocket_server.ToRPCError(err)
+
+	return je.createError(id, GetErrorCode(rpcError.Code), rpcError.Message, rpcError.Data)
 }
 
 func (je *JSONRPC) PrepareNotification(eventName string, payload interface{}) ([]byte, error) {
@@ -185,7 +396,7 @@ func (je *JSONRPC) PrepareNotification(eventName string, payload interface{}) ([
 	return jsonStr, nil
 }
 
-func (je *JSONRPC) createResponse(id int64, result interface{}) ([]byte, error) {
+func (je *JSONRPC) createResponse(id websocket_server.ID, result interface{}) ([]byte, error) {
 
 	// Create or get response object from pool
 	response := responsePool.Get().(*JSONRPCResponse)
@@ -205,7 +416,7 @@ func (je *JSONRPC) createResponse(id int64, result interface{}) ([]byte, error)
 	return jsonStr, nil
 }
 
-func (je *JSONRPC) createError(id int64, code JSONRPCErrorCode, message string, errData interface{}) ([]byte, error) {
+func (je *JSONRPC) createError(id websocket_server.ID, code JSONRPCErrorCode, message string, errData interface{}) ([]byte, error) {
 
 	errorEntry := JSONRPCError{
 		JSONRPC: "2.0",
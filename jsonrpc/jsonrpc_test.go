@@ -0,0 +1,102 @@
+package jsonrpc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+func parse(t *testing.T, msg string) *websocket_server.RPCRequest {
+
+	req, err := (&JSONRPC{}).ParseRequest(strings.NewReader(msg))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return req
+}
+
+func TestParseParams(t *testing.T) {
+
+	req := parse(t, `{"jsonrpc":"2.0","id":1,"method":"m","params":{"a":1,"b":"x"}}`)
+	if !req.HasNamedParams() || !reflect.DeepEqual(req.NamedParams, map[string]interface{}{"a": float64(1), "b": "x"}) {
+		t.Fatalf("unexpected named params %v", req.NamedParams)
+	}
+
+	req = parse(t, `{"jsonrpc":"2.0","id":1,"method":"m","params":[1,"x"]}`)
+	if req.HasNamedParams() || !reflect.DeepEqual(req.Params, []interface{}{float64(1), "x"}) {
+		t.Fatalf("unexpected params %v", req.Params)
+	}
+
+	// Omitted params are empty positional params
+	req = parse(t, `{"jsonrpc":"2.0","id":1,"method":"m"}`)
+	if req.HasNamedParams() || !reflect.DeepEqual(req.Params, []interface{}{}) {
+		t.Fatalf("unexpected params %v", req.Params)
+	}
+}
+
+func TestPrepareRequest(t *testing.T) {
+
+	je := &JSONRPC{}
+
+	data, err := je.PrepareRequest(&websocket_server.RPCRequest{
+		ID:          websocket_server.NewIntID(7),
+		Method:      "m",
+		NamedParams: map[string]interface{}{"a": "b"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := parse(t, string(data))
+	if req.ID != websocket_server.NewIntID(7) || req.Method != "m" || req.NamedParams["a"] != "b" {
+		t.Fatalf("unexpected request %s", data)
+	}
+}
+
+func TestErrorCodeMapping(t *testing.T) {
+
+	const code websocket_server.RPCErrorCode = 9200
+
+	if c := GetErrorCode(websocket_server.ErrorCode_InvalidParams_Insufficient_Arguments); c != JSONRPCError_InvalidParams {
+		t.Fatalf("unexpected code %d", c)
+	}
+
+	// The most generic code is chosen when converting back
+	if c := GetRPCErrorCode(JSONRPCError_InvalidParams); c != websocket_server.ErrorCode_InvalidParams {
+		t.Fatalf("unexpected code %d", c)
+	}
+
+	if c := GetErrorCode(code); c != JSONRPCErrorCode(code) {
+		t.Fatalf("unexpected code %d", c)
+	}
+
+	MapErrorCode(code, -32001)
+	t.Cleanup(func() {
+		errorMapMutex.Lock()
+		delete(JSONRPCErrorMap, code)
+		errorMapMutex.Unlock()
+	})
+
+	if c := GetErrorCode(code); c != -32001 {
+		t.Fatalf("unexpected code %d", c)
+	}
+
+	if c := GetRPCErrorCode(-32001); c != code {
+		t.Fatalf("unexpected code %d", c)
+	}
+
+	data, err := (&JSONRPC{}).PrepareResponse(&websocket_server.RPCResponse{
+		ID:    websocket_server.NewIntID(1),
+		Error: websocket_server.NewError(code, "x"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != `{"jsonrpc":"2.0","id":1,"error":{"code":-32001,"message":"","data":"x"}}` {
+		t.Fatalf("unexpected response %s", data)
+	}
+}
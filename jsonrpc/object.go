@@ -0,0 +1,255 @@
+package jsonrpc
+
+import (
+	"time"
+
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+// Binary backends decode messages into generic maps, their objects share the members of JSON-RPC 2.0
+// without "jsonrpc" version:
+//
+//	request:      {"id": 1, "method": "Foo", "params": [...]}
+//	notification: {"method": "Foo", "params": {...}}
+//	response:     {"id": 1, "result": ...}
+//	error:        {"id": 1, "error": {"code": -32601, "message": "Method not found"}}
+
+type RequestObject struct {
+	ID     websocket_server.ID `json:"id"`
+	Method string              `json:"method"`
+	Params interface{}         `json:"params,omitempty"`
+}
+
+type NotificationObject struct {
+	Method string      `json:"method"`
+	Params interface{} `json:"params"`
+}
+
+type ResponseObject struct {
+	ID     websocket_server.ID `json:"id"`
+	Result interface{}         `json:"result"`
+}
+
+type ErrorObject struct {
+	ID    websocket_server.ID `json:"id"`
+	Error *ErrorObjectInfo    `json:"error"`
+}
+
+type ErrorObjectInfo struct {
+	Code    int64       `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func NewRequestObject(req *websocket_server.RPCRequest) *RequestObject {
+
+	obj := &RequestObject{
+		ID:     req.ID,
+		Method: req.Method,
+		Params: req.Params,
+	}
+
+	if req.HasNamedParams() {
+		obj.Params = req.NamedParams
+	}
+
+	return obj
+}
+
+func NewNotificationObject(eventName string, payload interface{}) *NotificationObject {
+	return &NotificationObject{
+		Method: eventName,
+		Params: payload,
+	}
+}
+
+// NewResponseObject returns error object if response carries error
+func NewResponseObject(res *websocket_server.RPCResponse) interface{} {
+
+	if res.Error != nil {
+		return NewErrorObject(res.ID, res.Error)
+	}
+
+	return &ResponseObject{
+		ID:     res.ID,
+		Result: res.Result,
+	}
+}
+
+func NewErrorObject(id websocket_server.ID, err error) *ErrorObject {
+
+	rpcError := websocket_server.ToRPCError(err)
+
+	return &ErrorObject{
+		ID: id,
+		Error: &ErrorObjectInfo{
+			Code:    int64(GetErrorCode(rpcError.Code)),
+			Message: rpcError.Message,
+			Data:    rpcError.Data,
+		},
+	}
+}
+
+// ParseObject converts decoded message which is either an object or a batch of objects into request
+func ParseObject(message interface{}) (*websocket_server.RPCRequest, error) {
+
+	entries, ok := message.([]interface{})
+	if !ok {
+		return parseObject(message), nil
+	}
+
+	// Batch
+	if len(entries) == 0 {
+		return nil, websocket_server.NewError(websocket_server.ErrorCode_InvalidRequest, "empty batch")
+	}
+
+	batch := &websocket_server.RPCRequest{
+		Batch: make([]*websocket_server.RPCRequest, 0, len(entries)),
+	}
+
+	for _, entry := range entries {
+		batch.Batch = append(batch.Batch, parseObject(entry))
+	}
+
+	return batch, nil
+}
+
+// parseObject always returns request, the error will be attached to the request if it is invalid
+func parseObject(message interface{}) *websocket_server.RPCRequest {
+
+	obj, ok := message.(map[string]interface{})
+	if !ok {
+		return invalidObject(websocket_server.NullID, "invalid request object")
+	}
+
+	id := websocket_server.ID{}
+	if val, ok := obj["id"]; ok {
+		i, err := websocket_server.NewIDFromValue(val)
+		if err != nil {
+			return invalidObject(websocket_server.NullID, "invalid id")
+		}
+
+		id = i
+	}
+
+	method, _ := obj["method"].(string)
+
+	// Response to call which was initiated by server
+	if len(method) == 0 {
+		if res, ok := parseResponseObject(id, obj); ok {
+			return &websocket_server.RPCRequest{
+				ID:       id,
+				Response: res,
+			}
+		}
+
+		return invalidObject(id, "method is required")
+	}
+
+	req := &websocket_server.RPCRequest{
+		ID:           id,
+		Method:       method,
+		Params:       make([]interface{}, 0),
+		Notification: id.IsAbsent(),
+	}
+
+	if val, ok := obj["timeout"]; ok {
+		timeout, ok := toInt64(val)
+		if !ok {
+			return invalidObject(id, "invalid timeout")
+		}
+
+		req.Timeout = time.Duration(timeout) * time.Millisecond
+	}
+
+	// Prepare parameters
+	switch params := obj["params"].(type) {
+	case nil:
+	case []interface{}:
+		req.Params = params
+	case map[string]interface{}:
+		req.NamedParams = params
+	default:
+		// Params must be a structured value, notification is still not responded
+		req.Error = websocket_server.NewError(websocket_server.ErrorCode_InvalidRequest, "params must be an array or map")
+	}
+
+	return req
+}
+
+func parseResponseObject(id websocket_server.ID, obj map[string]interface{}) (*websocket_server.RPCResponse, bool) {
+
+	res := &websocket_server.RPCResponse{
+		ID: id,
+	}
+
+	if val, ok := obj["error"]; ok {
+
+		info, ok := val.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		code, _ := toInt64(info["code"])
+		message, _ := info["message"].(string)
+
+		res.Error = &websocket_server.RPCError{
+			Code:    GetRPCErrorCode(JSONRPCErrorCode(code)),
+			Message: message,
+			Data:    info["data"],
+		}
+
+		return res, true
+	}
+
+	result, ok := obj["result"]
+	if !ok {
+		return nil, false
+	}
+
+	res.Result = result
+
+	return res, true
+}
+
+func invalidObject(id websocket_server.ID, reason string) *websocket_server.RPCRequest {
+
+	if id.IsAbsent() {
+		id = websocket_server.NullID
+	}
+
+	return &websocket_server.RPCRequest{
+		ID:    id,
+		Error: websocket_server.NewError(websocket_server.ErrorCode_InvalidRequest, reason),
+	}
+}
+
+func toInt64(v interface{}) (int64, bool) {
+
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint8:
+		return int64(n), true
+	case uint16:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case uint64:
+		return int64(n), true
+	case float32:
+		return int64(n), true
+	case float64:
+		return int64(n), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,228 @@
+package mqtt_adapter
+
+import (
+	"errors"
+	"sync"
+)
+
+// QoS 2 is not supported
+const MaxQoS byte = 1
+
+const (
+	RetainHandling_Send      byte = 0
+	RetainHandling_SendIfNew      = 1
+	RetainHandling_DoNotSend      = 2
+)
+
+var (
+	ErrInvalidTopic    = errors.New("mqtt: invalid topic")
+	ErrQoSNotSupported = errors.New("mqtt: qos is not supported")
+)
+
+type Message struct {
+	Topic   string
+	Payload []byte
+	QoS     byte
+	Retain  bool
+}
+
+// Subscriber receives messages of topics it subscribed from broker
+type Subscriber interface {
+	Deliver(msg *Message, qos byte, retain bool) error
+}
+
+type subscription struct {
+	filter            string
+	qos               byte
+	noLocal           bool
+	retainAsPublished bool
+}
+
+// Broker routes messages between subscribers, it can be shared by multiple adapters. QoS 2 is
+// not supported.
+type Broker struct {
+	mutex         sync.RWMutex
+	subscriptions map[Subscriber]map[string]*subscription
+	retained      map[string]*Message
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		subscriptions: make(map[Subscriber]map[string]*subscription),
+		retained:      make(map[string]*Message),
+	}
+}
+
+// Publish sends message to all subscribers of the topic
+func (b *Broker) Publish(topic string, payload []byte, qos byte, retain bool) error {
+	return b.publish(&Message{
+		Topic:   topic,
+		Payload: payload,
+		QoS:     qos,
+		Retain:  retain,
+	}, nil)
+}
+
+func (b *Broker) publish(msg *Message, from Subscriber) error {
+
+	if !ValidTopic(msg.Topic) {
+		return ErrInvalidTopic
+	}
+
+	if msg.QoS > MaxQoS {
+		return ErrQoSNotSupported
+	}
+
+	type delivery struct {
+		subscriber Subscriber
+		sub        *subscription
+	}
+
+	deliveries := make([]delivery, 0)
+
+	b.mutex.Lock()
+
+	if msg.Retain {
+		if len(msg.Payload) == 0 {
+			delete(b.retained, msg.Topic)
+		} else {
+			b.retained[msg.Topic] = msg
+		}
+	}
+
+	for subscriber, subs := range b.subscriptions {
+
+		// Subscriber receives only one copy with the maximum QoS of overlapping subscriptions
+		var matched *subscription
+		for _, sub := range subs {
+
+			if sub.noLocal && subscriber == from {
+				continue
+			}
+
+			if !MatchTopic(sub.filter, msg.Topic) {
+				continue
+			}
+
+			if matched == nil || sub.qos > matched.qos {
+				matched = sub
+			}
+		}
+
+		if matched != nil {
+			deliveries = append(deliveries, delivery{
+				subscriber: subscriber,
+				sub:        matched,
+			})
+		}
+	}
+
+	b.mutex.Unlock()
+
+	var err error
+	for _, d := range deliveries {
+
+		qos := msg.QoS
+		if d.sub.qos < qos {
+			qos = d.sub.qos
+		}
+
+		// Failure of a subscriber should not affect others, the first one is reported
+		if e := d.subscriber.Deliver(msg, qos, msg.Retain && d.sub.retainAsPublished); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+// Subscribe adds subscription and returns granted QoS with retained messages according to retain
+// handling, caller is responsible for delivering them after the subscription is acknowledged.
+func (b *Broker) Subscribe(subscriber Subscriber, req *SubscribeRequest) (byte, []*Message, error) {
+
+	if !ValidFilter(req.Filter) {
+		return 0, nil, ErrInvalidTopic
+	}
+
+	qos := req.QoS
+	if qos > MaxQoS {
+		qos = MaxQoS
+	}
+
+	b.mutex.Lock()
+
+	subs, ok := b.subscriptions[subscriber]
+	if !ok {
+		subs = make(map[string]*subscription)
+		b.subscriptions[subscriber] = subs
+	}
+
+	_, exists := subs[req.Filter]
+
+	subs[req.Filter] = &subscription{
+		filter:            req.Filter,
+		qos:               qos,
+		noLocal:           req.NoLocal,
+		retainAsPublished: req.RetainAsPublished,
+	}
+
+	retained := make([]*Message, 0)
+	if req.RetainHandling == RetainHandling_Send || (req.RetainHandling == RetainHandling_SendIfNew && !exists) {
+		for topic, msg := range b.retained {
+			if MatchTopic(req.Filter, topic) {
+				retained = append(retained, msg)
+			}
+		}
+	}
+
+	b.mutex.Unlock()
+
+	return qos, retained, nil
+}
+
+// Unsubscribe removes subscription, false is returned if it did not exist
+func (b *Broker) Unsubscribe(subscriber Subscriber, filter string) bool {
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	subs, ok := b.subscriptions[subscriber]
+	if !ok {
+		return false
+	}
+
+	if _, ok := subs[filter]; !ok {
+		return false
+	}
+
+	delete(subs, filter)
+
+	return true
+}
+
+// move hands subscriptions over to the subscriber which resumed the session
+func (b *Broker) move(from Subscriber, to Subscriber) {
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if subs, ok := b.subscriptions[from]; ok {
+		delete(b.subscriptions, from)
+		b.subscriptions[to] = subs
+	}
+}
+
+// Remove removes all subscriptions of subscriber
+func (b *Broker) Remove(subscriber Subscriber) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	delete(b.subscriptions, subscriber)
+}
+
+// GetRetained returns retained message of the topic
+func (b *Broker) GetRetained(topic string) (*Message, bool) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	msg, ok := b.retained[topic]
+	return msg, ok
+}
@@ -0,0 +1,708 @@
+package mqtt_adapter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+// Subprotocol should be accepted by endpoint for MQTT clients
+const Subprotocol = "mqtt"
+
+const DefaultMaxPacketSize = 1 << 20
+
+// DefaultRetryInterval is the time to wait for PUBACK before QoS 1 message is sent again
+const DefaultRetryInterval = 20 * time.Second
+
+// DefaultMaxQueuedMessages limits QoS 1 messages which are waiting to be sent for each session
+const DefaultMaxQueuedMessages = 1000
+
+const keepAliveCheckInterval = time.Second
+
+// Return codes of CONNACK
+const (
+	connackAccepted             byte = 0x00
+	connackUnacceptableProtocol      = 0x01
+	connackIdentifierRejected        = 0x02
+	connackNotAuthorized             = 0x05
+	connackNotAuthorized_v5          = 0x87
+	connackQoSNotSupported_v5        = 0x9b
+)
+
+// Reason codes of MQTT 5
+const (
+	reasonSuccess              byte = 0x00
+	reasonDisconnectWithWill        = 0x04
+	reasonNoSubscriptionExists      = 0x11
+	reasonProtocolError             = 0x82
+	reasonKeepAliveTimeout          = 0x8d
+	reasonSessionTakenOver          = 0x8e
+	reasonTopicFilterInvalid        = 0x8f
+	reasonTopicNameInvalid          = 0x90
+	reasonPacketTooLarge            = 0x95
+	reasonQoSNotSupported           = 0x9b
+	subackFailure                   = 0x80
+)
+
+var (
+	ErrProtocolViolation = errors.New("mqtt: protocol violation")
+	ErrPacketTooLarge    = errors.New("mqtt: packet too large")
+	ErrDisconnected      = errors.New("mqtt: disconnected")
+	ErrConnectRejected   = errors.New("mqtt: connect rejected")
+	ErrQueueFull         = errors.New("mqtt: message queue is full")
+)
+
+type MQTTAdapterOpt func(*MQTTAdapter)
+type MessageHandler func(*websocket_server.Context) (interface{}, error)
+
+// AuthFunc verifies CONNECT packet, connection will be rejected if error is returned
+type AuthFunc func(c websocket_server.Client, cp *ConnectPacket) error
+
+type handlerEntry struct {
+	filter string
+	fn     MessageHandler
+}
+
+type MQTTAdapter struct {
+	broker         *Broker
+	requestQueue   *websocket_server.RequestQueue
+	handlers       map[string]*handlerEntry
+	sessions       map[uuid.UUID]*session
+	clientIDs      map[string]*session
+	persisted      map[string]*session
+	authenticator  AuthFunc
+	maxPacketSize  int
+	receiveMaximum int
+	maxQueued      int
+	retryInterval  time.Duration
+	done           chan struct{}
+	closeOnce      sync.Once
+	mutex          sync.RWMutex
+	sessionMutex   sync.RWMutex
+}
+
+// WithBroker shares broker with other adapters, a new broker is created by default
+func WithBroker(b *Broker) MQTTAdapterOpt {
+	return func(ma *MQTTAdapter) {
+		ma.broker = b
+	}
+}
+
+func WithAuthenticator(fn AuthFunc) MQTTAdapterOpt {
+	return func(ma *MQTTAdapter) {
+		ma.authenticator = fn
+	}
+}
+
+func WithMaxPacketSize(size int) MQTTAdapterOpt {
+	return func(ma *MQTTAdapter) {
+		ma.maxPacketSize = size
+	}
+}
+
+// WithReceiveMaximum limits QoS 1 messages which are in flight for each client, the smaller one of
+// this and the receive maximum of client is used
+func WithReceiveMaximum(n int) MQTTAdapterOpt {
+	return func(ma *MQTTAdapter) {
+		ma.receiveMaximum = n
+	}
+}
+
+// WithMaxQueuedMessages limits QoS 1 messages which are waiting for a slot of receive maximum or for
+// client to reconnect, messages are dropped if the queue is full
+func WithMaxQueuedMessages(n int) MQTTAdapterOpt {
+	return func(ma *MQTTAdapter) {
+		ma.maxQueued = n
+	}
+}
+
+// WithRetryInterval sets the time to wait for PUBACK before message is sent again, zero disables
+// retries so messages are only resent when client reconnects
+func WithRetryInterval(d time.Duration) MQTTAdapterOpt {
+	return func(ma *MQTTAdapter) {
+		ma.retryInterval = d
+	}
+}
+
+func New(opts ...MQTTAdapterOpt) *MQTTAdapter {
+
+	ma := &MQTTAdapter{
+		requestQueue:   websocket_server.NewRequestQueue(),
+		handlers:       make(map[string]*handlerEntry),
+		sessions:       make(map[uuid.UUID]*session),
+		clientIDs:      make(map[string]*session),
+		persisted:      make(map[string]*session),
+		maxPacketSize:  DefaultMaxPacketSize,
+		receiveMaximum: DefaultReceiveMaximum,
+		maxQueued:      DefaultMaxQueuedMessages,
+		retryInterval:  DefaultRetryInterval,
+		done:           make(chan struct{}),
+	}
+
+	for _, o := range opts {
+		o(ma)
+	}
+
+	if ma.broker == nil {
+		ma.broker = NewBroker()
+	}
+
+	ma.requestQueue.Consume(ma.consume)
+
+	go ma.watch()
+
+	return ma
+}
+
+// Close stops checking keep alive and resending messages, connected clients are not closed
+func (ma *MQTTAdapter) Close() {
+	ma.closeOnce.Do(func() {
+		close(ma.done)
+	})
+}
+
+func (ma *MQTTAdapter) GetBroker() *Broker {
+	return ma.broker
+}
+
+// GetMessage returns the message which is handled by the context
+func GetMessage(c *websocket_server.Context) *Message {
+	msg, _ := c.Param(0).(*Message)
+	return msg
+}
+
+// Handle registers handler for messages which are published by clients to topics matching the filter
+func (ma *MQTTAdapter) Handle(filter string, fn MessageHandler) error {
+
+	if !ValidFilter(filter) {
+		return ErrInvalidTopic
+	}
+
+	ma.mutex.Lock()
+	defer ma.mutex.Unlock()
+
+	ma.handlers[filter] = &handlerEntry{
+		filter: filter,
+		fn:     fn,
+	}
+
+	return nil
+}
+
+func (ma *MQTTAdapter) Unhandle(filter string) {
+	ma.mutex.Lock()
+	defer ma.mutex.Unlock()
+	delete(ma.handlers, filter)
+}
+
+func (ma *MQTTAdapter) getHandlers(topic string) []*handlerEntry {
+
+	ma.mutex.RLock()
+	defer ma.mutex.RUnlock()
+
+	entries := make([]*handlerEntry, 0)
+	for _, entry := range ma.handlers {
+		if MatchTopic(entry.filter, topic) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+// Publish sends message to clients which subscribed the topic
+func (ma *MQTTAdapter) Publish(topic string, payload []byte, qos byte, retain bool) error {
+	return ma.broker.Publish(topic, payload, qos, retain)
+}
+
+func (ma *MQTTAdapter) HandleMessage(c websocket_server.Client) error {
+
+	data, err := io.ReadAll(c.GetReader())
+	if err != nil {
+		return err
+	}
+
+	s := ma.getSession(c)
+	s.touch()
+
+	s.buffer = append(s.buffer, data...)
+
+	for {
+
+		p, n, err := ReadPacket(s.buffer)
+		if err != nil {
+			return err
+		}
+
+		// Waiting for the rest of packet
+		if n == 0 {
+			break
+		}
+
+		if err := ma.handlePacket(s, p); err != nil {
+			return err
+		}
+
+		s.buffer = s.buffer[n:]
+	}
+
+	if len(s.buffer) > ma.maxPacketSize {
+		s.disconnect(reasonPacketTooLarge)
+		return ErrPacketTooLarge
+	}
+
+	// Release consumed packets
+	s.buffer = append([]byte(nil), s.buffer...)
+
+	return nil
+}
+
+// handlePacket returns error only if the connection should be closed
+func (ma *MQTTAdapter) handlePacket(s *session, p *Packet) error {
+
+	if len(p.Body) > ma.maxPacketSize {
+		s.disconnect(reasonPacketTooLarge)
+		return ErrPacketTooLarge
+	}
+
+	// The first packet must be CONNECT, and it is allowed only once
+	if p.Type == PacketType_Connect || !s.isConnected() {
+		if p.Type != PacketType_Connect || s.isConnected() {
+			return ErrProtocolViolation
+		}
+
+		return ma.connect(s, p)
+	}
+
+	level := s.getLevel()
+
+	switch p.Type {
+	case PacketType_Publish:
+		return ma.publish(s, p, level)
+	case PacketType_Puback:
+		d := &decoder{data: p.Body}
+		packetID := d.uint16()
+		if d.err != nil {
+			return d.err
+		}
+
+		return s.ack(packetID)
+	case PacketType_Subscribe:
+		return ma.subscribe(s, p, level)
+	case PacketType_Unsubscribe:
+		return ma.unsubscribe(s, p, level)
+	case PacketType_Pingreq:
+		return s.client.Send((&Packet{Type: PacketType_Pingresp}).Marshal())
+	case PacketType_Disconnect:
+
+		// Will message is discarded unless client asks for it explicitly
+		if level != ProtocolLevel_5 || len(p.Body) == 0 || p.Body[0] != reasonDisconnectWithWill {
+			s.clearWill()
+		}
+
+		return ErrDisconnected
+	}
+
+	// QoS 2 flows and enhanced authentication are not supported
+	s.disconnect(reasonProtocolError)
+
+	return ErrProtocolViolation
+}
+
+func (ma *MQTTAdapter) connect(s *session, p *Packet) error {
+
+	cp, err := decodeConnect(p)
+	if err != nil {
+		return err
+	}
+
+	if cp.ProtocolName != "MQTT" || (cp.ProtocolLevel != ProtocolLevel_311 && cp.ProtocolLevel != ProtocolLevel_5) {
+		s.client.Send(encodeConnack(connackUnacceptableProtocol, false, ProtocolLevel_311, nil))
+		return ErrConnectRejected
+	}
+
+	level := cp.ProtocolLevel
+	props := make([]byte, 0)
+
+	clientID := cp.ClientID
+	if len(clientID) == 0 {
+
+		// Persistent session requires client identifier in MQTT 3.1.1
+		if level == ProtocolLevel_311 && !cp.CleanSession {
+			s.client.Send(encodeConnack(connackIdentifierRejected, false, level, nil))
+			return ErrConnectRejected
+		}
+
+		clientID = uuid.New().String()
+
+		if level == ProtocolLevel_5 {
+			props = append(props, propertyAssignedClientID)
+			props = (&encoder{buf: props}).string(clientID).buf
+		}
+	}
+
+	if cp.Will != nil && (cp.Will.QoS > MaxQoS || !ValidTopic(cp.Will.Topic)) {
+		if level == ProtocolLevel_5 {
+			s.client.Send(encodeConnack(connackQoSNotSupported_v5, false, level, nil))
+		}
+
+		return ErrConnectRejected
+	}
+
+	if ma.authenticator != nil {
+		if err := ma.authenticator(s.client, cp); err != nil {
+
+			code := byte(connackNotAuthorized)
+			if level == ProtocolLevel_5 {
+				code = connackNotAuthorized_v5
+			}
+
+			s.client.Send(encodeConnack(code, false, level, nil))
+
+			return ErrConnectRejected
+		}
+	}
+
+	if !s.connect(cp, clientID, ma.receiveMaximum, ma.maxQueued) {
+		return ErrProtocolViolation
+	}
+
+	sessionPresent := ma.takeover(s, clientID, cp.CleanSession)
+
+	props = append(props, propertyMaximumQoS, MaxQoS, propertyRetainAvailable, 1)
+
+	if err := s.client.Send(encodeConnack(connackAccepted, sessionPresent, level, props)); err != nil {
+		return err
+	}
+
+	// Unacknowledged and queued messages of resumed session are sent after CONNACK
+	return s.start()
+}
+
+// takeover disconnects existing client which has the same client identifier, and resumes state of
+// previous session unless client asks for a clean one. True is returned if session was resumed.
+func (ma *MQTTAdapter) takeover(s *session, clientID string, clean bool) bool {
+
+	ma.sessionMutex.Lock()
+	prev, ok := ma.clientIDs[clientID]
+	ma.clientIDs[clientID] = s
+	stored, persisted := ma.persisted[clientID]
+	delete(ma.persisted, clientID)
+	ma.sessionMutex.Unlock()
+
+	live := ok && prev != s
+
+	var old *session
+	if live {
+		old = prev
+	} else if persisted {
+		old = stored
+	}
+
+	resumed := false
+	if old != nil {
+		if clean {
+			ma.broker.Remove(old)
+			old.close()
+		} else {
+
+			// Messages for previous session are forwarded before its subscriptions are moved
+			s.resume(old)
+			ma.broker.move(old, s)
+			resumed = true
+		}
+	}
+
+	if live {
+		prev.disconnect(reasonSessionTakenOver)
+		prev.client.Close()
+	}
+
+	return resumed
+}
+
+func (ma *MQTTAdapter) publish(s *session, p *Packet, level byte) error {
+
+	msg, packetID, err := decodePublish(p, level)
+	if err != nil {
+		return err
+	}
+
+	if msg.QoS > MaxQoS {
+		s.disconnect(reasonQoSNotSupported)
+		return ErrQoSNotSupported
+	}
+
+	if !ValidTopic(msg.Topic) {
+		s.disconnect(reasonTopicNameInvalid)
+		return ErrInvalidTopic
+	}
+
+	// Packet identifier is required to acknowledge QoS 1 message
+	if msg.QoS > 0 && packetID == 0 {
+		s.disconnect(reasonProtocolError)
+		return ErrProtocolViolation
+	}
+
+	if msg.QoS > 0 {
+		if err := s.client.Send(encodeAck(PacketType_Puback, packetID, nil, level)); err != nil {
+			return err
+		}
+	}
+
+	// Message was validated already, failure of subscribers should not close the publisher
+	ma.broker.publish(msg, s)
+
+	if len(ma.getHandlers(msg.Topic)) > 0 {
+		req := &websocket_server.RPCRequest{
+			Method:       msg.Topic,
+			Params:       []interface{}{msg},
+			Notification: true,
+		}
+
+		ma.requestQueue.Push(websocket_server.NewContext(s.client, req))
+	}
+
+	return nil
+}
+
+func (ma *MQTTAdapter) subscribe(s *session, p *Packet, level byte) error {
+
+	packetID, reqs, err := decodeSubscribe(p, level)
+	if err != nil {
+		return err
+	}
+
+	type pending struct {
+		qos      byte
+		retained []*Message
+	}
+
+	codes := make([]byte, 0, len(reqs))
+	deliveries := make([]pending, 0, len(reqs))
+
+	for _, req := range reqs {
+
+		qos, retained, err := ma.broker.Subscribe(s, req)
+		if err != nil {
+			if level == ProtocolLevel_5 {
+				codes = append(codes, reasonTopicFilterInvalid)
+			} else {
+				codes = append(codes, subackFailure)
+			}
+
+			continue
+		}
+
+		codes = append(codes, qos)
+		deliveries = append(deliveries, pending{
+			qos:      qos,
+			retained: retained,
+		})
+	}
+
+	if err := s.client.Send(encodeAck(PacketType_Suback, packetID, codes, level)); err != nil {
+		return err
+	}
+
+	// Retained messages are sent after subscription is acknowledged
+	for _, d := range deliveries {
+		for _, msg := range d.retained {
+
+			qos := msg.QoS
+			if d.qos < qos {
+				qos = d.qos
+			}
+
+			s.Deliver(msg, qos, true)
+		}
+	}
+
+	return nil
+}
+
+func (ma *MQTTAdapter) unsubscribe(s *session, p *Packet, level byte) error {
+
+	packetID, filters, err := decodeUnsubscribe(p, level)
+	if err != nil {
+		return err
+	}
+
+	codes := make([]byte, 0, len(filters))
+	for _, filter := range filters {
+		if ma.broker.Unsubscribe(s, filter) {
+			codes = append(codes, reasonSuccess)
+		} else {
+			codes = append(codes, reasonNoSubscriptionExists)
+		}
+	}
+
+	// UNSUBACK has no payload in MQTT 3.1.1
+	if level != ProtocolLevel_5 {
+		codes = nil
+	}
+
+	return s.client.Send(encodeAck(PacketType_Unsuback, packetID, codes, level))
+}
+
+func (ma *MQTTAdapter) consume(c *websocket_server.Context) error {
+
+	defer c.Cancel()
+
+	msg := GetMessage(c)
+
+	var lastErr error
+	for _, entry := range ma.getHandlers(msg.Topic) {
+		if _, err := entry.fn(c); err != nil {
+			lastErr = err
+		}
+	}
+
+	return lastErr
+}
+
+func (ma *MQTTAdapter) watch() {
+
+	ticker := time.NewTicker(keepAliveCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ma.done:
+			return
+		case <-ticker.C:
+		}
+
+		ma.sessionMutex.RLock()
+		sessions := make([]*session, 0, len(ma.sessions))
+		for _, s := range ma.sessions {
+			sessions = append(sessions, s)
+		}
+		ma.sessionMutex.RUnlock()
+
+		now := time.Now().UnixNano()
+
+		// Will message is published when client is released
+		for _, s := range sessions {
+			if s.expired(now) {
+				s.disconnect(reasonKeepAliveTimeout)
+				s.client.Close()
+				continue
+			}
+
+			if ma.retryInterval > 0 {
+				s.resend(now - int64(ma.retryInterval))
+			}
+		}
+
+		ma.purge(now)
+	}
+}
+
+// purge discards persisted sessions which were expired
+func (ma *MQTTAdapter) purge(now int64) {
+
+	expired := make([]*session, 0)
+
+	ma.sessionMutex.Lock()
+	for clientID, s := range ma.persisted {
+		if s.stateExpired(now) {
+			delete(ma.persisted, clientID)
+			expired = append(expired, s)
+		}
+	}
+	ma.sessionMutex.Unlock()
+
+	for _, s := range expired {
+		ma.broker.Remove(s)
+		s.close()
+	}
+}
+
+func (ma *MQTTAdapter) getSession(c websocket_server.Client) *session {
+
+	ma.sessionMutex.Lock()
+	defer ma.sessionMutex.Unlock()
+
+	s, ok := ma.sessions[c.GetClientID()]
+	if !ok {
+		s = newSession(c)
+
+		// Client was closed already
+		if c.GetContext().Err() != nil {
+			return s
+		}
+
+		ma.sessions[c.GetClientID()] = s
+	}
+
+	return s
+}
+
+// Release publishes will message if client was disconnected unexpectedly, state of persistent session
+// is kept for client to resume it
+func (ma *MQTTAdapter) Release(c websocket_server.Client) {
+
+	ma.sessionMutex.Lock()
+
+	s, ok := ma.sessions[c.GetClientID()]
+	if !ok {
+		ma.sessionMutex.Unlock()
+		return
+	}
+
+	delete(ma.sessions, c.GetClientID())
+
+	owner := ma.clientIDs[s.clientID] == s
+	if owner {
+		delete(ma.clientIDs, s.clientID)
+	}
+
+	persisted := owner && s.detach()
+	if persisted {
+		ma.persisted[s.clientID] = s
+	}
+
+	ma.sessionMutex.Unlock()
+
+	if !persisted {
+		ma.broker.Remove(s)
+		s.close()
+	}
+
+	if will := s.takeWill(); will != nil {
+		ma.broker.publish(will, s)
+	}
+}
+
+// Call is not supported since MQTT has no request-response semantics
+func (ma *MQTTAdapter) Call(ctx context.Context, c websocket_server.Client, method string, params interface{}) (interface{}, error) {
+	return nil, websocket_server.ErrAdapterNotImplemented
+}
+
+// PrepareNotification is not supported since encoding depends on protocol version of client, Publish should be used instead
+func (ma *MQTTAdapter) PrepareNotification(eventName string, payload interface{}) ([]byte, error) {
+	return []byte(""), websocket_server.ErrAdapterNotImplemented
+}
+
+func (ma *MQTTAdapter) PrepareResponse(res *websocket_server.RPCResponse) ([]byte, error) {
+	return []byte(""), websocket_server.ErrAdapterNotImplemented
+}
+
+// Register is an alias of Handle for Adapter interface, options are unused
+func (ma *MQTTAdapter) Register(method string, fn websocket_server.RPCFunc, opts ...websocket_server.RPCMethodOpt) error {
+	return ma.Handle(method, MessageHandler(fn))
+}
+
+func (ma *MQTTAdapter) Unregister(method string) {
+	ma.Unhandle(method)
+}
+
+func (ma *MQTTAdapter) IsBinary() bool {
+	return true
+}
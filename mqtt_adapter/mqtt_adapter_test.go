@@ -0,0 +1,442 @@
+package mqtt_adapter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weedbox/websocket-modules/websocket_server"
+	"github.com/weedbox/websocket-modules/websocket_servertest"
+)
+
+// receive mimics the poller which closes client if message was failed to be handled
+func receive(c *websocket_servertest.Client, data []byte) error {
+
+	err := c.Receive(data)
+	if err != nil {
+		c.Close()
+	}
+
+	return err
+}
+
+func readPackets(t *testing.T, c *websocket_servertest.Client) []*Packet {
+
+	packets := make([]*Packet, 0)
+	for _, data := range c.GetFrames() {
+		for len(data) > 0 {
+			p, n, err := ReadPacket(data)
+			if err != nil || n == 0 {
+				t.Fatalf("malformed packet %v", err)
+			}
+
+			packets = append(packets, p)
+			data = data[n:]
+		}
+	}
+
+	return packets
+}
+
+func connectClient(t *testing.T, ma *MQTTAdapter, level byte, clientID string, keepAlive uint16, will *Message, props []byte) *websocket_servertest.Client {
+
+	c := websocket_servertest.NewClient(ma)
+
+	if err := receive(c, encodeConnect(level, clientID, keepAlive, will, props)); err != nil {
+		t.Fatal(err)
+	}
+
+	packets := readPackets(t, c)
+	if len(packets) != 1 || packets[0].Type != PacketType_Connack || packets[0].Body[1] != connackAccepted {
+		t.Fatalf("unexpected packets %v", packets)
+	}
+
+	c.Reset()
+
+	return c
+}
+
+// encodeResume is CONNECT which asks for the session of client identifier to be resumed
+func encodeResume(level byte, clientID string, props []byte) []byte {
+	p, _, _ := ReadPacket(encodeConnect(level, clientID, 0, nil, props))
+	p.Body[7] &^= 0x02
+	return p.Marshal()
+}
+
+func encodePuback(packetID uint16) []byte {
+	return (&Packet{Type: PacketType_Puback, Body: (&encoder{}).uint16(packetID).buf}).Marshal()
+}
+
+func subscribeClient(t *testing.T, c *websocket_servertest.Client, level byte, filter string, qos byte) {
+
+	e := &encoder{}
+	e.uint16(1)
+
+	if level == ProtocolLevel_5 {
+		e.properties(nil)
+	}
+
+	e.string(filter).byte(qos)
+
+	if err := receive(c, (&Packet{Type: PacketType_Subscribe, Flags: 0x02, Body: e.buf}).Marshal()); err != nil {
+		t.Fatal(err)
+	}
+
+	packets := readPackets(t, c)
+	if len(packets) != 1 || packets[0].Type != PacketType_Suback {
+		t.Fatalf("unexpected packets %v", packets)
+	}
+
+	c.Reset()
+}
+
+func deliveredMessages(t *testing.T, c *websocket_servertest.Client, level byte) ([]*Message, []uint16) {
+
+	msgs := make([]*Message, 0)
+	ids := make([]uint16, 0)
+
+	for _, p := range readPackets(t, c) {
+		if p.Type != PacketType_Publish {
+			continue
+		}
+
+		msg, packetID, err := decodePublish(p, level)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		msgs = append(msgs, msg)
+		ids = append(ids, packetID)
+	}
+
+	return msgs, ids
+}
+
+func TestPublishAndSubscribe(t *testing.T) {
+
+	ma := New()
+
+	handled := make(chan string, 1)
+	ma.Handle("sensors/+", func(c *websocket_server.Context) (interface{}, error) {
+		handled <- string(GetMessage(c).Payload)
+		return nil, nil
+	})
+
+	sub := connectClient(t, ma, ProtocolLevel_5, "sub", 0, nil, nil)
+	subscribeClient(t, sub, ProtocolLevel_5, "sensors/#", 1)
+
+	pub := connectClient(t, ma, ProtocolLevel_311, "pub", 0, nil, nil)
+
+	msg := &Message{Topic: "sensors/1", Payload: []byte("21.5"), QoS: 1}
+	if err := receive(pub, encodePublish(msg, 1, false, false, 5, ProtocolLevel_311)); err != nil {
+		t.Fatal(err)
+	}
+
+	packets := readPackets(t, pub)
+	if len(packets) != 1 || packets[0].Type != PacketType_Puback {
+		t.Fatalf("publish was not acknowledged: %v", packets)
+	}
+
+	msgs, ids := deliveredMessages(t, sub, ProtocolLevel_5)
+	if len(msgs) != 1 || string(msgs[0].Payload) != "21.5" || msgs[0].QoS != 1 || ids[0] == 0 {
+		t.Fatalf("unexpected messages %v %v", msgs, ids)
+	}
+
+	select {
+	case payload := <-handled:
+		if payload != "21.5" {
+			t.Fatalf("unexpected payload %s", payload)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler was not called")
+	}
+}
+
+func TestReceiveMaximum(t *testing.T) {
+
+	ma := New()
+
+	props := (&encoder{}).byte(propertyReceiveMaximum).uint16(2).buf
+	c := connectClient(t, ma, ProtocolLevel_5, "limited", 0, nil, props)
+	subscribeClient(t, c, ProtocolLevel_5, "news", 1)
+
+	for i := 0; i < 4; i++ {
+		if err := ma.Publish("news", []byte{byte('0' + i)}, 1, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The rest are queued since two are in flight
+	msgs, ids := deliveredMessages(t, c, ProtocolLevel_5)
+	if len(msgs) != 2 || string(msgs[1].Payload) != "1" {
+		t.Fatalf("unexpected messages %v", msgs)
+	}
+
+	c.Reset()
+
+	if err := receive(c, encodePuback(ids[0])); err != nil {
+		t.Fatal(err)
+	}
+
+	msgs, _ = deliveredMessages(t, c, ProtocolLevel_5)
+	if len(msgs) != 1 || string(msgs[0].Payload) != "2" {
+		t.Fatalf("unexpected messages %v", msgs)
+	}
+}
+
+func TestMaxQueuedMessages(t *testing.T) {
+
+	ma := New(WithReceiveMaximum(1), WithMaxQueuedMessages(1))
+
+	c := connectClient(t, ma, ProtocolLevel_311, "slow", 0, nil, nil)
+	subscribeClient(t, c, ProtocolLevel_311, "news", 1)
+
+	ma.Publish("news", []byte("0"), 1, false)
+	ma.Publish("news", []byte("1"), 1, false)
+
+	if err := ma.Publish("news", []byte("2"), 1, false); err != ErrQueueFull {
+		t.Fatalf("expected queue full, got %v", err)
+	}
+}
+
+func TestRetry(t *testing.T) {
+
+	ma := New(WithRetryInterval(time.Millisecond))
+
+	c := connectClient(t, ma, ProtocolLevel_5, "device", 0, nil, nil)
+	subscribeClient(t, c, ProtocolLevel_5, "news", 1)
+
+	ma.Publish("news", []byte("0"), 1, false)
+
+	_, ids := deliveredMessages(t, c, ProtocolLevel_5)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		packets := readPackets(t, c)
+		if p := packets[len(packets)-1]; p.Flags&0x08 != 0 {
+			if _, packetID, _ := decodePublish(p, ProtocolLevel_5); packetID != ids[0] {
+				t.Fatalf("unexpected packet identifier %d", packetID)
+			}
+
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("message was not sent again")
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// Acknowledged message is not sent again
+	if err := receive(c, encodePuback(ids[0])); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Reset()
+	time.Sleep(1500 * time.Millisecond)
+
+	if msgs, _ := deliveredMessages(t, c, ProtocolLevel_5); len(msgs) != 0 {
+		t.Fatalf("unexpected messages %v", msgs)
+	}
+}
+
+func TestResumeSession(t *testing.T) {
+
+	ma := New(WithRetryInterval(0))
+
+	for _, level := range []byte{ProtocolLevel_311, ProtocolLevel_5} {
+
+		var props []byte
+		if level == ProtocolLevel_5 {
+			props = (&encoder{}).byte(propertySessionExpiryInterval).uint16(0).uint16(60).buf
+		}
+
+		c := websocket_servertest.NewClient(ma)
+		if err := receive(c, encodeResume(level, "device", props)); err != nil {
+			t.Fatal(err)
+		}
+
+		c.Reset()
+		subscribeClient(t, c, level, "news", 1)
+
+		ma.Publish("news", []byte("0"), 1, false)
+		_, ids := deliveredMessages(t, c, level)
+
+		c.Close()
+
+		// Messages are kept for offline client
+		ma.Publish("news", []byte("1"), 1, false)
+
+		c = websocket_servertest.NewClient(ma)
+		if err := receive(c, encodeResume(level, "device", props)); err != nil {
+			t.Fatal(err)
+		}
+
+		packets := readPackets(t, c)
+		if len(packets) != 3 || packets[0].Type != PacketType_Connack || packets[0].Body[0] != 0x01 {
+			t.Fatalf("level %d: unexpected packets %v", level, packets)
+		}
+
+		// Unacknowledged message is sent again before queued one
+		msg, packetID, _ := decodePublish(packets[1], level)
+		if packets[1].Flags&0x08 == 0 || packetID != ids[0] || string(msg.Payload) != "0" {
+			t.Fatalf("level %d: unexpected message %v %d", level, msg, packetID)
+		}
+
+		msg, _, _ = decodePublish(packets[2], level)
+		if packets[2].Flags&0x08 != 0 || string(msg.Payload) != "1" {
+			t.Fatalf("level %d: unexpected message %v", level, msg)
+		}
+
+		c.Reset()
+
+		// Subscriptions are resumed as well
+		ma.Publish("news", []byte("2"), 1, false)
+		if msgs, _ := deliveredMessages(t, c, level); len(msgs) != 1 || string(msgs[0].Payload) != "2" {
+			t.Fatalf("level %d: unexpected messages %v", level, msgs)
+		}
+
+		// Clean session discards state
+		c.Close()
+
+		c = connectClient(t, ma, level, "device", 0, nil, nil)
+		ma.Publish("news", []byte("3"), 1, false)
+
+		if msgs, _ := deliveredMessages(t, c, level); len(msgs) != 0 {
+			t.Fatalf("level %d: unexpected messages %v", level, msgs)
+		}
+
+		c.Close()
+	}
+
+	ma.sessionMutex.RLock()
+	persisted := len(ma.persisted)
+	ma.sessionMutex.RUnlock()
+
+	if persisted != 0 {
+		t.Fatalf("sessions were persisted: %d", persisted)
+	}
+}
+
+func TestRejectZeroPacketID(t *testing.T) {
+
+	ma := New()
+
+	c := connectClient(t, ma, ProtocolLevel_5, "device", 0, nil, nil)
+
+	msg := &Message{Topic: "news", Payload: []byte("0")}
+	if err := receive(c, encodePublish(msg, 1, false, false, 0, ProtocolLevel_5)); err != ErrProtocolViolation {
+		t.Fatalf("expected protocol violation, got %v", err)
+	}
+
+	packets := readPackets(t, c)
+	if len(packets) != 1 || packets[0].Type != PacketType_Disconnect || packets[0].Body[0] != reasonProtocolError {
+		t.Fatalf("unexpected packets %v", packets)
+	}
+}
+
+func TestTakeoverPublishesWill(t *testing.T) {
+
+	ma := New()
+
+	watcher := connectClient(t, ma, ProtocolLevel_311, "watcher", 0, nil, nil)
+	subscribeClient(t, watcher, ProtocolLevel_311, "status/#", 0)
+
+	will := &Message{Topic: "status/device", Payload: []byte("offline")}
+	prev := connectClient(t, ma, ProtocolLevel_5, "device", 0, will, nil)
+	connectClient(t, ma, ProtocolLevel_5, "device", 0, nil, nil)
+
+	if !prev.IsClosed() {
+		t.Fatal("previous client was not closed")
+	}
+
+	packets := readPackets(t, prev)
+	if len(packets) != 1 || packets[0].Type != PacketType_Disconnect || packets[0].Body[0] != reasonSessionTakenOver {
+		t.Fatalf("unexpected packets %v", packets)
+	}
+
+	msgs, _ := deliveredMessages(t, watcher, ProtocolLevel_311)
+	if len(msgs) != 1 || string(msgs[0].Payload) != "offline" {
+		t.Fatalf("unexpected messages %v", msgs)
+	}
+
+	// Client identifier belongs to the new session
+	ma.sessionMutex.RLock()
+	s, ok := ma.clientIDs["device"]
+	ma.sessionMutex.RUnlock()
+
+	if !ok || s.client == prev {
+		t.Fatal("client identifier was released by previous session")
+	}
+}
+
+func TestKeepAliveTimeout(t *testing.T) {
+
+	ma := New()
+
+	watcher := connectClient(t, ma, ProtocolLevel_311, "watcher", 0, nil, nil)
+	subscribeClient(t, watcher, ProtocolLevel_311, "status/#", 0)
+
+	will := &Message{Topic: "status/device", Payload: []byte("lost")}
+	c := connectClient(t, ma, ProtocolLevel_311, "device", 1, will, nil)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !c.IsClosed() {
+		if time.Now().After(deadline) {
+			t.Fatal("client was not closed after keep alive timeout")
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	msgs, _ := deliveredMessages(t, watcher, ProtocolLevel_311)
+	if len(msgs) != 1 || string(msgs[0].Payload) != "lost" {
+		t.Fatalf("will was not published: %v", msgs)
+	}
+
+	ma.sessionMutex.RLock()
+	sessions, clientIDs := len(ma.sessions), len(ma.clientIDs)
+	ma.sessionMutex.RUnlock()
+
+	if sessions != 1 || clientIDs != 1 {
+		t.Fatalf("sessions were not released: %d %d", sessions, clientIDs)
+	}
+}
+
+func TestDisconnectDiscardsWill(t *testing.T) {
+
+	ma := New()
+
+	watcher := connectClient(t, ma, ProtocolLevel_311, "watcher", 0, nil, nil)
+	subscribeClient(t, watcher, ProtocolLevel_311, "status/#", 0)
+
+	will := &Message{Topic: "status/device", Payload: []byte("lost")}
+	c := connectClient(t, ma, ProtocolLevel_311, "device", 0, will, nil)
+
+	if err := receive(c, (&Packet{Type: PacketType_Disconnect}).Marshal()); err != ErrDisconnected {
+		t.Fatalf("expected disconnected, got %v", err)
+	}
+
+	if msgs, _ := deliveredMessages(t, watcher, ProtocolLevel_311); len(msgs) != 0 {
+		t.Fatalf("will was published: %v", msgs)
+	}
+}
+
+func TestClose(t *testing.T) {
+
+	ma := New()
+	ma.Close()
+	ma.Close()
+
+	stopped := make(chan struct{})
+	go func() {
+		ma.watch()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("watcher was not stopped")
+	}
+}
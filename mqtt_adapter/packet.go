@@ -0,0 +1,553 @@
+package mqtt_adapter
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+type PacketType byte
+
+const (
+	PacketType_Connect     PacketType = 1
+	PacketType_Connack                = 2
+	PacketType_Publish                = 3
+	PacketType_Puback                 = 4
+	PacketType_Pubrec                 = 5
+	PacketType_Pubrel                 = 6
+	PacketType_Pubcomp                = 7
+	PacketType_Subscribe              = 8
+	PacketType_Suback                 = 9
+	PacketType_Unsubscribe            = 10
+	PacketType_Unsuback               = 11
+	PacketType_Pingreq                = 12
+	PacketType_Pingresp               = 13
+	PacketType_Disconnect             = 14
+	PacketType_Auth                   = 15
+)
+
+const (
+	ProtocolLevel_311 byte = 4
+	ProtocolLevel_5   byte = 5
+)
+
+// Maximum length of remaining length field is 4 bytes
+const MaxPacketSize = 268435455
+
+const (
+	propertySessionExpiryInterval byte = 0x11
+	propertyAssignedClientID      byte = 0x12
+	propertyAuthenticationMethod  byte = 0x15
+	propertyAuthenticationData    byte = 0x16
+	propertyRequestProblemInfo    byte = 0x17
+	propertyRequestResponseInfo   byte = 0x19
+	propertyReceiveMaximum        byte = 0x21
+	propertyTopicAliasMaximum     byte = 0x22
+	propertyMaximumQoS            byte = 0x24
+	propertyRetainAvailable       byte = 0x25
+	propertyUserProperty          byte = 0x26
+	propertyMaximumPacketSize     byte = 0x27
+)
+
+// DefaultReceiveMaximum is used if client does not limit QoS 1 messages which are in flight
+const DefaultReceiveMaximum = 65535
+
+var (
+	ErrMalformedPacket = errors.New("mqtt: malformed packet")
+)
+
+type Packet struct {
+	Type  PacketType
+	Flags byte
+	Body  []byte
+}
+
+// ReadPacket reads a packet from the beginning of data, zero is returned if the packet is incomplete
+func ReadPacket(data []byte) (*Packet, int, error) {
+
+	if len(data) < 2 {
+		return nil, 0, nil
+	}
+
+	length, n, err := readVarint(data[1:])
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Incomplete remaining length or body
+	if n == 0 || len(data) < 1+n+length {
+		return nil, 0, nil
+	}
+
+	p := &Packet{
+		Type:  PacketType(data[0] >> 4),
+		Flags: data[0] & 0x0f,
+		Body:  data[1+n : 1+n+length],
+	}
+
+	return p, 1 + n + length, nil
+}
+
+func (p *Packet) Marshal() []byte {
+	buf := make([]byte, 0, len(p.Body)+5)
+	buf = append(buf, byte(p.Type)<<4|p.Flags)
+	buf = appendVarint(buf, len(p.Body))
+	return append(buf, p.Body...)
+}
+
+func readVarint(data []byte) (int, int, error) {
+
+	value := 0
+	multiplier := 1
+
+	for i := 0; i < 4; i++ {
+
+		if i >= len(data) {
+			return 0, 0, nil
+		}
+
+		value += int(data[i]&0x7f) * multiplier
+		if data[i]&0x80 == 0 {
+			return value, i + 1, nil
+		}
+
+		multiplier *= 128
+	}
+
+	return 0, 0, ErrMalformedPacket
+}
+
+func appendVarint(buf []byte, v int) []byte {
+
+	for {
+		b := byte(v % 128)
+		v /= 128
+
+		if v > 0 {
+			b |= 0x80
+		}
+
+		buf = append(buf, b)
+
+		if v == 0 {
+			return buf
+		}
+	}
+}
+
+// decoder reads fields of packet body, the first error is kept and following reads return zero values
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) fail() {
+	if d.err == nil {
+		d.err = ErrMalformedPacket
+	}
+
+	d.data = nil
+}
+
+func (d *decoder) remaining() int {
+	return len(d.data)
+}
+
+func (d *decoder) byte() byte {
+
+	if len(d.data) < 1 {
+		d.fail()
+		return 0
+	}
+
+	b := d.data[0]
+	d.data = d.data[1:]
+
+	return b
+}
+
+func (d *decoder) uint16() uint16 {
+
+	if len(d.data) < 2 {
+		d.fail()
+		return 0
+	}
+
+	v := binary.BigEndian.Uint16(d.data)
+	d.data = d.data[2:]
+
+	return v
+}
+
+func (d *decoder) bytes() []byte {
+
+	length := int(d.uint16())
+	if len(d.data) < length {
+		d.fail()
+		return nil
+	}
+
+	b := d.data[:length]
+	d.data = d.data[length:]
+
+	return b
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
+
+func (d *decoder) rest() []byte {
+	b := d.data
+	d.data = nil
+	return b
+}
+
+// properties skips properties of MQTT 5 packets, they are not used by the broker
+func (d *decoder) properties() {
+
+	length, n, err := readVarint(d.data)
+	if err != nil || n == 0 || len(d.data) < n+length {
+		d.fail()
+		return
+	}
+
+	d.data = d.data[n+length:]
+}
+
+// connectProperties reads properties of CONNECT packet, only session expiry and receive maximum are used
+// by the broker
+func (d *decoder) connectProperties(cp *ConnectPacket) {
+
+	length, n, err := readVarint(d.data)
+	if err != nil || n == 0 || len(d.data) < n+length {
+		d.fail()
+		return
+	}
+
+	pd := &decoder{data: d.data[n : n+length]}
+	d.data = d.data[n+length:]
+
+	var receiveMaximum uint16
+	found := false
+
+	for pd.remaining() > 0 && pd.err == nil {
+
+		switch pd.byte() {
+		case propertySessionExpiryInterval:
+			cp.SessionExpiryInterval = uint32(pd.uint16())<<16 | uint32(pd.uint16())
+		case propertyMaximumPacketSize:
+			pd.uint16()
+			pd.uint16()
+		case propertyReceiveMaximum:
+			receiveMaximum = pd.uint16()
+			found = true
+		case propertyTopicAliasMaximum:
+			pd.uint16()
+		case propertyRequestProblemInfo, propertyRequestResponseInfo:
+			pd.byte()
+		case propertyUserProperty:
+			pd.string()
+			pd.string()
+		case propertyAuthenticationMethod, propertyAuthenticationData:
+			pd.bytes()
+		default:
+			pd.fail()
+		}
+	}
+
+	// Zero is a protocol error
+	if pd.err != nil || (found && receiveMaximum == 0) {
+		d.fail()
+		return
+	}
+
+	if found {
+		cp.ReceiveMaximum = receiveMaximum
+	}
+}
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) byte(b byte) *encoder {
+	e.buf = append(e.buf, b)
+	return e
+}
+
+func (e *encoder) uint16(v uint16) *encoder {
+	e.buf = binary.BigEndian.AppendUint16(e.buf, v)
+	return e
+}
+
+func (e *encoder) bytes(b []byte) *encoder {
+	e.uint16(uint16(len(b)))
+	e.buf = append(e.buf, b...)
+	return e
+}
+
+func (e *encoder) string(s string) *encoder {
+	return e.bytes([]byte(s))
+}
+
+func (e *encoder) raw(b []byte) *encoder {
+	e.buf = append(e.buf, b...)
+	return e
+}
+
+func (e *encoder) properties(props []byte) *encoder {
+	e.buf = appendVarint(e.buf, len(props))
+	e.buf = append(e.buf, props...)
+	return e
+}
+
+type ConnectPacket struct {
+	ProtocolName  string
+	ProtocolLevel byte
+	CleanSession  bool
+	KeepAlive     uint16
+	ClientID      string
+	Will          *Message
+	Username      string
+	Password      []byte
+	HasUsername   bool
+	HasPassword   bool
+
+	// ReceiveMaximum limits QoS 1 messages which are not acknowledged by client
+	ReceiveMaximum uint16
+
+	// SessionExpiryInterval is in seconds, session of MQTT 5 ends with connection if it is zero
+	SessionExpiryInterval uint32
+}
+
+func decodeConnect(p *Packet) (*ConnectPacket, error) {
+
+	d := &decoder{data: p.Body}
+
+	cp := &ConnectPacket{
+		ProtocolName:   d.string(),
+		ProtocolLevel:  d.byte(),
+		ReceiveMaximum: DefaultReceiveMaximum,
+	}
+
+	flags := d.byte()
+	cp.KeepAlive = d.uint16()
+
+	if d.err != nil || flags&0x01 != 0 {
+		return nil, ErrMalformedPacket
+	}
+
+	// Properties and payload are unknown for unsupported versions, caller will reject it
+	if cp.ProtocolLevel != ProtocolLevel_311 && cp.ProtocolLevel != ProtocolLevel_5 {
+		return cp, nil
+	}
+
+	v5 := cp.ProtocolLevel == ProtocolLevel_5
+	if v5 {
+		d.connectProperties(cp)
+	}
+
+	cp.CleanSession = flags&0x02 != 0
+	cp.ClientID = d.string()
+
+	if flags&0x04 != 0 {
+
+		if v5 {
+			d.properties()
+		}
+
+		cp.Will = &Message{
+			Topic:   d.string(),
+			Payload: d.bytes(),
+			QoS:     (flags >> 3) & 0x03,
+			Retain:  flags&0x20 != 0,
+		}
+	}
+
+	if flags&0x80 != 0 {
+		cp.HasUsername = true
+		cp.Username = d.string()
+	}
+
+	if flags&0x40 != 0 {
+		cp.HasPassword = true
+		cp.Password = d.bytes()
+	}
+
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	return cp, nil
+}
+
+func decodePublish(p *Packet, level byte) (*Message, uint16, error) {
+
+	d := &decoder{data: p.Body}
+
+	msg := &Message{
+		Topic:  d.string(),
+		QoS:    (p.Flags >> 1) & 0x03,
+		Retain: p.Flags&0x01 != 0,
+	}
+
+	var packetID uint16
+	if msg.QoS > 0 {
+		packetID = d.uint16()
+	}
+
+	if level == ProtocolLevel_5 {
+		d.properties()
+	}
+
+	if d.err != nil {
+		return nil, 0, d.err
+	}
+
+	msg.Payload = append([]byte{}, d.rest()...)
+
+	return msg, packetID, nil
+}
+
+func encodePublish(msg *Message, qos byte, retain bool, dup bool, packetID uint16, level byte) []byte {
+
+	e := &encoder{}
+	e.string(msg.Topic)
+
+	if qos > 0 {
+		e.uint16(packetID)
+	}
+
+	if level == ProtocolLevel_5 {
+		e.properties(nil)
+	}
+
+	e.raw(msg.Payload)
+
+	flags := qos << 1
+	if retain {
+		flags |= 0x01
+	}
+
+	if dup {
+		flags |= 0x08
+	}
+
+	return (&Packet{Type: PacketType_Publish, Flags: flags, Body: e.buf}).Marshal()
+}
+
+type SubscribeRequest struct {
+	Filter            string
+	QoS               byte
+	NoLocal           bool
+	RetainAsPublished bool
+	RetainHandling    byte
+}
+
+func decodeSubscribe(p *Packet, level byte) (uint16, []*SubscribeRequest, error) {
+
+	if p.Flags != 0x02 {
+		return 0, nil, ErrMalformedPacket
+	}
+
+	d := &decoder{data: p.Body}
+	packetID := d.uint16()
+
+	if level == ProtocolLevel_5 {
+		d.properties()
+	}
+
+	reqs := make([]*SubscribeRequest, 0)
+	for d.err == nil && d.remaining() > 0 {
+
+		req := &SubscribeRequest{
+			Filter: d.string(),
+		}
+
+		opts := d.byte()
+		req.QoS = opts & 0x03
+
+		if level == ProtocolLevel_5 {
+			req.NoLocal = opts&0x04 != 0
+			req.RetainAsPublished = opts&0x08 != 0
+			req.RetainHandling = (opts >> 4) & 0x03
+		}
+
+		reqs = append(reqs, req)
+	}
+
+	if d.err != nil || len(reqs) == 0 {
+		return 0, nil, ErrMalformedPacket
+	}
+
+	return packetID, reqs, nil
+}
+
+func decodeUnsubscribe(p *Packet, level byte) (uint16, []string, error) {
+
+	if p.Flags != 0x02 {
+		return 0, nil, ErrMalformedPacket
+	}
+
+	d := &decoder{data: p.Body}
+	packetID := d.uint16()
+
+	if level == ProtocolLevel_5 {
+		d.properties()
+	}
+
+	filters := make([]string, 0)
+	for d.err == nil && d.remaining() > 0 {
+		filters = append(filters, d.string())
+	}
+
+	if d.err != nil || len(filters) == 0 {
+		return 0, nil, ErrMalformedPacket
+	}
+
+	return packetID, filters, nil
+}
+
+// encodeAck creates packets which consist of packet ID and reason codes
+func encodeAck(t PacketType, packetID uint16, codes []byte, level byte) []byte {
+
+	e := &encoder{}
+	e.uint16(packetID)
+
+	if level == ProtocolLevel_5 {
+		e.properties(nil)
+	}
+
+	e.raw(codes)
+
+	return (&Packet{Type: t, Body: e.buf}).Marshal()
+}
+
+func encodeConnack(code byte, sessionPresent bool, level byte, props []byte) []byte {
+
+	e := &encoder{}
+
+	if sessionPresent {
+		e.byte(0x01)
+	} else {
+		e.byte(0)
+	}
+
+	e.byte(code)
+
+	if level == ProtocolLevel_5 {
+		e.properties(props)
+	}
+
+	return (&Packet{Type: PacketType_Connack, Body: e.buf}).Marshal()
+}
+
+func encodeDisconnect(code byte, level byte) []byte {
+
+	// Only MQTT 5 allows server to send DISCONNECT
+	if level != ProtocolLevel_5 {
+		return nil
+	}
+
+	return (&Packet{Type: PacketType_Disconnect, Body: []byte{code, 0}}).Marshal()
+}
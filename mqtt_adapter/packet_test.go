@@ -0,0 +1,170 @@
+package mqtt_adapter
+
+import (
+	"bytes"
+	"testing"
+)
+
+func encodeConnect(level byte, clientID string, keepAlive uint16, will *Message, props []byte) []byte {
+
+	flags := byte(0x02)
+	if will != nil {
+		flags |= 0x04 | will.QoS<<3
+		if will.Retain {
+			flags |= 0x20
+		}
+	}
+
+	e := &encoder{}
+	e.string("MQTT").byte(level).byte(flags).uint16(keepAlive)
+
+	if level == ProtocolLevel_5 {
+		e.properties(props)
+	}
+
+	e.string(clientID)
+
+	if will != nil {
+		if level == ProtocolLevel_5 {
+			e.properties(nil)
+		}
+
+		e.string(will.Topic).bytes(will.Payload)
+	}
+
+	return (&Packet{Type: PacketType_Connect, Body: e.buf}).Marshal()
+}
+
+func TestVarint(t *testing.T) {
+
+	for _, v := range []int{0, 127, 128, 16383, 16384, 2097151, 2097152, MaxPacketSize} {
+
+		buf := appendVarint(nil, v)
+
+		decoded, n, err := readVarint(buf)
+		if err != nil || n != len(buf) || decoded != v {
+			t.Fatalf("%d: decoded %d with %d bytes, %v", v, decoded, n, err)
+		}
+	}
+
+	if _, _, err := readVarint([]byte{0xff, 0xff, 0xff, 0xff, 0x01}); err != ErrMalformedPacket {
+		t.Fatalf("expected malformed packet, got %v", err)
+	}
+}
+
+func TestReadPacket(t *testing.T) {
+
+	data := (&Packet{Type: PacketType_Pingreq}).Marshal()
+	data = append(data, encodePublish(&Message{Topic: "a/b", Payload: make([]byte, 200)}, 1, false, false, 7, ProtocolLevel_311)...)
+
+	p, n, err := ReadPacket(data)
+	if err != nil || p.Type != PacketType_Pingreq || n != 2 {
+		t.Fatalf("unexpected packet %v %d %v", p, n, err)
+	}
+
+	// Incomplete packet is waiting for the rest
+	if _, n, err := ReadPacket(data[2 : len(data)-1]); n != 0 || err != nil {
+		t.Fatalf("incomplete packet was read: %d %v", n, err)
+	}
+
+	p, n, err = ReadPacket(data[2:])
+	if err != nil || p.Type != PacketType_Publish || n != len(data)-2 {
+		t.Fatalf("unexpected packet %v %d %v", p, n, err)
+	}
+}
+
+func TestPublishRoundTrip(t *testing.T) {
+
+	for _, level := range []byte{ProtocolLevel_311, ProtocolLevel_5} {
+
+		msg := &Message{
+			Topic:   "sensors/1",
+			Payload: []byte("21.5"),
+		}
+
+		p, _, err := ReadPacket(encodePublish(msg, 1, true, true, 42, level))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if p.Flags&0x08 == 0 {
+			t.Fatalf("level %d: dup flag was not set", level)
+		}
+
+		decoded, packetID, err := decodePublish(p, level)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if packetID != 42 || decoded.Topic != msg.Topic || decoded.QoS != 1 || !decoded.Retain || !bytes.Equal(decoded.Payload, msg.Payload) {
+			t.Fatalf("level %d: unexpected message %+v %d", level, decoded, packetID)
+		}
+	}
+}
+
+func TestDecodeConnect(t *testing.T) {
+
+	will := &Message{Topic: "status", Payload: []byte("offline"), QoS: 1, Retain: true}
+
+	props := (&encoder{}).
+		byte(propertySessionExpiryInterval).uint16(0).uint16(60).
+		byte(propertyUserProperty).string("k").string("v").
+		byte(propertyReceiveMaximum).uint16(10).buf
+
+	p, _, err := ReadPacket(encodeConnect(ProtocolLevel_5, "device", 30, will, props))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cp, err := decodeConnect(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cp.ClientID != "device" || cp.KeepAlive != 30 || !cp.CleanSession || cp.ReceiveMaximum != 10 || cp.SessionExpiryInterval != 60 {
+		t.Fatalf("unexpected connect %+v", cp)
+	}
+
+	if cp.Will == nil || cp.Will.Topic != "status" || string(cp.Will.Payload) != "offline" || cp.Will.QoS != 1 || !cp.Will.Retain {
+		t.Fatalf("unexpected will %+v", cp.Will)
+	}
+
+	// Receive maximum is not limited by MQTT 3.1.1 clients
+	p, _, _ = ReadPacket(encodeConnect(ProtocolLevel_311, "device", 30, nil, nil))
+	if cp, err := decodeConnect(p); err != nil || cp.ReceiveMaximum != DefaultReceiveMaximum {
+		t.Fatalf("unexpected connect %+v %v", cp, err)
+	}
+
+	// Zero receive maximum is a protocol error
+	props = (&encoder{}).byte(propertyReceiveMaximum).uint16(0).buf
+	p, _, _ = ReadPacket(encodeConnect(ProtocolLevel_5, "device", 30, nil, props))
+	if _, err := decodeConnect(p); err != ErrMalformedPacket {
+		t.Fatalf("expected malformed packet, got %v", err)
+	}
+}
+
+func TestDecodeSubscribe(t *testing.T) {
+
+	e := &encoder{}
+	e.uint16(9).properties(nil)
+	e.string("a/+").byte(0x01 | 0x04 | 0x08 | 0x20)
+	e.string("b/#").byte(0x00)
+
+	packetID, reqs, err := decodeSubscribe(&Packet{Type: PacketType_Subscribe, Flags: 0x02, Body: e.buf}, ProtocolLevel_5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if packetID != 9 || len(reqs) != 2 {
+		t.Fatalf("unexpected subscribe %d %v", packetID, reqs)
+	}
+
+	if r := reqs[0]; r.Filter != "a/+" || r.QoS != 1 || !r.NoLocal || !r.RetainAsPublished || r.RetainHandling != RetainHandling_DoNotSend {
+		t.Fatalf("unexpected request %+v", r)
+	}
+
+	// Reserved flags must be set
+	if _, _, err := decodeSubscribe(&Packet{Type: PacketType_Subscribe, Body: e.buf}, ProtocolLevel_5); err != ErrMalformedPacket {
+		t.Fatalf("expected malformed packet, got %v", err)
+	}
+}
@@ -0,0 +1,370 @@
+package mqtt_adapter
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+// delivery is a QoS 1 message which is waiting for acknowledgement or for a slot of receive maximum
+type delivery struct {
+	msg      *Message
+	retain   bool
+	packetID uint16
+	seq      uint64
+	sent     int64
+}
+
+type session struct {
+	lastReceived int64
+
+	client websocket_server.Client
+
+	// Packets may span frames, buffer is only accessed by the poller
+	buffer []byte
+
+	mutex     sync.Mutex
+	connected bool
+	level     byte
+	clientID  string
+	keepAlive time.Duration
+	will      *Message
+	packetID  uint16
+	seq       uint64
+	inflight  map[uint16]*delivery
+	queue     []*delivery
+
+	// Messages are queued until CONNACK is sent and after connection is lost
+	online bool
+	closed bool
+
+	// Session state outlives connection, expiry is zero if it never expires
+	persistent bool
+	expiry     time.Duration
+	expiresAt  int64
+
+	// Messages for the session are forwarded to the session which resumed it
+	successor *session
+
+	// QoS 1 messages which are not acknowledged are limited by receive maximum
+	receiveMaximum int
+	maxQueued      int
+}
+
+func newSession(c websocket_server.Client) *session {
+	return &session{
+		lastReceived: time.Now().UnixNano(),
+		client:       c,
+		inflight:     make(map[uint16]*delivery),
+		queue:        make([]*delivery, 0),
+	}
+}
+
+func (s *session) touch() {
+	atomic.StoreInt64(&s.lastReceived, time.Now().UnixNano())
+}
+
+func (s *session) isConnected() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.connected
+}
+
+func (s *session) getLevel() byte {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.level
+}
+
+func (s *session) connect(cp *ConnectPacket, clientID string, receiveMaximum int, maxQueued int) bool {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.connected {
+		return false
+	}
+
+	s.connected = true
+	s.level = cp.ProtocolLevel
+	s.clientID = clientID
+	s.keepAlive = time.Duration(cp.KeepAlive) * time.Second
+	s.will = cp.Will
+	s.receiveMaximum = int(cp.ReceiveMaximum)
+	s.maxQueued = maxQueued
+
+	if receiveMaximum > 0 && receiveMaximum < s.receiveMaximum {
+		s.receiveMaximum = receiveMaximum
+	}
+
+	// MQTT 3.1.1 keeps session until client asks for a clean one, MQTT 5 keeps it for expiry interval
+	if cp.ProtocolLevel == ProtocolLevel_5 {
+		s.persistent = cp.SessionExpiryInterval > 0
+		if cp.SessionExpiryInterval != 0xffffffff {
+			s.expiry = time.Duration(cp.SessionExpiryInterval) * time.Second
+		}
+	} else {
+		s.persistent = !cp.CleanSession
+	}
+
+	return true
+}
+
+// takeWill returns will message which should be published, it is published once at most
+func (s *session) takeWill() *Message {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	will := s.will
+	s.will = nil
+	return will
+}
+
+func (s *session) clearWill() {
+	s.takeWill()
+}
+
+func (s *session) expired(now int64) bool {
+
+	s.mutex.Lock()
+	keepAlive := s.keepAlive
+	s.mutex.Unlock()
+
+	if keepAlive <= 0 {
+		return false
+	}
+
+	// Server allows one and a half times of keep alive period
+	return time.Duration(now-atomic.LoadInt64(&s.lastReceived)) > keepAlive*3/2
+}
+
+// Deliver sends message to client. QoS 1 messages are queued if too many of them are in flight or
+// client is offline, they are resent until client acknowledges them.
+func (s *session) Deliver(msg *Message, qos byte, retain bool) error {
+
+	s.mutex.Lock()
+
+	if s.successor != nil {
+		successor := s.successor
+		s.mutex.Unlock()
+		return successor.Deliver(msg, qos, retain)
+	}
+
+	if s.closed {
+		s.mutex.Unlock()
+		return websocket_server.ErrConnectionClosed
+	}
+
+	if qos == 0 {
+		online := s.online
+		level := s.level
+		s.mutex.Unlock()
+
+		// QoS 0 messages are not kept for offline client
+		if !online {
+			return nil
+		}
+
+		return s.client.Send(encodePublish(msg, 0, retain, false, 0, level))
+	}
+
+	if len(s.queue) >= s.maxQueued {
+		s.mutex.Unlock()
+		return ErrQueueFull
+	}
+
+	s.seq++
+	s.queue = append(s.queue, &delivery{
+		msg:    msg,
+		retain: retain,
+		seq:    s.seq,
+	})
+
+	packets := s.dequeue()
+
+	s.mutex.Unlock()
+
+	return s.send(packets)
+}
+
+// dequeue moves queued messages to inflight as long as receive maximum allows
+func (s *session) dequeue() [][]byte {
+
+	packets := make([][]byte, 0)
+
+	if !s.online {
+		return packets
+	}
+
+	now := time.Now().UnixNano()
+
+	for len(s.queue) > 0 && len(s.inflight) < s.receiveMaximum {
+
+		d := s.queue[0]
+		s.queue[0] = nil
+		s.queue = s.queue[1:]
+
+		d.packetID = s.nextPacketID()
+		d.sent = now
+		s.inflight[d.packetID] = d
+
+		packets = append(packets, encodePublish(d.msg, 1, d.retain, false, d.packetID, s.level))
+	}
+
+	return packets
+}
+
+func (s *session) send(packets [][]byte) error {
+
+	for _, data := range packets {
+		if err := s.client.Send(data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// nextPacketID always finds an ID since inflight messages are fewer than receive maximum
+func (s *session) nextPacketID() uint16 {
+
+	for {
+		s.packetID++
+
+		// Zero is not allowed, and IDs which are in use should be skipped
+		if s.packetID == 0 {
+			continue
+		}
+
+		if _, ok := s.inflight[s.packetID]; !ok {
+			return s.packetID
+		}
+	}
+}
+
+func (s *session) ack(packetID uint16) error {
+
+	s.mutex.Lock()
+	delete(s.inflight, packetID)
+	packets := s.dequeue()
+	s.mutex.Unlock()
+
+	return s.send(packets)
+}
+
+// resend sends inflight messages which were sent before the time again with DUP flag
+func (s *session) resend(before int64) error {
+
+	s.mutex.Lock()
+
+	if !s.online {
+		s.mutex.Unlock()
+		return nil
+	}
+
+	deliveries := make([]*delivery, 0)
+	for _, d := range s.inflight {
+		if d.sent <= before {
+			deliveries = append(deliveries, d)
+		}
+	}
+
+	// Messages are resent in the original order
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].seq < deliveries[j].seq
+	})
+
+	now := time.Now().UnixNano()
+
+	packets := make([][]byte, 0, len(deliveries))
+	for _, d := range deliveries {
+		d.sent = now
+		packets = append(packets, encodePublish(d.msg, 1, d.retain, true, d.packetID, s.level))
+	}
+
+	s.mutex.Unlock()
+
+	return s.send(packets)
+}
+
+// resume takes state of previous session, messages for previous session are forwarded from now on
+func (s *session) resume(prev *session) {
+
+	prev.mutex.Lock()
+	defer prev.mutex.Unlock()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.packetID = prev.packetID
+	s.seq = prev.seq
+	s.inflight = prev.inflight
+	s.queue = prev.queue
+
+	prev.successor = s
+	prev.inflight = make(map[uint16]*delivery)
+	prev.queue = make([]*delivery, 0)
+}
+
+// start sends messages which were kept for the session, it is called after CONNACK
+func (s *session) start() error {
+
+	s.mutex.Lock()
+	s.online = true
+	s.mutex.Unlock()
+
+	if err := s.resend(time.Now().UnixNano()); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	packets := s.dequeue()
+	s.mutex.Unlock()
+
+	return s.send(packets)
+}
+
+// detach keeps session state after connection is lost, false is returned if session is not persistent
+func (s *session) detach() bool {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.online = false
+
+	if s.closed || !s.persistent || s.successor != nil {
+		return false
+	}
+
+	if s.expiry > 0 {
+		s.expiresAt = time.Now().Add(s.expiry).UnixNano()
+	}
+
+	return true
+}
+
+func (s *session) stateExpired(now int64) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return !s.online && s.expiresAt > 0 && now > s.expiresAt
+}
+
+func (s *session) close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.online = false
+	s.closed = true
+}
+
+// disconnect notifies client of the reason before closing connection, MQTT 3.1.1 has no such packet
+func (s *session) disconnect(code byte) {
+
+	data := encodeDisconnect(code, s.getLevel())
+	if data == nil {
+		return
+	}
+
+	s.client.Send(data)
+}
@@ -0,0 +1,64 @@
+package mqtt_adapter
+
+import (
+	"strings"
+)
+
+// ValidTopic reports whether name is able to be published to
+func ValidTopic(name string) bool {
+	return len(name) > 0 && !strings.ContainsAny(name, "+#\x00")
+}
+
+// ValidFilter reports whether filter is a valid topic filter, wildcards must occupy entire levels
+func ValidFilter(filter string) bool {
+
+	if len(filter) == 0 || strings.Contains(filter, "\x00") {
+		return false
+	}
+
+	levels := strings.Split(filter, "/")
+	for i, level := range levels {
+
+		switch {
+		case level == "#":
+			if i != len(levels)-1 {
+				return false
+			}
+		case level == "+":
+		case strings.ContainsAny(level, "+#"):
+			return false
+		}
+	}
+
+	return true
+}
+
+// MatchTopic reports whether topic name matches the filter
+func MatchTopic(filter string, topic string) bool {
+
+	// Topics which begin with "$" are not matched by wildcards at the first level
+	if strings.HasPrefix(topic, "$") && (strings.HasPrefix(filter, "+") || strings.HasPrefix(filter, "#")) {
+		return false
+	}
+
+	fl := strings.Split(filter, "/")
+	tl := strings.Split(topic, "/")
+
+	for i, level := range fl {
+
+		// Multi-level wildcard matches parent level as well
+		if level == "#" {
+			return true
+		}
+
+		if i >= len(tl) {
+			return false
+		}
+
+		if level != "+" && level != tl[i] {
+			return false
+		}
+	}
+
+	return len(fl) == len(tl)
+}
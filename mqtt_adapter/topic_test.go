@@ -0,0 +1,53 @@
+package mqtt_adapter
+
+import "testing"
+
+func TestValidFilter(t *testing.T) {
+
+	cases := map[string]bool{
+		"a/b":     true,
+		"a/+/c":   true,
+		"a/#":     true,
+		"#":       true,
+		"+":       true,
+		"a/#/c":   false,
+		"a/b+":    false,
+		"a/#b":    false,
+		"":        false,
+		"a\x00/b": false,
+	}
+
+	for filter, expected := range cases {
+		if ValidFilter(filter) != expected {
+			t.Fatalf("%q: expected %v", filter, expected)
+		}
+	}
+}
+
+func TestMatchTopic(t *testing.T) {
+
+	cases := []struct {
+		filter  string
+		topic   string
+		matched bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/b", "a/c", false},
+		{"a/+", "a/b", true},
+		{"a/+", "a/b/c", false},
+		{"a/+/c", "a/b/c", true},
+		{"a/#", "a", true},
+		{"a/#", "a/b/c", true},
+		{"#", "a/b", true},
+		{"+/+", "/b", true},
+		{"#", "$SYS/uptime", false},
+		{"+/uptime", "$SYS/uptime", false},
+		{"$SYS/#", "$SYS/uptime", true},
+	}
+
+	for _, c := range cases {
+		if MatchTopic(c.filter, c.topic) != c.matched {
+			t.Fatalf("%q %q: expected %v", c.filter, c.topic, c.matched)
+		}
+	}
+}
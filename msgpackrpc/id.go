@@ -0,0 +1,34 @@
+package msgpackrpc
+
+import (
+	"reflect"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+// IDs are encoded in their native form wherever they are embedded, including stream chunks
+func init() {
+	msgpack.Register(websocket_server.ID{}, encodeID, decodeID)
+}
+
+func encodeID(enc *msgpack.Encoder, v reflect.Value) error {
+	return enc.Encode(v.Interface().(websocket_server.ID).Value())
+}
+
+func decodeID(dec *msgpack.Decoder, v reflect.Value) error {
+
+	val, err := dec.DecodeInterface()
+	if err != nil {
+		return err
+	}
+
+	id, err := websocket_server.NewIDFromValue(val)
+	if err != nil {
+		return err
+	}
+
+	v.Set(reflect.ValueOf(id))
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package msgpackrpc
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/weedbox/websocket-modules/jsonrpc"
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+// MessagePack messages use the same members as JSON-RPC 2.0 objects without "jsonrpc" version:
+//
+//	request:      {"id": 1, "method": "Foo", "params": [...]}
+//	notification: {"method": "Foo", "params": {...}}
+//	response:     {"id": 1, "result": ...}
+//	error:        {"id": 1, "error": {"code": -32601, "message": "Method not found"}}
+//
+// Batches are arrays of messages. Error codes are mapped the same way as the jsonrpc backend.
+type MsgPackRPC struct {
+}
+
+func New() *MsgPackRPC {
+	return &MsgPackRPC{}
+}
+
+// ConvertErrorCode reports the same error codes as JSON-RPC
+func (mp *MsgPackRPC) ConvertErrorCode(code websocket_server.RPCErrorCode) int64 {
+	return int64(jsonrpc.GetErrorCode(code))
+}
+
+func (mp *MsgPackRPC) IsBinary() bool {
+	return true
+}
+
+func (mp *MsgPackRPC) ParseRequest(r io.Reader) (*websocket_server.RPCRequest, error) {
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) == 0 {
+		return nil, websocket_server.NewError(websocket_server.ErrorCode_ParseError, nil)
+	}
+
+	var message interface{}
+	if err := mp.unmarshal(data, &message); err != nil {
+		return nil, websocket_server.NewError(websocket_server.ErrorCode_ParseError, nil)
+	}
+
+	return jsonrpc.ParseObject(message)
+}
+
+func (mp *MsgPackRPC) PrepareRequest(req *websocket_server.RPCRequest) ([]byte, error) {
+	return mp.marshal(jsonrpc.NewRequestObject(req))
+}
+
+func (mp *MsgPackRPC) PrepareResponse(res *websocket_server.RPCResponse) ([]byte, error) {
+
+	data, err := mp.marshal(jsonrpc.NewResponseObject(res))
+	if err != nil {
+		return mp.marshal(jsonrpc.NewErrorObject(res.ID, err))
+	}
+
+	return data, nil
+}
+
+func (mp *MsgPackRPC) PrepareBatchResponse(responses []*websocket_server.RPCResponse) ([]byte, error) {
+
+	var buf bytes.Buffer
+
+	// Members are encoded already, so only array header is needed
+	enc := msgpack.NewEncoder(&buf)
+	if err := enc.EncodeArrayLen(len(responses)); err != nil {
+		return []byte(""), err
+	}
+
+	for _, res := range responses {
+
+		data, err := mp.PrepareResponse(res)
+		if err != nil {
+			return []byte(""), err
+		}
+
+		buf.Write(data)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (mp *MsgPackRPC) PrepareNotification(eventName string, payload interface{}) ([]byte, error) {
+	return mp.marshal(jsonrpc.NewNotificationObject(eventName, payload))
+}
+
+func (mp *MsgPackRPC) marshal(v interface{}) ([]byte, error) {
+
+	var buf bytes.Buffer
+
+	enc := msgpack.NewEncoder(&buf)
+
+	// Share struct tags with JSON so typed handlers behave the same as jsonrpc
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+
+	if err := enc.Encode(v); err != nil {
+		return []byte(""), err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (mp *MsgPackRPC) unmarshal(data []byte, v interface{}) error {
+
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+
+	return dec.Decode(v)
+}
@@ -0,0 +1,235 @@
+package msgpackrpc_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/weedbox/websocket-modules/msgpackrpc"
+	"github.com/weedbox/websocket-modules/websocket_server"
+	"github.com/weedbox/websocket-modules/websocket_servertest"
+)
+
+type point struct {
+	X int `json:"x" validate:"required"`
+	Y int `json:"y"`
+}
+
+func decode(t *testing.T, data []byte) interface{} {
+
+	var v interface{}
+	if err := msgpack.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	return v
+}
+
+func TestRequestRoundTrip(t *testing.T) {
+
+	mp := msgpackrpc.New()
+
+	data, err := mp.PrepareRequest(&websocket_server.RPCRequest{
+		ID:          websocket_server.NewIntID(3),
+		Method:      "Move",
+		NamedParams: map[string]interface{}{"x": 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := mp.ParseRequest(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if req.ID != websocket_server.NewIntID(3) || req.Method != "Move" || req.Notification {
+		t.Fatalf("unexpected request %+v", req)
+	}
+
+	var p point
+	if err := websocket_server.BindParams(req, &p); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.X != 1 {
+		t.Fatalf("unexpected params %+v", p)
+	}
+
+	// Request without id is a notification
+	data, _ = msgpack.Marshal(map[string]interface{}{"method": "Log", "params": []interface{}{"a"}})
+
+	req, err = mp.ParseRequest(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !req.Notification || !reflect.DeepEqual(req.Params, []interface{}{"a"}) {
+		t.Fatalf("unexpected request %+v", req)
+	}
+}
+
+func TestResponse(t *testing.T) {
+
+	mp := msgpackrpc.New()
+
+	data, err := mp.PrepareResponse(&websocket_server.RPCResponse{
+		ID:     websocket_server.NewStringID("a"),
+		Result: &point{X: 1, Y: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{"id": "a", "result": map[string]interface{}{"x": int8(1), "y": int8(2)}}
+	if v := decode(t, data); !reflect.DeepEqual(v, expected) {
+		t.Fatalf("unexpected response %#v", v)
+	}
+
+	data, err = mp.PrepareResponse(&websocket_server.RPCResponse{
+		ID:    websocket_server.NewIntID(1),
+		Error: websocket_server.NewError(websocket_server.ErrorCode_NotFound, nil),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var res struct {
+		ID    websocket_server.ID `msgpack:"id"`
+		Error struct {
+			Code    int    `msgpack:"code"`
+			Message string `msgpack:"message"`
+		} `msgpack:"error"`
+	}
+
+	if err := msgpack.Unmarshal(data, &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if res.ID != websocket_server.NewIntID(1) || res.Error.Code != -32601 || res.Error.Message != "Method not found" {
+		t.Fatalf("unexpected response %+v", res)
+	}
+
+	data, err = mp.PrepareBatchResponse([]*websocket_server.RPCResponse{
+		{ID: websocket_server.NewIntID(1), Result: true},
+		{ID: websocket_server.NewIntID(2), Result: nil},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	batch := []interface{}{
+		map[string]interface{}{"id": int8(1), "result": true},
+		map[string]interface{}{"id": int8(2), "result": nil},
+	}
+	if v := decode(t, data); !reflect.DeepEqual(v, batch) {
+		t.Fatalf("unexpected batch %v", v)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+
+	mp := msgpackrpc.New()
+
+	var rpcErr *websocket_server.RPCError
+
+	_, err := mp.ParseRequest(bytes.NewReader([]byte{0xc1}))
+	if !errors.As(err, &rpcErr) || rpcErr.Code != websocket_server.ErrorCode_ParseError {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	data, _ := msgpack.Marshal(map[string]interface{}{"id": 1})
+
+	req, err := mp.ParseRequest(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !errors.As(req.Error, &rpcErr) || rpcErr.Code != websocket_server.ErrorCode_InvalidRequest {
+		t.Fatalf("unexpected error %v", req.Error)
+	}
+}
+
+func TestInvoke(t *testing.T) {
+
+	ra := websocket_server.NewRPCAdapter(websocket_server.WithRPCBackend(msgpackrpc.New()))
+	websocket_server.RegisterTyped(ra, "Move", func(c *websocket_server.Context, p *point) (*point, error) {
+		return &point{X: p.X + 1, Y: p.Y + 1}, nil
+	})
+
+	if !ra.IsBinary() {
+		t.Fatal("adapter should send binary frames")
+	}
+
+	var p point
+	if err := websocket_servertest.InvokeInto(context.Background(), ra, "Move", []interface{}{1, 2}, &p); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.X != 2 || p.Y != 3 {
+		t.Fatalf("unexpected result %+v", p)
+	}
+
+	_, err := websocket_servertest.Invoke(context.Background(), ra, "Move", map[string]interface{}{"y": 1})
+
+	var rpcErr *websocket_server.RPCError
+	// Wire code is converted back to the most generic one
+	if !errors.As(err, &rpcErr) || rpcErr.Code != websocket_server.ErrorCode_InvalidParams || !reflect.DeepEqual(rpcErr.Data, []interface{}{"x"}) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestID(t *testing.T) {
+
+	type message struct {
+		ID websocket_server.ID `msgpack:"id"`
+	}
+
+	big, _ := websocket_server.NewNumberID("18446744073709551615")
+
+	for _, id := range []websocket_server.ID{websocket_server.NewIntID(1), websocket_server.NewIntID(-70000), big, websocket_server.NewStringID("a"), websocket_server.NullID} {
+
+		data, err := msgpack.Marshal(&message{ID: id})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Encoded in native form
+		var raw map[string]interface{}
+		if err := msgpack.Unmarshal(data, &raw); err != nil {
+			t.Fatal(err)
+		}
+
+		if v, _ := websocket_server.NewIDFromValue(raw["id"]); v != id {
+			t.Fatalf("%v: unexpected native value %#v", id, raw["id"])
+		}
+
+		var msg message
+		if err := msgpack.Unmarshal(data, &msg); err != nil {
+			t.Fatal(err)
+		}
+
+		// Decoder leaves the field untouched for nil
+		if msg.ID != id && !(id.IsNull() && msg.ID.IsNull()) {
+			t.Fatalf("%v: unexpected id %v", id, msg.ID)
+		}
+	}
+
+	// ID which is embedded in payload is native as well
+	data, err := msgpackrpc.New().PrepareNotification(websocket_server.StreamChunkEvent, &websocket_server.StreamChunk{
+		ID:   websocket_server.NewStringID("s"),
+		Seq:  1,
+		Data: "x",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{"method": websocket_server.StreamChunkEvent, "params": map[string]interface{}{"id": "s", "seq": int8(1), "data": "x"}}
+	if v := decode(t, data); !reflect.DeepEqual(v, expected) {
+		t.Fatalf("unexpected notification %#v", v)
+	}
+}
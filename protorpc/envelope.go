@@ -0,0 +1,40 @@
+package protorpc
+
+import (
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative envelope.proto
+
+// getEnvelopeID converts oneof id of envelope into ID, it is absent if neither is set
+func getEnvelopeID(env *Envelope) websocket_server.ID {
+
+	switch id := env.GetId().(type) {
+	case *Envelope_IntId:
+		return websocket_server.NewIntID(id.IntId)
+	case *Envelope_StringId:
+		return websocket_server.NewStringID(id.StringId)
+	}
+
+	return websocket_server.ID{}
+}
+
+func setEnvelopeID(env *Envelope, id websocket_server.ID) {
+
+	switch id.Kind() {
+	case websocket_server.IDKind_String:
+		env.Id = &Envelope_StringId{StringId: id.String()}
+	case websocket_server.IDKind_Number:
+
+		// Only integers are able to be represented by int_id
+		n, ok := id.Int64()
+		if !ok {
+			env.Id = &Envelope_StringId{StringId: id.String()}
+			return
+		}
+
+		env.Id = &Envelope_IntId{IntId: n}
+	default:
+		env.Id = nil
+	}
+}
@@ -0,0 +1,427 @@
+package protorpc
+
+import (
+	"io"
+	"reflect"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/weedbox/websocket-modules/websocket_server"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+// ProtoRPC exchanges Envelope messages which are defined in envelope.proto. Payload of request
+// is unmarshalled into the message type which is registered for the method, then it is passed
+// to handler as the first positional parameter. Payload of methods without registered type is
+// decoded as JSON or google.protobuf.Struct into params, so typed handlers bind it the same way
+// as other backends do. Payload which is neither of them is passed as raw bytes.
+type ProtoRPC struct {
+	mutex sync.RWMutex
+	types map[string]protoreflect.MessageType
+}
+
+func New() *ProtoRPC {
+	return &ProtoRPC{
+		types: make(map[string]protoreflect.MessageType),
+	}
+}
+
+// RegisterMessageType specifies message type of params for the method
+func (pr *ProtoRPC) RegisterMessageType(method string, msg proto.Message) {
+	pr.mutex.Lock()
+	defer pr.mutex.Unlock()
+	pr.types[method] = msg.ProtoReflect().Type()
+}
+
+func (pr *ProtoRPC) UnregisterMessageType(method string) {
+	pr.mutex.Lock()
+	defer pr.mutex.Unlock()
+	delete(pr.types, method)
+}
+
+func (pr *ProtoRPC) getMessageType(method string) (protoreflect.MessageType, bool) {
+	pr.mutex.RLock()
+	defer pr.mutex.RUnlock()
+	mt, ok := pr.types[method]
+	return mt, ok
+}
+
+func (pr *ProtoRPC) IsBinary() bool {
+	return true
+}
+
+func (pr *ProtoRPC) ParseRequest(r io.Reader) (*websocket_server.RPCRequest, error) {
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	env := &Envelope{}
+	if err := proto.Unmarshal(data, env); err != nil {
+		return nil, websocket_server.NewError(websocket_server.ErrorCode_ParseError, nil)
+	}
+
+	if len(env.Batch) == 0 {
+		return pr.parseRequest(env), nil
+	}
+
+	batch := &websocket_server.RPCRequest{
+		Batch: make([]*websocket_server.RPCRequest, 0, len(env.Batch)),
+	}
+
+	for _, member := range env.Batch {
+		batch.Batch = append(batch.Batch, pr.parseRequest(member))
+	}
+
+	return batch, nil
+}
+
+// parseRequest always returns request, the error will be attached to the request if it is invalid
+func (pr *ProtoRPC) parseRequest(env *Envelope) *websocket_server.RPCRequest {
+
+	id := getEnvelopeID(env)
+
+	// Response to call which was initiated by server
+	if env.Kind == Envelope_RESPONSE {
+		return &websocket_server.RPCRequest{
+			ID:       id,
+			Response: pr.parseResponse(id, env),
+		}
+	}
+
+	if len(env.Method) == 0 {
+		return pr.invalidRequest(id, "method is required")
+	}
+
+	req := &websocket_server.RPCRequest{
+		ID:           id,
+		Method:       env.Method,
+		Params:       make([]interface{}, 0),
+		Notification: id.IsAbsent(),
+		Timeout:      time.Duration(env.Timeout) * time.Millisecond,
+	}
+
+	switch env.Method {
+	case websocket_server.CancelRequestMethod, websocket_server.StreamAckMethod:
+
+		// Control messages carry target ID and sequence with envelope
+		target := &Envelope{}
+		if err := proto.Unmarshal(env.Payload, target); err != nil {
+			req.Error = websocket_server.NewError(websocket_server.ErrorCode_InvalidParams, "invalid control payload")
+			return req
+		}
+
+		req.Params = []interface{}{getEnvelopeID(target).Value(), target.Seq}
+
+		return req
+	}
+
+	mt, ok := pr.getMessageType(env.Method)
+	if !ok {
+		decodeParams(req, env.Payload)
+		return req
+	}
+
+	msg := mt.New().Interface()
+	if err := proto.Unmarshal(env.Payload, msg); err != nil {
+		req.Error = websocket_server.NewError(websocket_server.ErrorCode_InvalidParams, err.Error())
+		return req
+	}
+
+	req.Params = []interface{}{msg}
+
+	return req
+}
+
+func (pr *ProtoRPC) parseResponse(id websocket_server.ID, env *Envelope) *websocket_server.RPCResponse {
+
+	res := &websocket_server.RPCResponse{
+		ID: id,
+	}
+
+	if env.Error != nil {
+
+		rpcError := &websocket_server.RPCError{
+			Code:    websocket_server.RPCErrorCode(env.Error.Code),
+			Message: env.Error.Message,
+		}
+
+		if len(env.Error.Data) > 0 {
+			var data interface{}
+			if err := json.Unmarshal([]byte(env.Error.Data), &data); err == nil {
+				rpcError.Data = data
+			}
+		}
+
+		res.Error = rpcError
+
+		return res
+	}
+
+	// Caller is responsible for unmarshalling the payload
+	res.Result = env.Payload
+
+	return res
+}
+
+func (pr *ProtoRPC) invalidRequest(id websocket_server.ID, reason string) *websocket_server.RPCRequest {
+
+	if id.IsAbsent() {
+		id = websocket_server.NullID
+	}
+
+	return &websocket_server.RPCRequest{
+		ID:    id,
+		Error: websocket_server.NewError(websocket_server.ErrorCode_InvalidRequest, reason),
+	}
+}
+
+func (pr *ProtoRPC) PrepareRequest(req *websocket_server.RPCRequest) ([]byte, error) {
+
+	params := req.Params
+	if req.HasNamedParams() {
+		params = req.NamedParams
+	}
+
+	// Single message is carried by payload as it is
+	if args, ok := params.([]interface{}); ok {
+		switch {
+		case len(args) == 0:
+			params = nil
+		case len(args) == 1 && isMessagePayload(args[0]):
+			params = args[0]
+		}
+	}
+
+	payload, err := pr.marshalParams(req.Method, params)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	env := &Envelope{
+		Kind:    Envelope_REQUEST,
+		Method:  req.Method,
+		Payload: payload,
+	}
+
+	setEnvelopeID(env, req.ID)
+
+	return proto.Marshal(env)
+}
+
+func (pr *ProtoRPC) PrepareResponse(res *websocket_server.RPCResponse) ([]byte, error) {
+
+	env, err := pr.createResponse(res)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	return proto.Marshal(env)
+}
+
+func (pr *ProtoRPC) PrepareBatchResponse(responses []*websocket_server.RPCResponse) ([]byte, error) {
+
+	env := &Envelope{
+		Kind:  Envelope_RESPONSE,
+		Batch: make([]*Envelope, 0, len(responses)),
+	}
+
+	for _, res := range responses {
+
+		member, err := pr.createResponse(res)
+		if err != nil {
+			return []byte(""), err
+		}
+
+		env.Batch = append(env.Batch, member)
+	}
+
+	return proto.Marshal(env)
+}
+
+func (pr *ProtoRPC) PrepareNotification(eventName string, payload interface{}) ([]byte, error) {
+
+	env := &Envelope{
+		Kind:   Envelope_NOTIFICATION,
+		Method: eventName,
+	}
+
+	if chunk, ok := payload.(*websocket_server.StreamChunk); ok {
+		setEnvelopeID(env, chunk.ID)
+		env.Seq = chunk.Seq
+		payload = chunk.Data
+	}
+
+	data, err := pr.marshalParams(eventName, payload)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	env.Payload = data
+
+	return proto.Marshal(env)
+}
+
+func (pr *ProtoRPC) createResponse(res *websocket_server.RPCResponse) (*Envelope, error) {
+
+	if res.Error != nil {
+		return pr.createError(res.ID, res.Error)
+	}
+
+	payload, err := marshalPayload(res.Result)
+	if err != nil {
+		return pr.createError(res.ID, err)
+	}
+
+	env := &Envelope{
+		Kind:    Envelope_RESPONSE,
+		Payload: payload,
+	}
+
+	setEnvelopeID(env, res.ID)
+
+	return env, nil
+}
+
+func (pr *ProtoRPC) createError(id websocket_server.ID, err error) (*Envelope, error) {
+
+	rpcError := websocket_server.ToRPCError(err)
+
+	info := &ErrorInfo{
+		Code:    int32(rpcError.Code),
+		Message: rpcError.Message,
+	}
+
+	if rpcError.Data != nil {
+		data, err := json.Marshal(rpcError.Data)
+		if err != nil {
+			return nil, err
+		}
+
+		info.Data = string(data)
+	}
+
+	env := &Envelope{
+		Kind:  Envelope_RESPONSE,
+		Error: info,
+	}
+
+	setEnvelopeID(env, id)
+
+	return env, nil
+}
+
+// marshalParams encodes params the same way as they are parsed, control methods carry target with envelope
+func (pr *ProtoRPC) marshalParams(method string, params interface{}) ([]byte, error) {
+
+	switch method {
+	case websocket_server.CancelRequestMethod, websocket_server.StreamAckMethod:
+	default:
+		return marshalPayload(params)
+	}
+
+	var id, seq interface{}
+	switch p := params.(type) {
+	case *Envelope:
+		return proto.Marshal(p)
+	case map[string]interface{}:
+		id, seq = p["id"], p["seq"]
+	case []interface{}:
+		if len(p) > 0 {
+			id = p[0]
+		}
+
+		if len(p) > 1 {
+			seq = p[1]
+		}
+	}
+
+	target := &Envelope{}
+
+	tid, err := websocket_server.NewIDFromValue(id)
+	if err != nil {
+		return nil, err
+	}
+
+	setEnvelopeID(target, tid)
+
+	if seq != nil {
+		v := reflect.ValueOf(seq)
+		switch {
+		case v.CanInt():
+			target.Seq = v.Int()
+		case v.CanUint():
+			target.Seq = int64(v.Uint())
+		case v.CanFloat():
+			target.Seq = int64(v.Float())
+		}
+	}
+
+	return proto.Marshal(target)
+}
+
+// marshalPayload encodes values other than messages and bytes as JSON
+func marshalPayload(v interface{}) ([]byte, error) {
+
+	switch val := v.(type) {
+	case nil:
+		return nil, nil
+	case proto.Message:
+		if reflect.ValueOf(val).IsNil() {
+			return nil, nil
+		}
+
+		return proto.Marshal(val)
+	case []byte:
+		return val, nil
+	}
+
+	return json.Marshal(v)
+}
+
+func isMessagePayload(v interface{}) bool {
+
+	switch v.(type) {
+	case proto.Message, []byte:
+		return true
+	}
+
+	return false
+}
+
+// decodeParams sets params from payload of method without registered type
+func decodeParams(req *websocket_server.RPCRequest, payload []byte) {
+
+	if len(payload) == 0 {
+		return
+	}
+
+	var v interface{}
+	if json.Valid(payload) && json.Unmarshal(payload, &v) == nil {
+		switch params := v.(type) {
+		case map[string]interface{}:
+			req.NamedParams = params
+		case []interface{}:
+			req.Params = params
+		default:
+			req.Params = []interface{}{params}
+		}
+
+		return
+	}
+
+	// Unknown fields mean the payload is not a Struct
+	s := &structpb.Struct{}
+	if err := proto.Unmarshal(payload, s); err == nil && len(s.ProtoReflect().GetUnknown()) == 0 {
+		req.NamedParams = s.AsMap()
+		return
+	}
+
+	req.Params = []interface{}{payload}
+}
@@ -0,0 +1,264 @@
+package protorpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/weedbox/websocket-modules/websocket_server"
+	"github.com/weedbox/websocket-modules/websocket_servertest"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func parse(t *testing.T, pr *ProtoRPC, env *Envelope) *websocket_server.RPCRequest {
+
+	data, err := proto.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := pr.ParseRequest(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return req
+}
+
+func unmarshalEnvelope(t *testing.T, data []byte) *Envelope {
+
+	env := &Envelope{}
+	if err := proto.Unmarshal(data, env); err != nil {
+		t.Fatal(err)
+	}
+
+	return env
+}
+
+func TestEnvelopeID(t *testing.T) {
+
+	fraction, _ := websocket_server.NewNumberID("1.5")
+
+	cases := []struct {
+		id       websocket_server.ID
+		expected websocket_server.ID
+	}{
+		{websocket_server.NewIntID(-7), websocket_server.NewIntID(-7)},
+		{websocket_server.NewStringID("a"), websocket_server.NewStringID("a")},
+		{fraction, websocket_server.NewStringID("1.5")},
+		{websocket_server.ID{}, websocket_server.ID{}},
+		{websocket_server.NullID, websocket_server.ID{}},
+	}
+
+	for _, c := range cases {
+
+		env := &Envelope{}
+		setEnvelopeID(env, c.id)
+
+		data, err := proto.Marshal(env)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if id := getEnvelopeID(unmarshalEnvelope(t, data)); id != c.expected {
+			t.Fatalf("%v: unexpected id %v", c.id, id)
+		}
+	}
+}
+
+func TestRequestRoundTrip(t *testing.T) {
+
+	pr := New()
+	pr.RegisterMessageType("Len", &wrapperspb.StringValue{})
+
+	data, err := pr.PrepareRequest(&websocket_server.RPCRequest{
+		ID:     websocket_server.NewIntID(1),
+		Method: "Len",
+		Params: []interface{}{wrapperspb.String("abc")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := pr.ParseRequest(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, ok := req.Params.([]interface{})[0].(*wrapperspb.StringValue)
+	if !ok || msg.Value != "abc" || req.ID != websocket_server.NewIntID(1) || req.Notification {
+		t.Fatalf("unexpected request %+v", req)
+	}
+
+	// Payload which is not the registered type is invalid
+	req = parse(t, pr, &Envelope{Method: "Len", Payload: []byte{0xff}, Id: &Envelope_IntId{IntId: 2}})
+	if req.Error == nil {
+		t.Fatal("invalid payload was accepted")
+	}
+
+	req = parse(t, pr, &Envelope{Id: &Envelope_IntId{IntId: 3}})
+	if req.Error == nil || req.ID != websocket_server.NewIntID(3) {
+		t.Fatal("request without method was accepted")
+	}
+}
+
+func TestUntypedPayload(t *testing.T) {
+
+	pr := New()
+
+	req := parse(t, pr, &Envelope{Method: "m", Payload: []byte(`{"a":1}`)})
+	if !req.Notification || !reflect.DeepEqual(req.NamedParams, map[string]interface{}{"a": float64(1)}) {
+		t.Fatalf("unexpected request %+v", req)
+	}
+
+	req = parse(t, pr, &Envelope{Method: "m", Payload: []byte(`[1,"b"]`)})
+	if !reflect.DeepEqual(req.Params, []interface{}{float64(1), "b"}) {
+		t.Fatalf("unexpected params %v", req.Params)
+	}
+
+	req = parse(t, pr, &Envelope{Method: "m", Payload: []byte(`"s"`)})
+	if !reflect.DeepEqual(req.Params, []interface{}{"s"}) {
+		t.Fatalf("unexpected params %v", req.Params)
+	}
+
+	s, _ := structpb.NewStruct(map[string]interface{}{"name": "x"})
+	payload, _ := proto.Marshal(s)
+
+	req = parse(t, pr, &Envelope{Method: "m", Payload: payload})
+	if !reflect.DeepEqual(req.NamedParams, map[string]interface{}{"name": "x"}) {
+		t.Fatalf("unexpected params %v", req.NamedParams)
+	}
+
+	// Neither JSON nor Struct
+	payload, _ = proto.Marshal(wrapperspb.Int64(5))
+
+	req = parse(t, pr, &Envelope{Method: "m", Payload: payload})
+	if !reflect.DeepEqual(req.Params, []interface{}{payload}) {
+		t.Fatalf("unexpected params %v", req.Params)
+	}
+}
+
+func TestResponse(t *testing.T) {
+
+	pr := New()
+
+	data, err := pr.PrepareResponse(&websocket_server.RPCResponse{
+		ID:     websocket_server.NewStringID("a"),
+		Result: map[string]interface{}{"ok": true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := unmarshalEnvelope(t, data)
+	if env.Kind != Envelope_RESPONSE || env.GetStringId() != "a" || string(env.Payload) != `{"ok":true}` {
+		t.Fatalf("unexpected envelope %v", env)
+	}
+
+	data, err = pr.PrepareResponse(&websocket_server.RPCResponse{
+		ID:    websocket_server.NewIntID(1),
+		Error: websocket_server.NewError(websocket_server.ErrorCode_InvalidParams, []string{"x"}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Errors are parsed back as responses to calls initiated by server
+	req, err := pr.ParseRequest(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rpcErr *websocket_server.RPCError
+	if req.Response == nil || !errors.As(req.Response.Error, &rpcErr) {
+		t.Fatalf("unexpected request %+v", req)
+	}
+
+	if rpcErr.Code != websocket_server.ErrorCode_InvalidParams || !reflect.DeepEqual(rpcErr.Data, []interface{}{"x"}) {
+		t.Fatalf("unexpected error %+v", rpcErr)
+	}
+
+	data, err = pr.PrepareBatchResponse([]*websocket_server.RPCResponse{
+		{ID: websocket_server.NewIntID(1), Result: wrapperspb.Bool(true)},
+		{ID: websocket_server.NewIntID(2), Error: websocket_server.NewError(websocket_server.ErrorCode_NotFound, nil)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env = unmarshalEnvelope(t, data)
+	if len(env.Batch) != 2 || env.Batch[0].GetIntId() != 1 || env.Batch[1].Error.GetCode() != int32(websocket_server.ErrorCode_NotFound) {
+		t.Fatalf("unexpected batch %v", env)
+	}
+}
+
+func TestControlMessages(t *testing.T) {
+
+	pr := New()
+
+	data, err := pr.PrepareRequest(&websocket_server.RPCRequest{
+		Method:      websocket_server.StreamAckMethod,
+		NamedParams: map[string]interface{}{"id": "s", "seq": 3},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := pr.ParseRequest(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !req.Notification || !reflect.DeepEqual(req.Params, []interface{}{"s", int64(3)}) {
+		t.Fatalf("unexpected request %+v", req)
+	}
+
+	// Chunk carries id and seq with envelope
+	data, err = pr.PrepareNotification(websocket_server.StreamChunkEvent, &websocket_server.StreamChunk{
+		ID:   websocket_server.NewIntID(4),
+		Seq:  2,
+		Data: "x",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := unmarshalEnvelope(t, data)
+	if env.Kind != Envelope_NOTIFICATION || env.GetIntId() != 4 || env.Seq != 2 || string(env.Payload) != `"x"` {
+		t.Fatalf("unexpected envelope %v", env)
+	}
+}
+
+func TestRegister(t *testing.T) {
+
+	pr := New()
+	ra := websocket_server.NewRPCAdapter(websocket_server.WithRPCBackend(pr))
+
+	Register(ra, pr, "Len", func(c *websocket_server.Context, s *wrapperspb.StringValue) (*wrapperspb.Int64Value, error) {
+		return wrapperspb.Int64(int64(len(s.Value))), nil
+	})
+
+	res, err := websocket_servertest.Invoke(context.Background(), ra, "Len", []interface{}{wrapperspb.String("abcd")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Caller unmarshals payload of response
+	n := &wrapperspb.Int64Value{}
+	if err := proto.Unmarshal(res.([]byte), n); err != nil {
+		t.Fatal(err)
+	}
+
+	if n.Value != 4 {
+		t.Fatalf("unexpected result %d", n.Value)
+	}
+
+	m, _ := ra.GetMethod("Len")
+	if m.ParamsType != reflect.TypeOf(&wrapperspb.StringValue{}) {
+		t.Fatalf("unexpected params type %v", m.ParamsType)
+	}
+}
@@ -0,0 +1,40 @@
+package protorpc
+
+import (
+	"reflect"
+
+	"github.com/weedbox/websocket-modules/websocket_server"
+	"google.golang.org/protobuf/proto"
+)
+
+type ProtoRPCFunc[Req proto.Message, Res proto.Message] func(*websocket_server.Context, Req) (Res, error)
+
+// Register registers a handler with concrete request and response message types. Payload of
+// request is unmarshalled into Req by backend before it reaches the handler.
+func Register[Req proto.Message, Res proto.Message](a websocket_server.Adapter, pr *ProtoRPC, method string, fn ProtoRPCFunc[Req, Res], opts ...websocket_server.RPCMethodOpt) error {
+
+	var zero Req
+	pr.RegisterMessageType(method, zero)
+
+	handler := func(c *websocket_server.Context) (interface{}, error) {
+
+		params, ok := c.Param(0).(Req)
+		if !ok {
+			return nil, websocket_server.NewError(websocket_server.ErrorCode_InvalidParams, "unexpected message type")
+		}
+
+		return fn(c, params)
+	}
+
+	paramsType := reflect.TypeOf((*Req)(nil)).Elem()
+	resultType := reflect.TypeOf((*Res)(nil)).Elem()
+
+	opts = append([]websocket_server.RPCMethodOpt{websocket_server.WithMethodTypes(paramsType, resultType)}, opts...)
+
+	if err := a.Register(method, handler, opts...); err != nil {
+		pr.UnregisterMessageType(method)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,241 @@
+package stomp_adapter
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	CommandConnect     = "CONNECT"
+	CommandStomp       = "STOMP"
+	CommandConnected   = "CONNECTED"
+	CommandSend        = "SEND"
+	CommandSubscribe   = "SUBSCRIBE"
+	CommandUnsubscribe = "UNSUBSCRIBE"
+	CommandAck         = "ACK"
+	CommandNack        = "NACK"
+	CommandDisconnect  = "DISCONNECT"
+	CommandMessage     = "MESSAGE"
+	CommandReceipt     = "RECEIPT"
+	CommandError       = "ERROR"
+)
+
+var (
+	ErrMalformedFrame = errors.New("stomp: malformed frame")
+)
+
+var headerEscaper = strings.NewReplacer("\\", "\\\\", "\r", "\\r", "\n", "\\n", ":", "\\c")
+
+type Frame struct {
+	Command string
+	Headers map[string]string
+	Body    []byte
+}
+
+func NewFrame(command string) *Frame {
+	return &Frame{
+		Command: command,
+		Headers: make(map[string]string),
+	}
+}
+
+func (f *Frame) Get(key string) string {
+	return f.Headers[key]
+}
+
+func (f *Frame) Set(key string, value string) *Frame {
+	f.Headers[key] = value
+	return f
+}
+
+// Header values of CONNECT and CONNECTED frames are not escaped for backward compatibility
+func (f *Frame) escaped() bool {
+	return f.Command != CommandConnect && f.Command != CommandConnected
+}
+
+func (f *Frame) Marshal() []byte {
+
+	var buf bytes.Buffer
+
+	buf.WriteString(f.Command)
+	buf.WriteByte('\n')
+
+	keys := make([]string, 0, len(f.Headers))
+	for k := range f.Headers {
+		if k == "content-length" {
+			continue
+		}
+
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+
+		v := f.Headers[k]
+		if f.escaped() {
+			k = headerEscaper.Replace(k)
+			v = headerEscaper.Replace(v)
+		}
+
+		buf.WriteString(k)
+		buf.WriteByte(':')
+		buf.WriteString(v)
+		buf.WriteByte('\n')
+	}
+
+	if len(f.Body) > 0 {
+		buf.WriteString("content-length:")
+		buf.WriteString(strconv.Itoa(len(f.Body)))
+		buf.WriteByte('\n')
+	}
+
+	buf.WriteByte('\n')
+	buf.Write(f.Body)
+	buf.WriteByte(0)
+
+	return buf.Bytes()
+}
+
+// ParseFrames parses all frames in data, heart-beats between frames are skipped
+func ParseFrames(data []byte) ([]*Frame, error) {
+
+	frames := make([]*Frame, 0, 1)
+
+	for {
+
+		// Skip heart-beats
+		data = bytes.TrimLeft(data, "\r\n")
+		if len(data) == 0 {
+			return frames, nil
+		}
+
+		f, rest, err := parseFrame(data)
+		if err != nil {
+			return nil, err
+		}
+
+		frames = append(frames, f)
+		data = rest
+	}
+}
+
+func parseFrame(data []byte) (*Frame, []byte, error) {
+
+	line, data, ok := readLine(data)
+	if !ok || len(line) == 0 {
+		return nil, nil, ErrMalformedFrame
+	}
+
+	f := NewFrame(string(line))
+
+	// Headers
+	for {
+
+		line, data, ok = readLine(data)
+		if !ok {
+			return nil, nil, ErrMalformedFrame
+		}
+
+		if len(line) == 0 {
+			break
+		}
+
+		idx := bytes.IndexByte(line, ':')
+		if idx < 0 {
+			return nil, nil, ErrMalformedFrame
+		}
+
+		k := string(line[:idx])
+		v := string(line[idx+1:])
+
+		if f.escaped() {
+			var err error
+			if k, err = unescape(k); err != nil {
+				return nil, nil, err
+			}
+
+			if v, err = unescape(v); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		// Only the first header entry is used if there are repeated entries
+		if _, ok := f.Headers[k]; !ok {
+			f.Headers[k] = v
+		}
+	}
+
+	// Body
+	if val, ok := f.Headers["content-length"]; ok {
+
+		length, err := strconv.Atoi(val)
+		if err != nil || length < 0 || length >= len(data) || data[length] != 0 {
+			return nil, nil, ErrMalformedFrame
+		}
+
+		f.Body = data[:length]
+
+		return f, data[length+1:], nil
+	}
+
+	idx := bytes.IndexByte(data, 0)
+	if idx < 0 {
+		return nil, nil, ErrMalformedFrame
+	}
+
+	f.Body = data[:idx]
+
+	return f, data[idx+1:], nil
+}
+
+func readLine(data []byte) ([]byte, []byte, bool) {
+
+	idx := bytes.IndexByte(data, '\n')
+	if idx < 0 {
+		return nil, nil, false
+	}
+
+	return bytes.TrimSuffix(data[:idx], []byte("\r")), data[idx+1:], true
+}
+
+func unescape(s string) (string, error) {
+
+	if !strings.Contains(s, "\\") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		if i == len(s) {
+			return "", ErrMalformedFrame
+		}
+
+		switch s[i] {
+		case 'r':
+			b.WriteByte('\r')
+		case 'n':
+			b.WriteByte('\n')
+		case 'c':
+			b.WriteByte(':')
+		case '\\':
+			b.WriteByte('\\')
+		default:
+			// Undefined escape sequences must be treated as fatal error
+			return "", ErrMalformedFrame
+		}
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,66 @@
+package stomp_adapter
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFrameRoundTrip(t *testing.T) {
+
+	f := NewFrame(CommandSend)
+	f.Set("destination", "/queue/a:b")
+	f.Set("note", "line1\nline2\\")
+	f.Body = []byte("hello\x00world")
+
+	frames, err := ParseFrames(append(append([]byte("\n\r\n"), f.Marshal()...), f.Marshal()...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(frames) != 2 {
+		t.Fatalf("expected 2 frames, got %d", len(frames))
+	}
+
+	for _, parsed := range frames {
+
+		if parsed.Command != CommandSend {
+			t.Fatalf("unexpected command %s", parsed.Command)
+		}
+
+		if parsed.Get("destination") != "/queue/a:b" || parsed.Get("note") != "line1\nline2\\" {
+			t.Fatalf("unexpected headers %v", parsed.Headers)
+		}
+
+		if !bytes.Equal(parsed.Body, f.Body) {
+			t.Fatalf("unexpected body %q", parsed.Body)
+		}
+	}
+}
+
+func TestConnectFrameIsNotEscaped(t *testing.T) {
+
+	frames, err := ParseFrames([]byte("CONNECT\nlogin:a\\cb\n\n\x00"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if frames[0].Get("login") != "a\\cb" {
+		t.Fatalf("unexpected login %q", frames[0].Get("login"))
+	}
+}
+
+func TestParseMalformedFrame(t *testing.T) {
+
+	cases := []string{
+		"SEND\ndestination:/a\n\nbody",
+		"SEND\ncontent-length:10\n\nshort\x00",
+		"SEND\nbad-header\n\n\x00",
+		"SEND\nkey:\\x\n\n\x00",
+	}
+
+	for _, c := range cases {
+		if _, err := ParseFrames([]byte(c)); err != ErrMalformedFrame {
+			t.Fatalf("%q: expected malformed frame, got %v", c, err)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package stomp_adapter
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+const (
+	AckMode_Auto             = "auto"
+	AckMode_Client           = "client"
+	AckMode_ClientIndividual = "client-individual"
+)
+
+type Subscription struct {
+	ID          string
+	Destination string
+	AckMode     string
+}
+
+type pendingMessage struct {
+	seq          int64
+	subscription *Subscription
+	frame        *Frame
+}
+
+type session struct {
+	lastSent     int64
+	lastReceived int64
+
+	client        websocket_server.Client
+	mutex         sync.Mutex
+	connected     bool
+	outgoing      time.Duration
+	incoming      time.Duration
+	subscriptions map[string]*Subscription
+	pending       map[string]*pendingMessage
+
+	// Frames which are waiting to be handled in order
+	queue    []*Frame
+	draining bool
+}
+
+func newSession(c websocket_server.Client) *session {
+
+	s := &session{
+		client:        c,
+		subscriptions: make(map[string]*Subscription),
+		pending:       make(map[string]*pendingMessage),
+	}
+
+	now := time.Now().UnixNano()
+	s.lastSent = now
+	s.lastReceived = now
+
+	return s
+}
+
+func (s *session) send(data []byte) error {
+	atomic.StoreInt64(&s.lastSent, time.Now().UnixNano())
+	return s.client.Send(data)
+}
+
+func (s *session) touch() {
+	atomic.StoreInt64(&s.lastReceived, time.Now().UnixNano())
+}
+
+// enqueue returns true if frame is the first one, the caller should start draining the queue
+func (s *session) enqueue(f *Frame) bool {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.queue = append(s.queue, f)
+
+	if s.draining {
+		return false
+	}
+
+	s.draining = true
+
+	return true
+}
+
+// dequeue returns false once queue is empty, the next frame will start draining again
+func (s *session) dequeue() (*Frame, bool) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.queue) == 0 {
+		s.draining = false
+		return nil, false
+	}
+
+	f := s.queue[0]
+	s.queue[0] = nil
+	s.queue = s.queue[1:]
+
+	return f, true
+}
+
+// discard drops frames which are waiting since connection is going to be closed, the queue will
+// never be drained again
+func (s *session) discard() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.queue = nil
+	s.draining = true
+}
+
+func (s *session) isConnected() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.connected
+}
+
+func (s *session) connect(outgoing time.Duration, incoming time.Duration) bool {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.connected {
+		return false
+	}
+
+	s.connected = true
+	s.outgoing = outgoing
+	s.incoming = incoming
+
+	return true
+}
+
+func (s *session) heartbeat() (time.Duration, time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.outgoing, s.incoming
+}
+
+func (s *session) subscribe(sub *Subscription) bool {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.subscriptions[sub.ID]; ok {
+		return false
+	}
+
+	s.subscriptions[sub.ID] = sub
+
+	return true
+}
+
+func (s *session) unsubscribe(id string) bool {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.subscriptions[id]; !ok {
+		return false
+	}
+
+	delete(s.subscriptions, id)
+
+	// Messages which are not acknowledged are dropped with the subscription
+	for ackID, p := range s.pending {
+		if p.subscription.ID == id {
+			delete(s.pending, ackID)
+		}
+	}
+
+	return true
+}
+
+func (s *session) getSubscriptions(destination string) []*Subscription {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	subs := make([]*Subscription, 0)
+	for _, sub := range s.subscriptions {
+		if sub.Destination == destination {
+			subs = append(subs, sub)
+		}
+	}
+
+	return subs
+}
+
+func (s *session) track(ackID string, p *pendingMessage) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.pending[ackID] = p
+}
+
+// settle removes acknowledged message, all previous messages of the same subscription are
+// acknowledged together in client mode
+func (s *session) settle(ackID string) ([]*pendingMessage, bool) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	target, ok := s.pending[ackID]
+	if !ok {
+		return nil, false
+	}
+
+	if target.subscription.AckMode != AckMode_Client {
+		delete(s.pending, ackID)
+		return []*pendingMessage{target}, true
+	}
+
+	settled := make([]*pendingMessage, 0)
+	for id, p := range s.pending {
+		if p.subscription == target.subscription && p.seq <= target.seq {
+			settled = append(settled, p)
+			delete(s.pending, id)
+		}
+	}
+
+	sort.Slice(settled, func(i, j int) bool {
+		return settled[i].seq < settled[j].seq
+	})
+
+	return settled, true
+}
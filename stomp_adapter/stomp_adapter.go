@@ -0,0 +1,655 @@
+package stomp_adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+const Version = "1.2"
+
+// Subprotocol should be accepted by endpoint for browser clients
+const Subprotocol = "v12.stomp"
+
+const DefaultServerName = "websocket-modules"
+
+var (
+	ErrDisconnected = errors.New("stomp: disconnected")
+)
+
+// DeliveryError carries errors of clients which failed to receive message
+type DeliveryError struct {
+	Errors []error
+}
+
+func (e *DeliveryError) Error() string {
+
+	messages := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		messages = append(messages, err.Error())
+	}
+
+	return "stomp: failed to deliver: " + strings.Join(messages, "; ")
+}
+
+func (e *DeliveryError) Unwrap() []error {
+	return e.Errors
+}
+
+type StompAdapterOpt func(*StompAdapter)
+type DestinationHandler func(*websocket_server.Context) (interface{}, error)
+
+// AuthFunc verifies CONNECT frame, connection will be rejected if error is returned
+type AuthFunc func(c websocket_server.Client, f *Frame) error
+
+// NackFunc is called with messages which were rejected by client
+type NackFunc func(c websocket_server.Client, sub *Subscription, msg *Frame)
+
+type StompAdapter struct {
+	messageSeq int64
+
+	requestQueue  *websocket_server.RequestQueue
+	handlers      map[string]DestinationHandler
+	sessions      map[uuid.UUID]*session
+	sendInterval  time.Duration
+	recvInterval  time.Duration
+	authenticator AuthFunc
+	nackHandler   NackFunc
+	serverName    string
+	done          chan struct{}
+	closeOnce     sync.Once
+	mutex         sync.RWMutex
+	sessionMutex  sync.RWMutex
+}
+
+// WithHeartbeat sets the smallest intervals server is able to send heart-beats and wants to receive them
+func WithHeartbeat(send time.Duration, receive time.Duration) StompAdapterOpt {
+	return func(sa *StompAdapter) {
+		sa.sendInterval = send
+		sa.recvInterval = receive
+	}
+}
+
+func WithAuthenticator(fn AuthFunc) StompAdapterOpt {
+	return func(sa *StompAdapter) {
+		sa.authenticator = fn
+	}
+}
+
+func WithNackHandler(fn NackFunc) StompAdapterOpt {
+	return func(sa *StompAdapter) {
+		sa.nackHandler = fn
+	}
+}
+
+func WithServerName(name string) StompAdapterOpt {
+	return func(sa *StompAdapter) {
+		sa.serverName = name
+	}
+}
+
+func New(opts ...StompAdapterOpt) *StompAdapter {
+
+	sa := &StompAdapter{
+		requestQueue: websocket_server.NewRequestQueue(),
+		handlers:     make(map[string]DestinationHandler),
+		sessions:     make(map[uuid.UUID]*session),
+		serverName:   DefaultServerName,
+		done:         make(chan struct{}),
+	}
+
+	for _, o := range opts {
+		o(sa)
+	}
+
+	sa.requestQueue.Consume(sa.consume)
+
+	// Single ticker serves heart-beats of all clients
+	if tick := sa.tickInterval(); tick > 0 {
+		go sa.watch(tick)
+	}
+
+	return sa
+}
+
+// Close stops heart-beats, connected clients are not closed
+func (sa *StompAdapter) Close() {
+	sa.closeOnce.Do(func() {
+		close(sa.done)
+	})
+}
+
+// GetFrame returns the SEND frame which is handled by the context
+func GetFrame(c *websocket_server.Context) *Frame {
+	f, _ := c.Param(0).(*Frame)
+	return f
+}
+
+// Handle routes SEND frames of the destination to handler. The returned value will be delivered to
+// the "reply-to" destination of the sender if it was specified.
+func (sa *StompAdapter) Handle(destination string, fn DestinationHandler) {
+	sa.mutex.Lock()
+	defer sa.mutex.Unlock()
+	sa.handlers[destination] = fn
+}
+
+func (sa *StompAdapter) Unhandle(destination string) {
+	sa.mutex.Lock()
+	defer sa.mutex.Unlock()
+	delete(sa.handlers, destination)
+}
+
+func (sa *StompAdapter) getHandler(destination string) (DestinationHandler, bool) {
+	sa.mutex.RLock()
+	defer sa.mutex.RUnlock()
+	fn, ok := sa.handlers[destination]
+	return fn, ok
+}
+
+// SendTo delivers message to all clients which subscribed the destination, clients which failed are
+// skipped and their errors are returned together
+func (sa *StompAdapter) SendTo(destination string, body interface{}) error {
+
+	sa.sessionMutex.RLock()
+	sessions := make([]*session, 0, len(sa.sessions))
+	for _, s := range sa.sessions {
+		sessions = append(sessions, s)
+	}
+	sa.sessionMutex.RUnlock()
+
+	errs := make([]error, 0)
+	for _, s := range sessions {
+
+		// Failure of a client should not affect others
+		if err := sa.deliver(s, destination, body); err != nil && !errors.Is(err, websocket_server.ErrConnectionClosed) {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return &DeliveryError{Errors: errs}
+	}
+
+	return nil
+}
+
+// SendToClient delivers message to subscriptions of the destination on specific client
+func (sa *StompAdapter) SendToClient(c websocket_server.Client, destination string, body interface{}) error {
+	return sa.deliver(sa.getSession(c), destination, body)
+}
+
+func (sa *StompAdapter) deliver(s *session, destination string, body interface{}) error {
+
+	subs := s.getSubscriptions(destination)
+	if len(subs) == 0 {
+		return nil
+	}
+
+	f, err := sa.createMessage(destination, body)
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range subs {
+
+		msg := NewFrame(CommandMessage)
+		for k, v := range f.Headers {
+			msg.Set(k, v)
+		}
+
+		// Every subscription gets its own message to be acknowledged separately
+		seq := atomic.AddInt64(&sa.messageSeq, 1)
+		msg.Body = f.Body
+		msg.Set("subscription", sub.ID)
+		msg.Set("message-id", strconv.FormatInt(seq, 10))
+
+		if sub.AckMode != AckMode_Auto {
+			msg.Set("ack", msg.Get("message-id"))
+			s.track(msg.Get("ack"), &pendingMessage{
+				seq:          seq,
+				subscription: sub,
+				frame:        msg,
+			})
+		}
+
+		if err := s.send(msg.Marshal()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (sa *StompAdapter) createMessage(destination string, body interface{}) (*Frame, error) {
+
+	f := NewFrame(CommandMessage)
+	f.Set("destination", destination)
+
+	switch b := body.(type) {
+	case nil:
+	case []byte:
+		f.Body = b
+		f.Set("content-type", "application/octet-stream")
+	case string:
+		f.Body = []byte(b)
+		f.Set("content-type", "text/plain;charset=utf-8")
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+
+		f.Body = data
+		f.Set("content-type", "application/json")
+	}
+
+	return f, nil
+}
+
+func (sa *StompAdapter) HandleMessage(c websocket_server.Client) error {
+
+	data, err := io.ReadAll(c.GetReader())
+	if err != nil {
+		return err
+	}
+
+	s := sa.getSession(c)
+	s.touch()
+
+	frames, err := ParseFrames(data)
+	if err != nil {
+		return sa.fail(s, nil, "malformed frame")
+	}
+
+	// Frames of a connection are handled in order by one worker at a time, so handlers of SEND frames
+	// are able to take a while without blocking the poller
+	for _, f := range frames {
+		if s.enqueue(f) {
+			sa.requestQueue.Push(websocket_server.NewContext(c, &websocket_server.RPCRequest{
+				Notification: true,
+			}))
+		}
+	}
+
+	return nil
+}
+
+// handleFrame returns error only if the connection should be closed
+func (sa *StompAdapter) handleFrame(s *session, f *Frame) error {
+
+	switch f.Command {
+	case CommandConnect, CommandStomp:
+		return sa.connect(s, f)
+	}
+
+	if !s.isConnected() {
+		return sa.fail(s, f, "not connected")
+	}
+
+	switch f.Command {
+	case CommandSend:
+		return sa.handleSend(s, f)
+	case CommandSubscribe:
+		return sa.subscribe(s, f)
+	case CommandUnsubscribe:
+		if len(f.Get("id")) == 0 {
+			return sa.fail(s, f, "id is required")
+		}
+
+		if !s.unsubscribe(f.Get("id")) {
+			return sa.fail(s, f, "no such subscription")
+		}
+
+		return sa.receipt(s, f)
+	case CommandAck, CommandNack:
+		return sa.ack(s, f)
+	case CommandDisconnect:
+		if err := sa.receipt(s, f); err != nil {
+			return err
+		}
+
+		return ErrDisconnected
+	}
+
+	return sa.fail(s, f, fmt.Sprintf("unsupported command %s", f.Command))
+}
+
+func (sa *StompAdapter) connect(s *session, f *Frame) error {
+
+	// Only 1.2 is supported, 1.0 is assumed if version is not specified
+	if !strings.Contains(","+f.Get("accept-version")+",", ","+Version+",") {
+		return sa.fail(s, f, "supported protocol versions are "+Version)
+	}
+
+	if sa.authenticator != nil {
+		if err := sa.authenticator(s.client, f); err != nil {
+			return sa.fail(s, f, err.Error())
+		}
+	}
+
+	cx, cy, ok := parseHeartbeat(f.Get("heart-beat"))
+	if !ok {
+		return sa.fail(s, f, "invalid heart-beat")
+	}
+
+	outgoing := negotiate(sa.sendInterval, cy)
+	incoming := negotiate(sa.recvInterval, cx)
+
+	if !s.connect(outgoing, incoming) {
+		return sa.fail(s, f, "already connected")
+	}
+
+	connected := NewFrame(CommandConnected)
+	connected.Set("version", Version)
+	connected.Set("heart-beat", fmt.Sprintf("%d,%d", sa.sendInterval.Milliseconds(), sa.recvInterval.Milliseconds()))
+	connected.Set("server", sa.serverName)
+	connected.Set("session", s.client.GetClientID().String())
+
+	return s.send(connected.Marshal())
+}
+
+func (sa *StompAdapter) subscribe(s *session, f *Frame) error {
+
+	sub := &Subscription{
+		ID:          f.Get("id"),
+		Destination: f.Get("destination"),
+		AckMode:     f.Get("ack"),
+	}
+
+	if len(sub.ID) == 0 || len(sub.Destination) == 0 {
+		return sa.fail(s, f, "id and destination are required")
+	}
+
+	switch sub.AckMode {
+	case "":
+		sub.AckMode = AckMode_Auto
+	case AckMode_Auto, AckMode_Client, AckMode_ClientIndividual:
+	default:
+		return sa.fail(s, f, "invalid ack mode")
+	}
+
+	if !s.subscribe(sub) {
+		return sa.fail(s, f, "subscription id is in use")
+	}
+
+	return sa.receipt(s, f)
+}
+
+func (sa *StompAdapter) ack(s *session, f *Frame) error {
+
+	id := f.Get("id")
+	if len(id) == 0 {
+		return sa.fail(s, f, "id is required")
+	}
+
+	settled, ok := s.settle(id)
+	if !ok {
+		return sa.fail(s, f, "no such message")
+	}
+
+	if f.Command == CommandNack && sa.nackHandler != nil {
+		for _, p := range settled {
+			sa.nackHandler(s.client, p.subscription, p.frame)
+		}
+	}
+
+	return sa.receipt(s, f)
+}
+
+func (sa *StompAdapter) handleSend(s *session, f *Frame) error {
+
+	destination := f.Get("destination")
+	if len(destination) == 0 {
+		return sa.fail(s, f, "destination is required")
+	}
+
+	if len(f.Get("transaction")) > 0 {
+		return sa.fail(s, f, "transactions are not supported")
+	}
+
+	fn, ok := sa.getHandler(destination)
+	if !ok {
+		return sa.fail(s, f, "no handler for destination")
+	}
+
+	c := websocket_server.NewContext(s.client, &websocket_server.RPCRequest{
+		Method:       destination,
+		Params:       []interface{}{f},
+		Notification: true,
+	})
+	defer c.Cancel()
+
+	returnedValue, err := fn(c)
+	if err != nil {
+		return sa.fail(s, f, err.Error())
+	}
+
+	if replyTo := f.Get("reply-to"); len(replyTo) > 0 && returnedValue != nil {
+		if err := sa.deliver(s, replyTo, returnedValue); err != nil {
+			return err
+		}
+	}
+
+	return sa.receipt(s, f)
+}
+
+// consume drains frames of the session, connection will be closed if any of them failed
+func (sa *StompAdapter) consume(c *websocket_server.Context) error {
+
+	defer c.Cancel()
+
+	s := sa.getSession(c.GetClient())
+
+	for {
+		f, ok := s.dequeue()
+		if !ok {
+			return nil
+		}
+
+		if err := sa.handleFrame(s, f); err != nil {
+			s.discard()
+			return sa.closeClient(s)
+		}
+	}
+}
+
+func (sa *StompAdapter) receipt(s *session, f *Frame) error {
+
+	id := f.Get("receipt")
+	if len(id) == 0 {
+		return nil
+	}
+
+	r := NewFrame(CommandReceipt)
+	r.Set("receipt-id", id)
+
+	return s.send(r.Marshal())
+}
+
+// fail sends ERROR frame, the connection must be closed after that
+func (sa *StompAdapter) fail(s *session, f *Frame, message string) error {
+
+	e := NewFrame(CommandError)
+	e.Set("message", message)
+
+	if f != nil {
+		if id := f.Get("receipt"); len(id) > 0 {
+			e.Set("receipt-id", id)
+		}
+
+		if f.Command == CommandConnect || f.Command == CommandStomp {
+			e.Set("version", Version)
+		}
+	}
+
+	if err := s.send(e.Marshal()); err != nil {
+		return err
+	}
+
+	return fmt.Errorf("stomp: %s", message)
+}
+
+// closeClient disconnects client from server side, session is released by adapter's Release
+func (sa *StompAdapter) closeClient(s *session) error {
+	s.client.Close()
+	return nil
+}
+
+func (sa *StompAdapter) tickInterval() time.Duration {
+
+	tick := sa.sendInterval
+	if tick <= 0 || (sa.recvInterval > 0 && sa.recvInterval < tick) {
+		tick = sa.recvInterval
+	}
+
+	return tick / 2
+}
+
+func (sa *StompAdapter) watch(tick time.Duration) {
+
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-sa.done:
+			return
+		case <-ticker.C:
+		}
+
+		sa.sessionMutex.RLock()
+		sessions := make([]*session, 0, len(sa.sessions))
+		for _, s := range sa.sessions {
+			sessions = append(sessions, s)
+		}
+		sa.sessionMutex.RUnlock()
+
+		now := time.Now().UnixNano()
+
+		for _, s := range sessions {
+
+			outgoing, incoming := s.heartbeat()
+
+			if outgoing > 0 && time.Duration(now-atomic.LoadInt64(&s.lastSent)) >= outgoing-tick {
+				s.send([]byte("\n"))
+			}
+
+			// Be tolerant of network latency
+			if incoming > 0 && time.Duration(now-atomic.LoadInt64(&s.lastReceived)) > 2*incoming {
+				sa.closeClient(s)
+			}
+		}
+	}
+}
+
+func (sa *StompAdapter) getSession(c websocket_server.Client) *session {
+
+	sa.sessionMutex.Lock()
+	defer sa.sessionMutex.Unlock()
+
+	s, ok := sa.sessions[c.GetClientID()]
+	if !ok {
+		s = newSession(c)
+
+		// Client was closed already
+		if c.GetContext().Err() != nil {
+			return s
+		}
+
+		sa.sessions[c.GetClientID()] = s
+	}
+
+	return s
+}
+
+func (sa *StompAdapter) Release(c websocket_server.Client) {
+	sa.sessionMutex.Lock()
+	defer sa.sessionMutex.Unlock()
+	delete(sa.sessions, c.GetClientID())
+}
+
+// Call is not supported since STOMP has no request-response semantics
+func (sa *StompAdapter) Call(ctx context.Context, c websocket_server.Client, method string, params interface{}) (interface{}, error) {
+	return nil, websocket_server.ErrAdapterNotImplemented
+}
+
+// PrepareNotification creates MESSAGE frame without subscription, SendTo should be used instead
+func (sa *StompAdapter) PrepareNotification(eventName string, payload interface{}) ([]byte, error) {
+
+	f, err := sa.createMessage(eventName, payload)
+	if err != nil {
+		return []byte(""), err
+	}
+
+	f.Set("message-id", strconv.FormatInt(atomic.AddInt64(&sa.messageSeq, 1), 10))
+
+	return f.Marshal(), nil
+}
+
+func (sa *StompAdapter) PrepareResponse(res *websocket_server.RPCResponse) ([]byte, error) {
+	return []byte(""), websocket_server.ErrAdapterNotImplemented
+}
+
+// Register is an alias of Handle for Adapter interface, options are unused
+func (sa *StompAdapter) Register(method string, fn websocket_server.RPCFunc, opts ...websocket_server.RPCMethodOpt) error {
+	sa.Handle(method, DestinationHandler(fn))
+	return nil
+}
+
+func (sa *StompAdapter) Unregister(method string) {
+	sa.Unhandle(method)
+}
+
+func (sa *StompAdapter) IsBinary() bool {
+	return false
+}
+
+func parseHeartbeat(val string) (time.Duration, time.Duration, bool) {
+
+	if len(val) == 0 {
+		return 0, 0, true
+	}
+
+	parts := strings.Split(val, ",")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+
+	x, err := strconv.ParseInt(strings.TrimSpace(parts[0]), 10, 64)
+	if err != nil || x < 0 {
+		return 0, 0, false
+	}
+
+	y, err := strconv.ParseInt(strings.TrimSpace(parts[1]), 10, 64)
+	if err != nil || y < 0 {
+		return 0, 0, false
+	}
+
+	return time.Duration(x) * time.Millisecond, time.Duration(y) * time.Millisecond, true
+}
+
+// negotiate returns the interval of heart-beats in one direction, zero means no heart-beats
+func negotiate(local time.Duration, remote time.Duration) time.Duration {
+
+	if local <= 0 || remote <= 0 {
+		return 0
+	}
+
+	if local > remote {
+		return local
+	}
+
+	return remote
+}
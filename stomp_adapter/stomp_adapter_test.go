@@ -0,0 +1,278 @@
+package stomp_adapter_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/weedbox/websocket-modules/stomp_adapter"
+	"github.com/weedbox/websocket-modules/websocket_server"
+	"github.com/weedbox/websocket-modules/websocket_servertest"
+)
+
+func receive(t *testing.T, c *websocket_servertest.Client, f *stomp_adapter.Frame) {
+	if err := c.Receive(f.Marshal()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitFrames waits for server to send n frames
+func waitFrames(t *testing.T, c *websocket_servertest.Client, n int) []*stomp_adapter.Frame {
+
+	deadline := time.Now().Add(2 * time.Second)
+
+	for {
+		frames := make([]*stomp_adapter.Frame, 0)
+		for _, data := range c.GetFrames() {
+			fs, err := stomp_adapter.ParseFrames(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			frames = append(frames, fs...)
+		}
+
+		if len(frames) >= n {
+			return frames
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d frames, got %d", n, len(frames))
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func connect(t *testing.T, sa *stomp_adapter.StompAdapter, heartbeat string) *websocket_servertest.Client {
+
+	c := websocket_servertest.NewClient(sa)
+
+	f := stomp_adapter.NewFrame(stomp_adapter.CommandConnect)
+	f.Set("accept-version", "1.2")
+	f.Set("heart-beat", heartbeat)
+	receive(t, c, f)
+
+	frames := waitFrames(t, c, 1)
+	if frames[0].Command != stomp_adapter.CommandConnected {
+		t.Fatalf("unexpected frame %s: %s", frames[0].Command, frames[0].Get("message"))
+	}
+
+	c.Reset()
+
+	return c
+}
+
+func subscribe(t *testing.T, c *websocket_servertest.Client, id string, destination string, ack string) {
+
+	f := stomp_adapter.NewFrame(stomp_adapter.CommandSubscribe)
+	f.Set("id", id)
+	f.Set("destination", destination)
+	f.Set("ack", ack)
+	f.Set("receipt", "sub-"+id)
+	receive(t, c, f)
+
+	frames := waitFrames(t, c, 1)
+	if frames[0].Command != stomp_adapter.CommandReceipt {
+		t.Fatalf("unexpected frame %s: %s", frames[0].Command, frames[0].Get("message"))
+	}
+
+	c.Reset()
+}
+
+func TestConnectVersion(t *testing.T) {
+
+	c := websocket_servertest.NewClient(stomp_adapter.New())
+
+	f := stomp_adapter.NewFrame(stomp_adapter.CommandConnect)
+	f.Set("accept-version", "1.0,1.1")
+	receive(t, c, f)
+
+	frames := waitFrames(t, c, 1)
+	if frames[0].Command != stomp_adapter.CommandError || frames[0].Get("version") != stomp_adapter.Version {
+		t.Fatalf("unexpected frame %s %v", frames[0].Command, frames[0].Headers)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if !c.IsClosed() {
+		t.Fatal("client was not closed after ERROR frame")
+	}
+}
+
+func TestSubscriptionsAreAcknowledgedSeparately(t *testing.T) {
+
+	nacked := make(chan string, 2)
+	sa := stomp_adapter.New(stomp_adapter.WithNackHandler(func(c websocket_server.Client, sub *stomp_adapter.Subscription, msg *stomp_adapter.Frame) {
+		nacked <- sub.ID
+	}))
+
+	c := connect(t, sa, "")
+	subscribe(t, c, "a", "/topic/news", stomp_adapter.AckMode_ClientIndividual)
+	subscribe(t, c, "b", "/topic/news", stomp_adapter.AckMode_ClientIndividual)
+
+	if err := sa.SendTo("/topic/news", map[string]interface{}{"title": "hello"}); err != nil {
+		t.Fatal(err)
+	}
+
+	frames := waitFrames(t, c, 2)
+	if frames[0].Get("message-id") == frames[1].Get("message-id") || frames[0].Get("ack") == frames[1].Get("ack") {
+		t.Fatalf("messages of subscriptions share id: %v %v", frames[0].Headers, frames[1].Headers)
+	}
+
+	if frames[0].Get("content-type") != "application/json" || string(frames[0].Body) != `{"title":"hello"}` {
+		t.Fatalf("unexpected message %v %s", frames[0].Headers, frames[0].Body)
+	}
+
+	acks := make(map[string]string)
+	for _, f := range frames {
+		acks[f.Get("subscription")] = f.Get("ack")
+	}
+
+	c.Reset()
+
+	ack := stomp_adapter.NewFrame(stomp_adapter.CommandAck)
+	ack.Set("id", acks["a"])
+	ack.Set("receipt", "ack-a")
+	receive(t, c, ack)
+
+	// The message of the other subscription is still pending
+	nack := stomp_adapter.NewFrame(stomp_adapter.CommandNack)
+	nack.Set("id", acks["b"])
+	nack.Set("receipt", "nack-b")
+	receive(t, c, nack)
+
+	frames = waitFrames(t, c, 2)
+	for _, f := range frames {
+		if f.Command != stomp_adapter.CommandReceipt {
+			t.Fatalf("unexpected frame %s: %s", f.Command, f.Get("message"))
+		}
+	}
+
+	if sub := <-nacked; sub != "b" {
+		t.Fatalf("unexpected nacked subscription %s", sub)
+	}
+}
+
+func TestFramesAreHandledInOrder(t *testing.T) {
+
+	var mutex sync.Mutex
+	handled := make([]string, 0)
+
+	sa := stomp_adapter.New()
+	sa.Handle("/app/echo", func(c *websocket_server.Context) (interface{}, error) {
+
+		f := stomp_adapter.GetFrame(c)
+
+		// Earlier frame takes longer
+		if string(f.Body) == "first" {
+			time.Sleep(50 * time.Millisecond)
+		}
+
+		mutex.Lock()
+		handled = append(handled, string(f.Body))
+		mutex.Unlock()
+
+		return string(f.Body), nil
+	})
+
+	c := connect(t, sa, "")
+	subscribe(t, c, "r", "/queue/reply", stomp_adapter.AckMode_Auto)
+
+	for _, body := range []string{"first", "second"} {
+		f := stomp_adapter.NewFrame(stomp_adapter.CommandSend)
+		f.Set("destination", "/app/echo")
+		f.Set("reply-to", "/queue/reply")
+		f.Body = []byte(body)
+		receive(t, c, f)
+	}
+
+	disconnect := stomp_adapter.NewFrame(stomp_adapter.CommandDisconnect)
+	disconnect.Set("receipt", "bye")
+	receive(t, c, disconnect)
+
+	frames := waitFrames(t, c, 3)
+
+	if string(frames[0].Body) != "first" || string(frames[1].Body) != "second" {
+		t.Fatalf("unexpected replies %q %q", frames[0].Body, frames[1].Body)
+	}
+
+	if frames[2].Command != stomp_adapter.CommandReceipt || frames[2].Get("receipt-id") != "bye" {
+		t.Fatalf("unexpected frame %s %v", frames[2].Command, frames[2].Headers)
+	}
+
+	mutex.Lock()
+	if len(handled) != 2 || handled[0] != "first" {
+		t.Fatalf("unexpected order %v", handled)
+	}
+	mutex.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	if !c.IsClosed() {
+		t.Fatal("client was not closed after DISCONNECT")
+	}
+}
+
+func TestHandlerErrorClosesClient(t *testing.T) {
+
+	sa := stomp_adapter.New()
+	sa.Handle("/app/fail", func(c *websocket_server.Context) (interface{}, error) {
+		return nil, errors.New("denied")
+	})
+
+	c := connect(t, sa, "")
+
+	f := stomp_adapter.NewFrame(stomp_adapter.CommandSend)
+	f.Set("destination", "/app/fail")
+	receive(t, c, f)
+
+	frames := waitFrames(t, c, 1)
+	if frames[0].Command != stomp_adapter.CommandError || frames[0].Get("message") != "denied" {
+		t.Fatalf("unexpected frame %s %v", frames[0].Command, frames[0].Headers)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if !c.IsClosed() {
+		t.Fatal("client was not closed")
+	}
+
+	// Session is released
+	if err := sa.SendToClient(c, "/app/fail", "x"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHeartbeatTimeout(t *testing.T) {
+
+	sa := stomp_adapter.New(stomp_adapter.WithHeartbeat(0, 20*time.Millisecond))
+
+	c := connect(t, sa, "20,0")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !c.IsClosed() {
+		if time.Now().After(deadline) {
+			t.Fatal("client was not closed without heart-beats")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestClose(t *testing.T) {
+
+	sa := stomp_adapter.New(stomp_adapter.WithHeartbeat(0, 20*time.Millisecond))
+	sa.Close()
+	sa.Close()
+
+	c := connect(t, sa, "20,0")
+
+	// Heart-beats are no longer checked
+	time.Sleep(200 * time.Millisecond)
+
+	if c.IsClosed() {
+		t.Fatal("client was closed after adapter was closed")
+	}
+}
@@ -3,6 +3,7 @@ package system_rpc
 import (
 	"context"
 	"errors"
+	"reflect"
 
 	"github.com/gin-gonic/gin"
 	"github.com/weedbox/common-modules/http_server"
@@ -12,11 +13,12 @@ import (
 )
 
 type SystemRPC struct {
-	params Params
-	logger *zap.Logger
-	router *gin.RouterGroup
-	scope  string
-	uri    string
+	params  Params
+	logger  *zap.Logger
+	router  *gin.RouterGroup
+	scope   string
+	uri     string
+	adapter *websocket_server.RPCAdapter
 }
 
 type Params struct {
@@ -67,11 +69,39 @@ func (srpc *SystemRPC) onStart(ctx context.Context) error {
 		return errors.New("Not found endpoint")
 	}
 
-	ep.GetAdapter().Register("System.Ping", srpc.ping)
+	srpc.register(ep.GetAdapter())
 
 	return nil
 }
 
+func (srpc *SystemRPC) register(a websocket_server.Adapter) {
+
+	a.Register("System.Ping", srpc.ping, websocket_server.WithDescription("Checks whether server is alive"))
+
+	// Introspection relies on metadata which is kept by RPC adapter only
+	ra, ok := a.(*websocket_server.RPCAdapter)
+	if !ok {
+		srpc.logger.Warn("Introspection is not supported by adapter", zap.String("uri", srpc.uri))
+		return
+	}
+
+	srpc.adapter = ra
+
+	a.Register("System.ListMethods", srpc.listMethods,
+		websocket_server.WithDescription("Lists registered methods"),
+		websocket_server.WithMethodTypes(nil, reflect.TypeOf([]*MethodInfo{})),
+	)
+
+	websocket_server.RegisterTyped(a, "System.Describe", srpc.describe,
+		websocket_server.WithDescription("Describes params and result of method"),
+	)
+
+	a.Register("System.Capabilities", srpc.capabilities,
+		websocket_server.WithDescription("Reports features which are supported by server"),
+		websocket_server.WithMethodTypes(nil, reflect.TypeOf(&websocket_server.Capabilities{})),
+	)
+}
+
 func (srpc *SystemRPC) onStop(ctx context.Context) error {
 	srpc.logger.Info("Stopped System RPC", zap.String("uri", srpc.uri))
 	return nil
@@ -85,3 +115,67 @@ type Hello struct {
 func (srpc *SystemRPC) ping(c *websocket_server.Context) (interface{}, error) {
 	return nil, nil
 }
+
+type MethodInfo struct {
+	Name         string                   `json:"name"`
+	Description  string                   `json:"description,omitempty"`
+	Deprecated   bool                     `json:"deprecated,omitempty"`
+	Notification bool                     `json:"notification,omitempty"`
+	Streaming    bool                     `json:"streaming,omitempty"`
+	Timeout      int64                    `json:"timeout,omitempty"`
+	Params       *websocket_server.Schema `json:"params,omitempty"`
+	Result       *websocket_server.Schema `json:"result,omitempty"`
+}
+
+type DescribeParams struct {
+	Method string `json:"method" validate:"required" description:"Name of method"`
+}
+
+func newMethodInfo(m *websocket_server.RPCMethod, notification bool) *MethodInfo {
+	return &MethodInfo{
+		Name:         m.Name,
+		Description:  m.Description,
+		Deprecated:   m.Deprecated,
+		Notification: notification,
+		Streaming:    m.Streaming,
+		Timeout:      m.Timeout.Milliseconds(),
+	}
+}
+
+func (srpc *SystemRPC) listMethods(c *websocket_server.Context) (interface{}, error) {
+
+	methods := make([]*MethodInfo, 0)
+	for _, m := range srpc.adapter.GetMethods() {
+		methods = append(methods, newMethodInfo(m, false))
+	}
+
+	for _, m := range srpc.adapter.GetNotificationMethods() {
+		methods = append(methods, newMethodInfo(m, true))
+	}
+
+	return methods, nil
+}
+
+func (srpc *SystemRPC) describe(c *websocket_server.Context, params *DescribeParams) (*MethodInfo, error) {
+
+	if m, ok := srpc.adapter.GetMethod(params.Method); ok {
+		info := newMethodInfo(m, false)
+		info.Params = m.GetParamsSchema()
+		info.Result = m.GetResultSchema()
+		return info, nil
+	}
+
+	for _, m := range srpc.adapter.GetNotificationMethods() {
+		if m.Name == params.Method {
+			info := newMethodInfo(m, true)
+			info.Params = m.GetParamsSchema()
+			return info, nil
+		}
+	}
+
+	return nil, c.Error(websocket_server.ErrorCode_NotFound, params.Method)
+}
+
+func (srpc *SystemRPC) capabilities(c *websocket_server.Context) (interface{}, error) {
+	return srpc.adapter.GetCapabilities(), nil
+}
@@ -0,0 +1,140 @@
+package system_rpc
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/weedbox/websocket-modules/jsonrpc"
+	"github.com/weedbox/websocket-modules/websocket_server"
+	"github.com/weedbox/websocket-modules/websocket_servertest"
+	"go.uber.org/zap"
+)
+
+type point struct {
+	X int `json:"x" validate:"required"`
+	Y int `json:"y"`
+}
+
+func newAdapter() *websocket_server.RPCAdapter {
+
+	ra := websocket_server.NewRPCAdapter(
+		websocket_server.WithRPCBackend(&jsonrpc.JSONRPC{}),
+		websocket_server.WithRPCTimeout(time.Second),
+	)
+
+	websocket_server.RegisterTyped(ra, "Move", func(c *websocket_server.Context, p *point) (*point, error) {
+		return p, nil
+	}, websocket_server.WithDescription("Moves point"), websocket_server.WithMethodTimeout(time.Minute))
+
+	ra.RegisterNotification("Log", func(c *websocket_server.Context) error {
+		return nil
+	}, websocket_server.WithDeprecated())
+
+	srpc := &SystemRPC{
+		logger: zap.NewNop(),
+	}
+
+	srpc.register(ra)
+
+	return ra
+}
+
+func TestPing(t *testing.T) {
+
+	res, err := websocket_servertest.Invoke(context.Background(), newAdapter(), "System.Ping", nil)
+	if err != nil || res != nil {
+		t.Fatalf("unexpected result %v %v", res, err)
+	}
+}
+
+func TestListMethods(t *testing.T) {
+
+	var methods []*MethodInfo
+	if err := websocket_servertest.InvokeInto(context.Background(), newAdapter(), "System.ListMethods", nil, &methods); err != nil {
+		t.Fatal(err)
+	}
+
+	names := make([]string, 0, len(methods))
+	for _, m := range methods {
+		names = append(names, m.Name)
+	}
+
+	expected := []string{"Move", "System.Capabilities", "System.Describe", "System.ListMethods", "System.Ping", "rpc.discover", "Log"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("unexpected methods %v", names)
+	}
+
+	if m := methods[0]; m.Description != "Moves point" || m.Timeout != time.Minute.Milliseconds() || m.Params != nil {
+		t.Fatalf("unexpected method %+v", m)
+	}
+
+	if m := methods[6]; !m.Notification || !m.Deprecated {
+		t.Fatalf("unexpected notification %+v", m)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+
+	ra := newAdapter()
+
+	var info MethodInfo
+	if err := websocket_servertest.InvokeInto(context.Background(), ra, "System.Describe", []interface{}{"Move"}, &info); err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Params == nil || info.Params.Type != "object" || !reflect.DeepEqual(info.Params.Required, []string{"x"}) {
+		t.Fatalf("unexpected params %+v", info.Params)
+	}
+
+	if info.Result == nil || info.Result.Properties["y"].Type != "integer" {
+		t.Fatalf("unexpected result %+v", info.Result)
+	}
+
+	info = MethodInfo{}
+	if err := websocket_servertest.InvokeInto(context.Background(), ra, "System.Describe", map[string]interface{}{"method": "Log"}, &info); err != nil {
+		t.Fatal(err)
+	}
+
+	if !info.Notification || info.Result != nil {
+		t.Fatalf("unexpected notification %+v", info)
+	}
+
+	_, err := websocket_servertest.Invoke(context.Background(), ra, "System.Describe", []interface{}{"Missing"})
+
+	var rpcErr *websocket_server.RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != websocket_server.ErrorCode_NotFound {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+
+	var caps websocket_server.Capabilities
+	if err := websocket_servertest.InvokeInto(context.Background(), newAdapter(), "System.Capabilities", nil, &caps); err != nil {
+		t.Fatal(err)
+	}
+
+	if caps.Binary || !caps.Batch || !caps.Streaming || caps.DefaultTimeout != time.Second.Milliseconds() {
+		t.Fatalf("unexpected capabilities %+v", caps)
+	}
+}
+
+func TestRegisterWithoutRPCAdapter(t *testing.T) {
+
+	options := websocket_server.NewOptions()
+	a := options.Adapter
+
+	srpc := &SystemRPC{
+		logger: zap.NewNop(),
+	}
+
+	// Only ping is available without introspection
+	srpc.register(a)
+
+	if srpc.adapter != nil {
+		t.Fatal("introspection was enabled")
+	}
+}
@@ -0,0 +1,478 @@
+package websocket_client
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/weedbox/websocket-modules/jsonrpc"
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+var (
+	ErrClientClosed = errors.New("client: closed")
+)
+
+type ClientOpt func(*Client)
+
+// NotificationHandler receives notifications which are sent by server
+type NotificationHandler func(params jsoniter.RawMessage)
+
+// RequestHandler serves calls which are initiated by server
+type RequestHandler func(ctx context.Context, params jsoniter.RawMessage) (interface{}, error)
+
+// ChunkHandler receives partial results of streaming methods
+type ChunkHandler func(data jsoniter.RawMessage, seq int64) error
+
+type message struct {
+	JSONRPC string                    `json:"jsonrpc"`
+	ID      websocket_server.ID       `json:"id"`
+	Method  string                    `json:"method"`
+	Params  jsoniter.RawMessage       `json:"params"`
+	Result  jsoniter.RawMessage       `json:"result"`
+	Error   *jsonrpc.JSONRPCErrorInfo `json:"error"`
+}
+
+type pendingCall struct {
+	ch      chan *message
+	onChunk ChunkHandler
+}
+
+// Client speaks JSON-RPC 2.0 with endpoints which use the default backend
+type Client struct {
+	seq int64
+
+	conn          net.Conn
+	header        http.Header
+	protocols     []string
+	writeMutex    sync.Mutex
+	mutex         sync.RWMutex
+	pending       map[int64]*pendingCall
+	notifications map[string]NotificationHandler
+	anyHandler    func(method string, params jsoniter.RawMessage)
+	requests      map[string]RequestHandler
+	ctx           context.Context
+	cancel        context.CancelFunc
+	err           error
+}
+
+func WithHeader(header http.Header) ClientOpt {
+	return func(c *Client) {
+		c.header = header
+	}
+}
+
+func WithProtocols(protocols ...string) ClientOpt {
+	return func(c *Client) {
+		c.protocols = protocols
+	}
+}
+
+// Dial connects to endpoint like "ws://localhost:8080/ws"
+func Dial(ctx context.Context, url string, opts ...ClientOpt) (*Client, error) {
+
+	c := newClient(opts...)
+
+	dialer := ws.Dialer{
+		Protocols: c.protocols,
+	}
+
+	if c.header != nil {
+		dialer.Header = ws.HandshakeHeaderHTTP(c.header)
+	}
+
+	conn, br, _, err := dialer.Dial(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	// Server may send frames right after handshake which were buffered already
+	if br != nil {
+		conn = &bufferedConn{Conn: conn, r: br}
+	}
+
+	c.start(conn)
+
+	return c, nil
+}
+
+// New creates client over connection which has completed handshake already
+func New(conn net.Conn, opts ...ClientOpt) *Client {
+	c := newClient(opts...)
+	c.start(conn)
+	return c
+}
+
+func newClient(opts ...ClientOpt) *Client {
+
+	c := &Client{
+		pending:       make(map[int64]*pendingCall),
+		notifications: make(map[string]NotificationHandler),
+		requests:      make(map[string]RequestHandler),
+	}
+
+	for _, o := range opts {
+		o(c)
+	}
+
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+
+	return c
+}
+
+func (c *Client) start(conn net.Conn) {
+	c.conn = conn
+	go c.readLoop()
+}
+
+// Done is closed once connection is closed
+func (c *Client) Done() <-chan struct{} {
+	return c.ctx.Done()
+}
+
+// Err returns the reason why connection was closed
+func (c *Client) Err() error {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.err
+}
+
+func (c *Client) Close() error {
+
+	c.shutdown(ErrClientClosed)
+
+	c.writeMutex.Lock()
+	wsutil.WriteClientMessage(c.conn, ws.OpClose, ws.NewCloseFrameBody(ws.StatusNormalClosure, ""))
+	c.writeMutex.Unlock()
+
+	return c.conn.Close()
+}
+
+// On registers handler of notification, handlers are called by the reader so they should not block
+func (c *Client) On(method string, fn NotificationHandler) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.notifications[method] = fn
+}
+
+// OnAny registers handler which receives all notifications, method handlers are still called
+func (c *Client) OnAny(fn func(method string, params jsoniter.RawMessage)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.anyHandler = fn
+}
+
+func (c *Client) Off(method string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.notifications, method)
+}
+
+// Handle serves calls of the method which are initiated by server
+func (c *Client) Handle(method string, fn RequestHandler) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.requests[method] = fn
+}
+
+// Call invokes method and decodes result into result which should be a pointer or nil. Request is
+// cancelled on server if context is done before response.
+func (c *Client) Call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	return c.CallStream(ctx, method, params, result, nil)
+}
+
+// CallStream is like Call, partial results of streaming methods are passed to onChunk
+func (c *Client) CallStream(ctx context.Context, method string, params interface{}, result interface{}, onChunk ChunkHandler) error {
+
+	id := atomic.AddInt64(&c.seq, 1)
+	p := &pendingCall{
+		ch:      make(chan *message, 1),
+		onChunk: onChunk,
+	}
+
+	c.mutex.Lock()
+	if c.err != nil {
+		c.mutex.Unlock()
+		return c.err
+	}
+
+	c.pending[id] = p
+	c.mutex.Unlock()
+
+	defer func() {
+		c.mutex.Lock()
+		delete(c.pending, id)
+		c.mutex.Unlock()
+	}()
+
+	err := c.send(&jsonrpc.JSONRPCOutgoingRequest{
+		JSONRPC: "2.0",
+		ID:      websocket_server.NewIntID(id),
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		c.Notify(websocket_server.CancelRequestMethod, map[string]interface{}{"id": id})
+		return ctx.Err()
+	case <-c.ctx.Done():
+		return c.Err()
+	case res := <-p.ch:
+		if res.Error != nil {
+			return &websocket_server.RPCError{
+				Code:    jsonrpc.GetRPCErrorCode(res.Error.Code),
+				Message: res.Error.Message,
+				Data:    res.Error.Data,
+			}
+		}
+
+		if result == nil || len(res.Result) == 0 {
+			return nil
+		}
+
+		return json.Unmarshal(res.Result, result)
+	}
+}
+
+func (c *Client) Notify(method string, params interface{}) error {
+	return c.send(&jsonrpc.NotificationEntry{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+	})
+}
+
+func (c *Client) send(v interface{}) error {
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return c.write(ws.OpText, data)
+}
+
+func (c *Client) write(op ws.OpCode, data []byte) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	return wsutil.WriteClientMessage(c.conn, op, data)
+}
+
+func (c *Client) shutdown(err error) {
+
+	c.mutex.Lock()
+	if c.err == nil {
+		c.err = err
+	}
+	c.mutex.Unlock()
+
+	c.cancel()
+}
+
+func (c *Client) readLoop() {
+
+	rd := &wsutil.Reader{
+		Source:    c.conn,
+		State:     ws.StateClientSide,
+		CheckUTF8: true,
+	}
+
+	rd.OnIntermediate = func(h ws.Header, r io.Reader) error {
+		return c.handleControl(h, r)
+	}
+
+	for {
+		h, err := rd.NextFrame()
+		if err != nil {
+			c.conn.Close()
+			c.shutdown(err)
+			return
+		}
+
+		if h.OpCode.IsControl() {
+			if err := c.handleControl(h, rd); err != nil {
+				c.conn.Close()
+				c.shutdown(err)
+				return
+			}
+
+			continue
+		}
+
+		data, err := io.ReadAll(rd)
+		if err != nil {
+			c.conn.Close()
+			c.shutdown(err)
+			return
+		}
+
+		c.dispatch(data)
+	}
+}
+
+func (c *Client) handleControl(h ws.Header, r io.Reader) error {
+
+	payload, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	switch h.OpCode {
+	case ws.OpPing:
+		return c.write(ws.OpPong, payload)
+	case ws.OpClose:
+		c.write(ws.OpClose, payload)
+		return io.EOF
+	}
+
+	return nil
+}
+
+func (c *Client) dispatch(data []byte) {
+
+	var msg message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return
+	}
+
+	switch {
+	case len(msg.Method) > 0 && msg.ID.IsAbsent():
+		c.handleNotification(&msg)
+	case len(msg.Method) > 0:
+		go c.handleRequest(&msg)
+	default:
+		id, ok := msg.ID.Int64()
+		if !ok {
+			return
+		}
+
+		// Only the first response is taken, duplicates must not block the read loop
+		c.mutex.Lock()
+		p, ok := c.pending[id]
+		delete(c.pending, id)
+		c.mutex.Unlock()
+
+		if !ok {
+			return
+		}
+
+		select {
+		case p.ch <- &msg:
+		default:
+		}
+	}
+}
+
+func (c *Client) handleNotification(msg *message) {
+
+	// Partial results are acknowledged for flow control of server
+	if msg.Method == websocket_server.StreamChunkEvent {
+		c.handleChunk(msg)
+		return
+	}
+
+	c.mutex.RLock()
+	fn, ok := c.notifications[msg.Method]
+	anyHandler := c.anyHandler
+	c.mutex.RUnlock()
+
+	if anyHandler != nil {
+		anyHandler(msg.Method, msg.Params)
+	}
+
+	if ok {
+		fn(msg.Params)
+	}
+}
+
+func (c *Client) handleChunk(msg *message) {
+
+	var chunk struct {
+		ID   websocket_server.ID `json:"id"`
+		Seq  int64               `json:"seq"`
+		Data jsoniter.RawMessage `json:"data"`
+	}
+
+	if err := json.Unmarshal(msg.Params, &chunk); err != nil {
+		return
+	}
+
+	id, ok := chunk.ID.Int64()
+	if !ok {
+		return
+	}
+
+	c.mutex.RLock()
+	p, ok := c.pending[id]
+	c.mutex.RUnlock()
+
+	if !ok || p.onChunk == nil {
+		return
+	}
+
+	if err := p.onChunk(chunk.Data, chunk.Seq); err != nil {
+		c.Notify(websocket_server.CancelRequestMethod, map[string]interface{}{"id": id})
+		return
+	}
+
+	c.Notify(websocket_server.StreamAckMethod, map[string]interface{}{"id": id, "seq": chunk.Seq})
+}
+
+func (c *Client) handleRequest(msg *message) {
+
+	c.mutex.RLock()
+	fn, ok := c.requests[msg.Method]
+	c.mutex.RUnlock()
+
+	if !ok {
+		c.respondError(msg.ID, websocket_server.NewError(websocket_server.ErrorCode_NotFound, nil))
+		return
+	}
+
+	result, err := fn(c.ctx, msg.Params)
+	if err != nil {
+		c.respondError(msg.ID, websocket_server.ToRPCError(err))
+		return
+	}
+
+	c.send(&jsonrpc.JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      msg.ID,
+		Result:  result,
+	})
+}
+
+func (c *Client) respondError(id websocket_server.ID, e *websocket_server.RPCError) {
+	c.send(&jsonrpc.JSONRPCError{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error: jsonrpc.JSONRPCErrorInfo{
+			Code:    jsonrpc.GetErrorCode(e.Code),
+			Message: e.Message,
+			Data:    e.Data,
+		},
+	})
+}
+
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (bc *bufferedConn) Read(p []byte) (int, error) {
+	return bc.r.Read(p)
+}
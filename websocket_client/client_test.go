@@ -0,0 +1,194 @@
+package websocket_client_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/weedbox/websocket-modules/jsonrpc"
+	"github.com/weedbox/websocket-modules/websocket_client"
+	"github.com/weedbox/websocket-modules/websocket_server"
+	"github.com/weedbox/websocket-modules/websocket_servertest"
+)
+
+func newServer(t *testing.T) *websocket_servertest.Server {
+
+	s := websocket_servertest.NewRPCServer(websocket_server.WithRPCBackend(&jsonrpc.JSONRPC{}))
+	t.Cleanup(s.Close)
+
+	ra := s.GetRPCAdapter()
+	ra.Register("Echo", func(c *websocket_server.Context) (interface{}, error) {
+		c.Notify("echoed", c.GetRequest().Params)
+		return c.GetRequest().Params, nil
+	})
+	ra.Register("Fail", func(c *websocket_server.Context) (interface{}, error) {
+		return nil, websocket_server.NewError(websocket_server.ErrorCode_InvalidParams, "bad")
+	})
+	ra.Register("Slow", func(c *websocket_server.Context) (interface{}, error) {
+		<-c.Done()
+		return nil, c.Err()
+	})
+	ra.Register("Ask", func(c *websocket_server.Context) (interface{}, error) {
+		return c.GetClient().Call(c, "Client.Name", nil)
+	})
+	ra.RegisterStream("Count", func(c *websocket_server.Context) (interface{}, error) {
+		for i := 0; i < 3; i++ {
+			if err := c.GetStream().Send(i); err != nil {
+				return nil, err
+			}
+		}
+
+		return "done", nil
+	}, websocket_server.WithStreamWindow(1))
+
+	return s
+}
+
+func dial(t *testing.T, s *websocket_servertest.Server) *websocket_client.Client {
+
+	c, err := s.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		c.Close()
+	})
+
+	return c
+}
+
+func TestCall(t *testing.T) {
+
+	c := dial(t, newServer(t))
+
+	echoed := make(chan string, 1)
+	c.On("echoed", func(params jsoniter.RawMessage) {
+		echoed <- string(params)
+	})
+
+	var result []string
+	if err := c.Call(context.Background(), "Echo", []string{"a", "b"}, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result) != 2 || result[1] != "b" {
+		t.Fatalf("unexpected result %v", result)
+	}
+
+	select {
+	case params := <-echoed:
+		if params != `["a","b"]` {
+			t.Fatalf("unexpected notification %s", params)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("notification was not received")
+	}
+
+	err := c.Call(context.Background(), "Fail", nil, nil)
+
+	var rpcErr *websocket_server.RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != websocket_server.ErrorCode_InvalidParams || rpcErr.Data != "bad" {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	err = c.Call(context.Background(), "Nope", nil, nil)
+	if !errors.As(err, &rpcErr) || rpcErr.Code != websocket_server.ErrorCode_NotFound {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestCancelCall(t *testing.T) {
+
+	c := dial(t, newServer(t))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := c.Call(ctx, "Slow", nil, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// Connection is still usable
+	var result []int
+	if err := c.Call(context.Background(), "Echo", []int{1}, &result); err != nil || len(result) != 1 {
+		t.Fatal(result, err)
+	}
+}
+
+func TestHandleServerCall(t *testing.T) {
+
+	c := dial(t, newServer(t))
+	c.Handle("Client.Name", func(ctx context.Context, params jsoniter.RawMessage) (interface{}, error) {
+		return "alice", nil
+	})
+
+	var name string
+	if err := c.Call(context.Background(), "Ask", nil, &name); err != nil || name != "alice" {
+		t.Fatal(name, err)
+	}
+}
+
+func TestCallStream(t *testing.T) {
+
+	c := dial(t, newServer(t))
+
+	chunks := make([]int64, 0)
+	var result string
+	err := c.CallStream(context.Background(), "Count", nil, &result, func(data jsoniter.RawMessage, seq int64) error {
+		chunks = append(chunks, seq)
+		return nil
+	})
+
+	if err != nil || result != "done" || len(chunks) != 3 {
+		t.Fatal(result, chunks, err)
+	}
+}
+
+func TestDuplicateResponse(t *testing.T) {
+
+	conn, peer := net.Pipe()
+
+	c := websocket_client.New(conn)
+	defer c.Close()
+
+	// Peer is closed first since pipe blocks close frame of client
+	defer peer.Close()
+
+	notified := make(chan struct{})
+	c.On("after", func(params jsoniter.RawMessage) {
+		close(notified)
+	})
+
+	// Server answers twice before client takes the response
+	go func() {
+		data, err := wsutil.ReadClientText(peer)
+		if err != nil {
+			return
+		}
+
+		var req jsonrpc.JSONRPCOutgoingRequest
+		jsoniter.Unmarshal(data, &req)
+
+		res, _ := jsoniter.Marshal(&jsonrpc.JSONRPCResponse{JSONRPC: "2.0", ID: req.ID, Result: 1})
+		wsutil.WriteServerMessage(peer, ws.OpText, res)
+		wsutil.WriteServerMessage(peer, ws.OpText, res)
+		wsutil.WriteServerMessage(peer, ws.OpText, []byte(`{"jsonrpc":"2.0","method":"after"}`))
+	}()
+
+	var result int
+	if err := c.Call(context.Background(), "Foo", nil, &result); err != nil || result != 1 {
+		t.Fatal(result, err)
+	}
+
+	select {
+	case <-notified:
+	case <-time.After(2 * time.Second):
+		t.Fatal("read loop is blocked")
+	}
+}
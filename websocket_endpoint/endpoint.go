@@ -18,6 +18,10 @@ type Endpoint struct {
 	scope    string
 	uri      string
 	endpoint *websocket_server.Endpoint
+	backend  websocket_server.Backend
+	adapter  websocket_server.Adapter
+
+	subprotocols []string
 }
 
 type Params struct {
@@ -29,7 +33,28 @@ type Params struct {
 	WebSocketServer *websocket_server.WebSocketServer
 }
 
-func Module(scope string, uri string) fx.Option {
+type Option func(*Endpoint)
+
+func WithBackend(b websocket_server.Backend) Option {
+	return func(ep *Endpoint) {
+		ep.backend = b
+	}
+}
+
+// WithAdapter replaces the RPC adapter, backend is ignored if adapter is specified
+func WithAdapter(a websocket_server.Adapter) Option {
+	return func(ep *Endpoint) {
+		ep.adapter = a
+	}
+}
+
+func WithSubprotocols(protocols ...string) Option {
+	return func(ep *Endpoint) {
+		ep.subprotocols = protocols
+	}
+}
+
+func Module(scope string, uri string, opts ...Option) fx.Option {
 
 	var ep *Endpoint
 
@@ -38,10 +63,15 @@ func Module(scope string, uri string) fx.Option {
 		fx.Provide(func(p Params) *Endpoint {
 
 			ep := &Endpoint{
-				params: p,
-				logger: p.Logger.Named(scope),
-				scope:  scope,
-				uri:    uri,
+				params:  p,
+				logger:  p.Logger.Named(scope),
+				scope:   scope,
+				uri:     uri,
+				backend: &jsonrpc.JSONRPC{},
+			}
+
+			for _, o := range opts {
+				o(ep)
 			}
 
 			return ep
@@ -64,9 +94,13 @@ func (ep *Endpoint) onStart(ctx context.Context) error {
 	ep.logger.Info("Starting Websocket Endpoint", zap.String("uri", ep.uri))
 
 	opts := websocket_server.NewOptions()
-	opts.Adapter = websocket_server.NewRPCAdapter(
-		websocket_server.WithRPCBackend(&jsonrpc.JSONRPC{}),
-	)
+	opts.Subprotocols = ep.subprotocols
+	opts.Adapter = ep.adapter
+	if opts.Adapter == nil {
+		opts.Adapter = websocket_server.NewRPCAdapter(
+			websocket_server.WithRPCBackend(ep.backend),
+		)
+	}
 
 	// Create endpoint
 	e, err := ep.params.WebSocketServer.CreateEndpoint(ep.uri, opts)
@@ -1,6 +1,7 @@
 package websocket_server
 
 import (
+	"context"
 	"errors"
 )
 
@@ -10,10 +11,18 @@ var (
 
 type Adapter interface {
 	HandleMessage(Client) error
+	Call(ctx context.Context, c Client, method string, params interface{}) (interface{}, error)
 	PrepareNotification(eventName string, payload interface{}) ([]byte, error)
 	PrepareResponse(*RPCResponse) ([]byte, error)
-	Register(method string, fn RPCFunc) error
+	Register(method string, fn RPCFunc, opts ...RPCMethodOpt) error
 	Unregister(method string)
+	Release(Client)
+	IsBinary() bool
+}
+
+// Attacher is implemented by adapters which need to know clients before their first message
+type Attacher interface {
+	Attach(Client)
 }
 
 type adapter struct {
@@ -27,6 +36,10 @@ func (a *adapter) HandleMessage(c Client) error {
 	return c.GetOptions().OnMessage(c)
 }
 
+func (a *adapter) Call(ctx context.Context, c Client, method string, params interface{}) (interface{}, error) {
+	return nil, ErrAdapterNotImplemented
+}
+
 func (a *adapter) PrepareResponse(res *RPCResponse) ([]byte, error) {
 	return []byte(""), ErrAdapterNotImplemented
 }
@@ -35,9 +48,16 @@ func (a *adapter) PrepareNotification(eventName string, payload interface{}) ([]
 	return []byte(""), ErrAdapterNotImplemented
 }
 
-func (a *adapter) Register(method string, fn RPCFunc) error {
+func (a *adapter) Register(method string, fn RPCFunc, opts ...RPCMethodOpt) error {
 	return ErrAdapterNotImplemented
 }
 
 func (a *adapter) Unregister(method string) {
 }
+
+func (a *adapter) Release(c Client) {
+}
+
+func (a *adapter) IsBinary() bool {
+	return false
+}
@@ -11,8 +11,18 @@ var (
 
 type Backend interface {
 	ParseRequest(r io.Reader) (*RPCRequest, error)
+	PrepareRequest(*RPCRequest) ([]byte, error)
 	PrepareNotification(eventName string, payload interface{}) ([]byte, error)
 	PrepareResponse(*RPCResponse) ([]byte, error)
+	PrepareBatchResponse([]*RPCResponse) ([]byte, error)
+
+	// IsBinary reports whether messages should be sent as binary frames
+	IsBinary() bool
+}
+
+// ErrorCodeConverter is implemented by backends whose error codes on the wire differ from RPCErrorCode
+type ErrorCodeConverter interface {
+	ConvertErrorCode(code RPCErrorCode) int64
 }
 
 type backend struct {
@@ -26,6 +36,10 @@ func (b *backend) ParseRequest(r io.Reader) (*RPCRequest, error) {
 	return nil, ErrBackendNotImplemented
 }
 
+func (b *backend) PrepareRequest(*RPCRequest) ([]byte, error) {
+	return []byte(""), ErrBackendNotImplemented
+}
+
 func (b *backend) PrepareNotification(eventName string, payload interface{}) ([]byte, error) {
 	return []byte(""), ErrBackendNotImplemented
 }
@@ -33,3 +47,11 @@ func (b *backend) PrepareNotification(eventName string, payload interface{}) ([]
 func (b *backend) PrepareResponse(*RPCResponse) ([]byte, error) {
 	return []byte(""), ErrBackendNotImplemented
 }
+
+func (b *backend) PrepareBatchResponse([]*RPCResponse) ([]byte, error) {
+	return []byte(""), ErrBackendNotImplemented
+}
+
+func (b *backend) IsBinary() bool {
+	return false
+}
@@ -0,0 +1,195 @@
+package websocket_server
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	jsoniter "github.com/json-iterator/go"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+
+	v := validator.New()
+
+	// Report field names as they are seen by clients
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		return fieldName(f)
+	})
+
+	return v
+}
+
+func fieldName(f reflect.StructField) string {
+
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+
+	if len(name) == 0 {
+		return f.Name
+	}
+
+	return name
+}
+
+// positionalFields returns names of fields in declaration order, which are used to bind positional params.
+// Fields of embedded structs are promoted in place as they are by JSON.
+func positionalFields(t reflect.Type) []string {
+
+	fields := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+
+		f := t.Field(i)
+
+		if f.Anonymous && len(f.Tag.Get("json")) == 0 && indirectType(f.Type).Kind() == reflect.Struct {
+			fields = append(fields, positionalFields(indirectType(f.Type))...)
+			continue
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+
+		name := fieldName(f)
+		if len(name) == 0 {
+			continue
+		}
+
+		fields = append(fields, name)
+	}
+
+	return fields
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t
+}
+
+// BindParams decodes request params into out which must be a pointer, then validates it
+func BindParams(req *RPCRequest, out interface{}) error {
+
+	params := req.Params
+	if req.HasNamedParams() {
+		params = req.NamedParams
+	}
+
+	if err := decodeParams(params, out); err != nil {
+		return err
+	}
+
+	return validateParams(out)
+}
+
+func decodeParams(params interface{}, out interface{}) error {
+
+	t := indirectType(reflect.TypeOf(out))
+
+	var source interface{}
+
+	switch p := params.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		source = p
+	case []interface{}:
+
+		// Bind by field order
+		if t.Kind() == reflect.Struct {
+
+			fields := positionalFields(t)
+			if len(p) > len(fields) {
+				return NewError(ErrorCode_InvalidParams_Invalid_Arguments, "too many arguments")
+			}
+
+			named := make(map[string]interface{}, len(p))
+			for i, v := range p {
+				named[fields[i]] = v
+			}
+
+			source = named
+			break
+		}
+
+		switch {
+		case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+			source = p
+		case len(p) == 0:
+			return nil
+		case len(p) == 1:
+			source = p[0]
+		default:
+			return NewError(ErrorCode_InvalidParams_Invalid_Arguments, "too many arguments")
+		}
+	default:
+		source = p
+	}
+
+	data, err := json.Marshal(source)
+	if err != nil {
+		return NewError(ErrorCode_InvalidParams_Invalid_Arguments, err.Error())
+	}
+
+	if err := json.Unmarshal(data, out); err != nil {
+		return NewError(ErrorCode_InvalidParams_Invalid_Arguments, err.Error())
+	}
+
+	return nil
+}
+
+func validateParams(out interface{}) error {
+
+	v := reflect.ValueOf(out)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	err := validate.Struct(v.Interface())
+	if err == nil {
+		return nil
+	}
+
+	verrs, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return NewError(ErrorCode_InvalidParams, err.Error())
+	}
+
+	return newValidationError(verrs)
+}
+
+func newValidationError(verrs validator.ValidationErrors) *RPCError {
+
+	missing := make([]string, 0)
+	invalid := make([]string, 0)
+	for _, fe := range verrs {
+		if fe.Tag() == "required" {
+			missing = append(missing, fe.Field())
+			continue
+		}
+
+		invalid = append(invalid, fe.Field())
+	}
+
+	if len(missing) > 0 {
+		return NewError(ErrorCode_InvalidParams_Insufficient_Arguments, missing)
+	}
+
+	return NewError(ErrorCode_InvalidParams_Invalid_Arguments, invalid)
+}
@@ -0,0 +1,177 @@
+package websocket_server_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+type transferParams struct {
+	From   string `json:"from" validate:"required"`
+	To     string `json:"to" validate:"required"`
+	Amount int    `json:"amount" validate:"gt=0"`
+	Memo   string `json:"memo,omitempty"`
+
+	// Unexported fields take no positional params
+	secret string
+}
+
+type Base struct {
+	ID string `json:"id" validate:"required"`
+}
+
+type labelled struct {
+	Base
+	Label string `json:"label"`
+}
+
+func TestBindParams(t *testing.T) {
+
+	cases := []struct {
+		req      *websocket_server.RPCRequest
+		expected transferParams
+	}{
+		{
+			&websocket_server.RPCRequest{Params: []interface{}{"a", "b", float64(3)}},
+			transferParams{From: "a", To: "b", Amount: 3},
+		},
+		{
+			&websocket_server.RPCRequest{NamedParams: map[string]interface{}{"to": "b", "from": "a", "amount": float64(3), "memo": "x"}},
+			transferParams{From: "a", To: "b", Amount: 3, Memo: "x"},
+		},
+	}
+
+	for _, c := range cases {
+
+		var p transferParams
+		if err := websocket_server.BindParams(c.req, &p); err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(p, c.expected) {
+			t.Fatalf("unexpected params %+v", p)
+		}
+	}
+}
+
+func TestBindParamsPromoted(t *testing.T) {
+
+	// Fields of embedded struct take positions in place
+	var l labelled
+	if err := websocket_server.BindParams(&websocket_server.RPCRequest{Params: []interface{}{"n1", "hi"}}, &l); err != nil {
+		t.Fatal(err)
+	}
+
+	if l.ID != "n1" || l.Label != "hi" {
+		t.Fatalf("unexpected params %+v", l)
+	}
+
+	var p struct {
+		*Base
+		Label string `json:"label"`
+	}
+
+	if err := websocket_server.BindParams(&websocket_server.RPCRequest{Params: []interface{}{"n2", "hey"}}, &p); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Base == nil || p.ID != "n2" || p.Label != "hey" {
+		t.Fatalf("unexpected params %+v", p)
+	}
+}
+
+func TestBindParamsErrors(t *testing.T) {
+
+	cases := []struct {
+		req  *websocket_server.RPCRequest
+		code websocket_server.RPCErrorCode
+		data interface{}
+	}{
+		{
+			&websocket_server.RPCRequest{Params: []interface{}{"a"}},
+			websocket_server.ErrorCode_InvalidParams_Insufficient_Arguments,
+			[]string{"to"},
+		},
+		{
+			&websocket_server.RPCRequest{Params: []interface{}{"a", "b", float64(0)}},
+			websocket_server.ErrorCode_InvalidParams_Invalid_Arguments,
+			[]string{"amount"},
+		},
+		{
+			&websocket_server.RPCRequest{Params: []interface{}{"a", "b", float64(1), "m", "extra"}},
+			websocket_server.ErrorCode_InvalidParams_Invalid_Arguments,
+			"too many arguments",
+		},
+		{
+			&websocket_server.RPCRequest{NamedParams: map[string]interface{}{"from": "a", "to": "b", "amount": "many"}},
+			websocket_server.ErrorCode_InvalidParams_Invalid_Arguments,
+			nil,
+		},
+	}
+
+	for _, c := range cases {
+
+		var p transferParams
+		err := websocket_server.BindParams(c.req, &p)
+
+		var rpcErr *websocket_server.RPCError
+		if !errors.As(err, &rpcErr) || rpcErr.Code != c.code {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		if c.data != nil && !reflect.DeepEqual(rpcErr.Data, c.data) {
+			t.Fatalf("unexpected data %v", rpcErr.Data)
+		}
+	}
+}
+
+func TestBindParamsScalar(t *testing.T) {
+
+	var n int
+	if err := websocket_server.BindParams(&websocket_server.RPCRequest{Params: []interface{}{float64(7)}}, &n); err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 7 {
+		t.Fatalf("unexpected value %d", n)
+	}
+
+	var list []string
+	if err := websocket_server.BindParams(&websocket_server.RPCRequest{Params: []interface{}{"a", "b"}}, &list); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(list, []string{"a", "b"}) {
+		t.Fatalf("unexpected value %v", list)
+	}
+}
+
+func TestRegisterTyped(t *testing.T) {
+
+	ra := newJSONRPCAdapter()
+	websocket_server.RegisterTyped(ra, "Bank.Transfer", func(c *websocket_server.Context, p *transferParams) (map[string]interface{}, error) {
+		return map[string]interface{}{"from": p.From, "to": p.To, "amount": p.Amount}, nil
+	})
+	websocket_server.RegisterTyped(ra, "Math.Double", func(c *websocket_server.Context, n int) (int, error) {
+		return n * 2, nil
+	})
+
+	p := connect(t, ra)
+
+	p.send(`{"jsonrpc":"2.0","id":1,"method":"Bank.Transfer","params":["a","b",5]}`)
+	p.expect(`{"jsonrpc":"2.0","id":1,"result":{"from":"a","to":"b","amount":5}}`)
+
+	p.send(`{"jsonrpc":"2.0","id":2,"method":"Bank.Transfer","params":{"from":"a","amount":5}}`)
+	p.expect(`{"jsonrpc":"2.0","id":2,"error":{"code":-32602,"message":"Insufficient arguments","data":["to"]}}`)
+
+	p.send(`{"jsonrpc":"2.0","id":3,"method":"Math.Double","params":[21]}`)
+	p.expect(`{"jsonrpc":"2.0","id":3,"result":42}`)
+
+	// Method types are recorded for introspection
+	m, _ := ra.GetMethod("Bank.Transfer")
+	if m.ParamsType != reflect.TypeOf(&transferParams{}) || m.ResultType != reflect.TypeOf(map[string]interface{}{}) {
+		t.Fatalf("unexpected types %v %v", m.ParamsType, m.ResultType)
+	}
+}
@@ -1,9 +1,11 @@
 package websocket_server
 
 import (
+	"context"
 	"errors"
 	"io"
 	"net"
+	"sync"
 
 	"github.com/google/uuid"
 
@@ -22,6 +24,7 @@ type Packet struct {
 
 type Client interface {
 	GetOptions() *Options
+	GetContext() context.Context
 	GetConnection() net.Conn
 	GetClientID() uuid.UUID
 	GetMeta() *Metadata
@@ -29,10 +32,12 @@ type Client interface {
 	Send(data []byte) error
 	Respond(res *RPCResponse) error
 	Notify(eventName string, payload interface{}) error
+	Call(ctx context.Context, method string, params interface{}) (interface{}, error)
 	Resume() error
 	CreateRunner(func(*Runner)) *Runner
 	Release()
 	Close()
+	CloseWithStatus(code ws.StatusCode, reason string) error
 }
 
 type client struct {
@@ -42,9 +47,22 @@ type client struct {
 	id      uuid.UUID
 	meta    *Metadata
 	runners []*Runner
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	// Endpoint detaches client from poller before connection is closed
+	closer    func(*client)
+	closeOnce sync.Once
+
+	// Frames from concurrent requests must not be interleaved
+	writeMutex sync.Mutex
 }
 
 func NewClient(options *Options, conn net.Conn) Client {
+	return newClient(options, conn)
+}
+
+func newClient(options *Options, conn net.Conn) *client {
 
 	c := &client{
 		options: options,
@@ -56,6 +74,9 @@ func NewClient(options *Options, conn net.Conn) Client {
 
 	c.reader = wsutil.NewReader(c.conn, ws.StateServerSide)
 
+	// Context will be cancelled once the client is closed
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+
 	return c
 }
 
@@ -63,6 +84,10 @@ func (c *client) GetOptions() *Options {
 	return c.options
 }
 
+func (c *client) GetContext() context.Context {
+	return c.ctx
+}
+
 func (c *client) GetConnection() net.Conn {
 	return c.conn
 }
@@ -85,9 +110,33 @@ func (c *client) Release() {
 	c.runners = make([]*Runner, 0)
 }
 
+// Close disconnects client from server side, it is able to be called from any goroutine and more than
+// once. Poller gets no event for connection which is closed locally, so everything is cleaned up here.
 func (c *client) Close() {
-	c.Release()
-	c.conn.Close()
+	c.closeOnce.Do(func() {
+		c.cancel()
+		c.Release()
+		c.options.Adapter.Release(c)
+
+		if c.closer != nil {
+			c.closer(c)
+			return
+		}
+
+		c.conn.Close()
+	})
+}
+
+// CloseWithStatus sends close frame before closing connection
+func (c *client) CloseWithStatus(code ws.StatusCode, reason string) error {
+
+	c.writeMutex.Lock()
+	err := wsutil.WriteServerMessage(c.conn, ws.OpClose, ws.NewCloseFrameBody(code, reason))
+	c.writeMutex.Unlock()
+
+	c.Close()
+
+	return err
 }
 
 func (c *client) Resume() error {
@@ -121,7 +170,15 @@ func (c *client) GetClientID() uuid.UUID {
 
 func (c *client) Send(data []byte) error {
 
-	w := wsutil.NewWriter(c.conn, ws.StateServerSide, ws.OpText)
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	op := ws.OpText
+	if c.options.Adapter.IsBinary() {
+		op = ws.OpBinary
+	}
+
+	w := wsutil.NewWriter(c.conn, ws.StateServerSide, op)
 
 	w.Write(data)
 
@@ -151,3 +208,7 @@ func (c *client) Notify(eventName string, payload interface{}) error {
 
 	return c.Send(data)
 }
+
+func (c *client) Call(ctx context.Context, method string, params interface{}) (interface{}, error) {
+	return c.options.Adapter.Call(ctx, c, method, params)
+}
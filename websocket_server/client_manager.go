@@ -14,29 +14,52 @@ type ClientManager struct {
 	// Unregister requests from clients.
 	unregister chan Client
 
-	// Close all clients
-	closeAll chan interface{}
+	// Close all clients, registered clients are handed over to caller
+	closeAll chan chan []Client
+
+	// Closed once manager stops
+	done chan struct{}
 }
 
 func NewClientManager() *ClientManager {
 	return &ClientManager{
 		register:   make(chan Client, 1024),
 		unregister: make(chan Client, 1024),
-		closeAll:   make(chan interface{}),
+		closeAll:   make(chan chan []Client),
+		done:       make(chan struct{}),
 		clients:    make(map[Client]struct{}),
 	}
 }
 
 func (clientMgr *ClientManager) Register(c Client) {
-	clientMgr.register <- c
+	select {
+	case clientMgr.register <- c:
+	case <-clientMgr.done:
+	}
 }
 
+// Unregister never blocks once manager stopped, so clients are able to be closed by Close
 func (clientMgr *ClientManager) Unregister(c Client) {
-	clientMgr.unregister <- c
+	select {
+	case clientMgr.unregister <- c:
+	case <-clientMgr.done:
+	}
 }
 
+// Close stops manager then closes registered clients in caller's goroutine
 func (clientMgr *ClientManager) Close() {
-	clientMgr.closeAll <- true
+
+	reply := make(chan []Client, 1)
+
+	select {
+	case clientMgr.closeAll <- reply:
+	case <-clientMgr.done:
+		return
+	}
+
+	for _, c := range <-reply {
+		c.Close()
+	}
 }
 
 func (clientMgr *ClientManager) Run() {
@@ -53,10 +76,17 @@ func (clientMgr *ClientManager) Run() {
 					delete(clientMgr.clients, client)
 					atomic.AddUint64((*uint64)(&clientMgr.clientCount), ^uint64(0))
 				}
-			case <-clientMgr.closeAll:
+			case reply := <-clientMgr.closeAll:
+				clients := make([]Client, 0, len(clientMgr.clients))
 				for client := range clientMgr.clients {
-					client.Close()
+					clients = append(clients, client)
 				}
+
+				clientMgr.clients = make(map[Client]struct{})
+				atomic.StoreUint64(&clientMgr.clientCount, 0)
+				close(clientMgr.done)
+
+				reply <- clients
 				return
 			}
 		}
@@ -0,0 +1,68 @@
+package websocket_server
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// managedClient unregisters itself when it is closed like endpoint does
+type managedClient struct {
+	Client
+	mgr    *ClientManager
+	closed int32
+}
+
+func (c *managedClient) Close() {
+	atomic.AddInt32(&c.closed, 1)
+	c.mgr.Unregister(c)
+}
+
+func TestClientManagerClose(t *testing.T) {
+
+	mgr := NewClientManager()
+	mgr.Run()
+
+	// More clients than unregister channel is able to buffer
+	clients := make([]*managedClient, 2000)
+	for i := range clients {
+		clients[i] = &managedClient{mgr: mgr}
+		mgr.Register(clients[i])
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadUint64(&mgr.clientCount) != uint64(len(clients)) {
+		if time.Now().After(deadline) {
+			t.Fatal("clients were not registered")
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		mgr.Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("closing clients was blocked")
+	}
+
+	for _, c := range clients {
+		if atomic.LoadInt32(&c.closed) != 1 {
+			t.Fatal("client was not closed once")
+		}
+	}
+
+	// Manager which is stopped never blocks callers
+	mgr.Register(&managedClient{mgr: mgr})
+	clients[0].Close()
+	mgr.Close()
+
+	if atomic.LoadUint64(&mgr.clientCount) != 0 {
+		t.Fatalf("unexpected count %d", mgr.clientCount)
+	}
+}
@@ -1,19 +1,68 @@
 package websocket_server
 
-import "io"
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"time"
+)
 
 type Context struct {
-	client Client
-	req    *RPCRequest
+	context.Context
+
+	client  Client
+	req     *RPCRequest
+	cancel  context.CancelFunc
+	session *rpcSession
+	batch   *rpcBatch
+	stream  *Stream
+
+	// Whether request was cancelled by client
+	aborted int32
 }
 
 func NewContext(client Client, req *RPCRequest) *Context {
+
+	// Derived from client context so it will be cancelled when client disconnected
+	c, cancel := context.WithCancel(client.GetContext())
+
 	return &Context{
-		client: client,
-		req:    req,
+		Context: c,
+		client:  client,
+		req:     req,
+		cancel:  cancel,
+	}
+}
+
+func (ctx *Context) applyTimeout(timeout time.Duration) {
+
+	if timeout <= 0 {
+		return
+	}
+
+	c, cancel := context.WithTimeout(ctx.Context, timeout)
+	parentCancel := ctx.cancel
+
+	ctx.Context = c
+	ctx.cancel = func() {
+		cancel()
+		parentCancel()
 	}
 }
 
+func (ctx *Context) Cancel() {
+	ctx.cancel()
+}
+
+func (ctx *Context) abort() {
+	atomic.StoreInt32(&ctx.aborted, 1)
+	ctx.cancel()
+}
+
+func (ctx *Context) IsAborted() bool {
+	return atomic.LoadInt32(&ctx.aborted) == 1
+}
+
 func (ctx *Context) GetMeta() *Metadata {
 	return ctx.client.GetMeta()
 }
@@ -30,6 +79,51 @@ func (ctx *Context) GetRequest() *RPCRequest {
 	return ctx.req
 }
 
+// GetStream returns stream for emitting partial results, it is nil if method is not a streaming method
+func (ctx *Context) GetStream() *Stream {
+	return ctx.stream
+}
+
+func (ctx *Context) Param(index int) interface{} {
+
+	params, _ := ctx.req.Params.([]interface{})
+	if index < 0 || index >= len(params) {
+		return nil
+	}
+
+	return params[index]
+}
+
+func (ctx *Context) NamedParam(name string) (interface{}, bool) {
+
+	if ctx.req.NamedParams == nil {
+		return nil, false
+	}
+
+	val, ok := ctx.req.NamedParams[name]
+
+	return val, ok
+}
+
+func (ctx *Context) GetNamedParams() map[string]interface{} {
+	return ctx.req.NamedParams
+}
+
+// LookupParam reads param by name if params were given by name, otherwise by position
+func (ctx *Context) LookupParam(index int, name string) (interface{}, bool) {
+
+	if ctx.req.HasNamedParams() {
+		return ctx.NamedParam(name)
+	}
+
+	params, _ := ctx.req.Params.([]interface{})
+	if index < 0 || index >= len(params) {
+		return nil, false
+	}
+
+	return params[index], true
+}
+
 func (ctx *Context) Error(code RPCErrorCode, data string) error {
 	return NewError(code, data)
 }
@@ -3,6 +3,7 @@ package websocket_server
 import (
 	"fmt"
 	"net/http"
+	"sync/atomic"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gobwas/ws"
@@ -14,6 +15,9 @@ type Options struct {
 	OnConnected    func(Client) error
 	OnDisconnected func(Client) error
 	OnMessage      func(Client) error
+
+	// Subprotocols which are accepted in order of preference of client, empty means no negotiation
+	Subprotocols []string
 }
 
 func NewOptions() *Options {
@@ -58,14 +62,6 @@ func NewEndpoint(uri string, options *Options) *Endpoint {
 			err := c.Resume()
 			if err != nil {
 				c.Close()
-
-				ep.pollerPool.Remove(c)
-
-				// Unregister client
-				ep.clientMgr.Unregister(c)
-
-				// Emit event
-				ep.options.OnDisconnected(c)
 			}
 		}
 	})
@@ -91,25 +87,61 @@ func (ep *Endpoint) Establish(c *gin.Context) {
 	}
 
 	// Disallow to establish connection when the number of clients exceeds
-	if ep.clientMgr.clientCount >= uint64(ep.options.MaxClients) {
+	if atomic.LoadUint64(&ep.clientMgr.clientCount) >= uint64(ep.options.MaxClients) {
 		logger.Warn("Too Many Connections")
 		c.String(http.StatusTooManyRequests, "Too Many Connections")
 		return
 	}
 
 	// Initializing websocket connection
-	conn, _, _, err := ws.UpgradeHTTP(c.Request, c.Writer)
+	upgrader := ws.HTTPUpgrader{
+		Protocol: ep.acceptProtocol,
+	}
+
+	conn, _, _, err := upgrader.Upgrade(c.Request, c.Writer)
 	if err != nil {
 		logger.Error(err.Error())
 		return
 	}
 
 	// Create client
-	client := NewClient(ep.options, conn)
+	client := newClient(ep.options, conn)
+	client.closer = ep.disconnect
 
 	ep.clientMgr.Register(client)
+
+	if a, ok := ep.options.Adapter.(Attacher); ok {
+		a.Attach(client)
+	}
+
 	ep.pollerPool.Add(client)
 
 	// Emit event
 	ep.options.OnConnected(client)
 }
+
+// disconnect runs once client is closed by either side
+func (ep *Endpoint) disconnect(c *client) {
+
+	// Poller is unable to find connection once it is closed
+	ep.pollerPool.Remove(c)
+
+	c.conn.Close()
+
+	// Unregister client
+	ep.clientMgr.Unregister(c)
+
+	// Emit event
+	ep.options.OnDisconnected(c)
+}
+
+func (ep *Endpoint) acceptProtocol(protocol string) bool {
+
+	for _, p := range ep.options.Subprotocols {
+		if p == protocol {
+			return true
+		}
+	}
+
+	return false
+}
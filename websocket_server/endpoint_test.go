@@ -0,0 +1,97 @@
+package websocket_server_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/weedbox/websocket-modules/jsonrpc"
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+func serveEndpoint(t *testing.T, options *websocket_server.Options) string {
+
+	ep := websocket_server.NewEndpoint("/ws", options)
+
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	r.GET("/ws", func(c *gin.Context) {
+		ep.Establish(c)
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &http.Server{Handler: r}
+	go srv.Serve(ln)
+	t.Cleanup(func() {
+		srv.Close()
+	})
+
+	return "ws://" + ln.Addr().String() + "/ws"
+}
+
+func TestEndpointServerSideClose(t *testing.T) {
+
+	connected := make(chan websocket_server.Client, 1)
+	disconnected := make(chan websocket_server.Client, 1)
+
+	options := websocket_server.NewOptions()
+	options.Adapter = websocket_server.NewRPCAdapter(websocket_server.WithRPCBackend(&jsonrpc.JSONRPC{}))
+	options.OnConnected = func(c websocket_server.Client) error {
+		connected <- c
+		return nil
+	}
+	options.OnDisconnected = func(c websocket_server.Client) error {
+		disconnected <- c
+		return nil
+	}
+
+	conn, _, _, err := ws.Dial(context.Background(), serveEndpoint(t, options))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	c := <-connected
+
+	// Closed from another goroutine rather than the poller
+	go c.CloseWithStatus(ws.StatusPolicyViolation, "bye")
+
+	select {
+	case dc := <-disconnected:
+		if dc != c {
+			t.Fatal("unexpected client was disconnected")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnDisconnected was not emitted")
+	}
+
+	if c.GetContext().Err() == nil {
+		t.Fatal("context of client was not canceled")
+	}
+
+	// Peer receives close frame and then EOF
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err = wsutil.ReadServerData(conn)
+	closed, ok := err.(wsutil.ClosedError)
+	if !ok || closed.Code != ws.StatusPolicyViolation {
+		t.Fatalf("unexpected close %v", err)
+	}
+
+	// Closing again is harmless
+	c.Close()
+
+	select {
+	case <-disconnected:
+		t.Fatal("OnDisconnected was emitted twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
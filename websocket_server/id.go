@@ -0,0 +1,202 @@
+package websocket_server
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+)
+
+var (
+	ErrInvalidID = errors.New("rpc: invalid id")
+)
+
+type IDKind int8
+
+const (
+	IDKind_None IDKind = iota
+	IDKind_Null
+	IDKind_Number
+	IDKind_String
+)
+
+// ID identifies a request. Numbers are kept in their literal form so they are able to be
+// sent back exactly as received. Zero value of ID means the id is absent.
+type ID struct {
+	kind  IDKind
+	value string
+}
+
+var NullID = ID{kind: IDKind_Null}
+
+func NewIntID(id int64) ID {
+	return ID{
+		kind:  IDKind_Number,
+		value: strconv.FormatInt(id, 10),
+	}
+}
+
+func NewStringID(id string) ID {
+	return ID{
+		kind:  IDKind_String,
+		value: id,
+	}
+}
+
+func NewNumberID(literal string) (ID, error) {
+
+	if _, err := strconv.ParseFloat(literal, 64); err != nil {
+		return ID{}, ErrInvalidID
+	}
+
+	return ID{
+		kind:  IDKind_Number,
+		value: literal,
+	}, nil
+}
+
+// NewIDFromValue converts value which is decoded by codecs into ID
+func NewIDFromValue(v interface{}) (ID, error) {
+
+	switch id := v.(type) {
+	case nil:
+		return NullID, nil
+	case ID:
+		return id, nil
+	case string:
+		return NewStringID(id), nil
+	case int:
+		return NewIntID(int64(id)), nil
+	case int8:
+		return NewIntID(int64(id)), nil
+	case int16:
+		return NewIntID(int64(id)), nil
+	case int32:
+		return NewIntID(int64(id)), nil
+	case int64:
+		return NewIntID(id), nil
+	case uint:
+		return NewNumberID(strconv.FormatUint(uint64(id), 10))
+	case uint8:
+		return NewIntID(int64(id)), nil
+	case uint16:
+		return NewIntID(int64(id)), nil
+	case uint32:
+		return NewIntID(int64(id)), nil
+	case uint64:
+		return NewNumberID(strconv.FormatUint(id, 10))
+	case float32:
+		return NewNumberID(strconv.FormatFloat(float64(id), 'g', -1, 32))
+	case float64:
+		return NewNumberID(strconv.FormatFloat(id, 'g', -1, 64))
+	case interface{ String() string }:
+		// json.Number and alike
+		return NewNumberID(id.String())
+	}
+
+	return ID{}, ErrInvalidID
+}
+
+func (id ID) Kind() IDKind {
+	return id.kind
+}
+
+func (id ID) IsAbsent() bool {
+	return id.kind == IDKind_None
+}
+
+func (id ID) IsNull() bool {
+	return id.kind == IDKind_Null || id.kind == IDKind_None
+}
+
+func (id ID) String() string {
+
+	switch id.kind {
+	case IDKind_String, IDKind_Number:
+		return id.value
+	}
+
+	return "null"
+}
+
+func (id ID) Int64() (int64, bool) {
+
+	if id.kind != IDKind_Number {
+		return 0, false
+	}
+
+	n, err := strconv.ParseInt(id.value, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return n, true
+}
+
+// Value returns native representation of ID for codecs
+func (id ID) Value() interface{} {
+
+	switch id.kind {
+	case IDKind_String:
+		return id.value
+	case IDKind_Number:
+		if n, err := strconv.ParseInt(id.value, 10, 64); err == nil {
+			return n
+		}
+
+		if n, err := strconv.ParseUint(id.value, 10, 64); err == nil {
+			return n
+		}
+
+		f, _ := strconv.ParseFloat(id.value, 64)
+
+		return f
+	}
+
+	return nil
+}
+
+func (id ID) MarshalJSON() ([]byte, error) {
+
+	switch id.kind {
+	case IDKind_Number:
+		return []byte(id.value), nil
+	case IDKind_String:
+		return json.Marshal(id.value)
+	}
+
+	return []byte("null"), nil
+}
+
+func (id *ID) UnmarshalJSON(data []byte) error {
+
+	data = bytes.TrimSpace(data)
+
+	if len(data) == 0 {
+		return ErrInvalidID
+	}
+
+	switch data[0] {
+	case 'n':
+		if string(data) != "null" {
+			return ErrInvalidID
+		}
+
+		*id = NullID
+	case '"':
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return ErrInvalidID
+		}
+
+		*id = NewStringID(s)
+	default:
+		n, err := NewNumberID(string(data))
+		if err != nil {
+			return err
+		}
+
+		*id = n
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package websocket_server_test
+
+import (
+	"errors"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+func TestIDJSON(t *testing.T) {
+
+	cases := []struct {
+		data string
+		kind websocket_server.IDKind
+	}{
+		{`1`, websocket_server.IDKind_Number},
+		{`-3`, websocket_server.IDKind_Number},
+		{`1.50`, websocket_server.IDKind_Number},
+		{`12345678901234567890`, websocket_server.IDKind_Number},
+		{`"abc"`, websocket_server.IDKind_String},
+		{`""`, websocket_server.IDKind_String},
+		{`null`, websocket_server.IDKind_Null},
+	}
+
+	for _, c := range cases {
+
+		var id websocket_server.ID
+		if err := json.Unmarshal([]byte(c.data), &id); err != nil {
+			t.Fatalf("%s: %v", c.data, err)
+		}
+
+		if id.Kind() != c.kind {
+			t.Fatalf("%s: unexpected kind %d", c.data, id.Kind())
+		}
+
+		// Numbers are sent back exactly as received
+		data, err := json.Marshal(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != c.data {
+			t.Fatalf("%s: unexpected encoding %s", c.data, data)
+		}
+	}
+
+	for _, data := range []string{`true`, `{}`, `[1]`, `nul`} {
+
+		var id websocket_server.ID
+		if err := id.UnmarshalJSON([]byte(data)); !errors.Is(err, websocket_server.ErrInvalidID) {
+			t.Fatalf("%s: unexpected error %v", data, err)
+		}
+	}
+}
+
+func TestIDAbsent(t *testing.T) {
+
+	var msg struct {
+		ID websocket_server.ID `json:"id"`
+	}
+
+	if err := json.Unmarshal([]byte(`{}`), &msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if !msg.ID.IsAbsent() || !msg.ID.IsNull() {
+		t.Fatal("id should be absent")
+	}
+
+	if err := json.Unmarshal([]byte(`{"id":null}`), &msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.ID.IsAbsent() || !msg.ID.IsNull() {
+		t.Fatal("id should be null rather than absent")
+	}
+}
+
+func TestIDFromValue(t *testing.T) {
+
+	cases := []struct {
+		value    interface{}
+		expected websocket_server.ID
+	}{
+		{nil, websocket_server.NullID},
+		{"x", websocket_server.NewStringID("x")},
+		{int8(-1), websocket_server.NewIntID(-1)},
+		{uint32(5), websocket_server.NewIntID(5)},
+		{float64(7), websocket_server.NewIntID(7)},
+		{jsoniter.Number("9"), websocket_server.NewIntID(9)},
+	}
+
+	for _, c := range cases {
+
+		id, err := websocket_server.NewIDFromValue(c.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if id != c.expected {
+			t.Fatalf("%v: unexpected id %v", c.value, id)
+		}
+	}
+
+	if _, err := websocket_server.NewIDFromValue(true); !errors.Is(err, websocket_server.ErrInvalidID) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if n, ok := websocket_server.NewStringID("1").Int64(); ok {
+		t.Fatalf("string id was converted to %d", n)
+	}
+}
+
+func TestRPCResponseID(t *testing.T) {
+
+	ra := newJSONRPCAdapter()
+	ra.Register("Ping", func(c *websocket_server.Context) (interface{}, error) {
+		return "pong", nil
+	})
+
+	p := connect(t, ra)
+
+	for _, id := range []string{`"a"`, `null`, `12345678901234567890`, `1.0`} {
+
+		p.send(`{"jsonrpc":"2.0","id":` + id + `,"method":"Ping"}`)
+
+		expected := `{"jsonrpc":"2.0","id":` + id + `,"result":"pong"}`
+		if data := p.readText(); string(data) != expected {
+			t.Fatalf("unexpected response %s, expected %s", data, expected)
+		}
+	}
+}
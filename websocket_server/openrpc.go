@@ -0,0 +1,215 @@
+package websocket_server
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+const (
+	OpenRPCVersion = "1.3.2"
+
+	// DiscoverMethod is reserved by OpenRPC for service discovery
+	DiscoverMethod = "rpc.discover"
+)
+
+const (
+	DefaultOpenRPCTitle   = "WebSocket RPC"
+	DefaultOpenRPCVersion = "1.0.0"
+)
+
+type OpenRPCInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type OpenRPCDocument struct {
+	OpenRPC    string             `json:"openrpc"`
+	Info       *OpenRPCInfo       `json:"info"`
+	Methods    []*OpenRPCMethod   `json:"methods"`
+	Components *OpenRPCComponents `json:"components,omitempty"`
+
+	// Notifications which are sent by server, OpenRPC has no standard way to describe them
+	Events []*OpenRPCMethod `json:"x-events,omitempty"`
+}
+
+type OpenRPCMethod struct {
+	Name           string                      `json:"name"`
+	Description    string                      `json:"description,omitempty"`
+	Deprecated     bool                        `json:"deprecated,omitempty"`
+	ParamStructure string                      `json:"paramStructure,omitempty"`
+	Params         []*OpenRPCContentDescriptor `json:"params"`
+	Result         *OpenRPCContentDescriptor   `json:"result,omitempty"`
+	Errors         []*OpenRPCReference         `json:"errors,omitempty"`
+	Streaming      bool                        `json:"x-streaming,omitempty"`
+}
+
+type OpenRPCContentDescriptor struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type OpenRPCError struct {
+	Code    int64  `json:"code"`
+	Message string `json:"message"`
+}
+
+type OpenRPCReference struct {
+	Ref string `json:"$ref"`
+}
+
+type OpenRPCComponents struct {
+	Errors map[string]*OpenRPCError `json:"errors,omitempty"`
+}
+
+func WithOpenRPCInfo(info *OpenRPCInfo) RPCAdapterOpt {
+	return func(a *RPCAdapter) {
+		a.info = info
+	}
+}
+
+// GenerateOpenRPC creates document from registered methods, methods without result are notifications
+func (ra *RPCAdapter) GenerateOpenRPC() *OpenRPCDocument {
+
+	doc := &OpenRPCDocument{
+		OpenRPC: OpenRPCVersion,
+		Info:    ra.info,
+		Methods: make([]*OpenRPCMethod, 0),
+		Components: &OpenRPCComponents{
+			Errors: make(map[string]*OpenRPCError),
+		},
+	}
+
+	// Error codes are converted to the ones which are seen by clients
+	converter, _ := ra.backend.(ErrorCodeConverter)
+
+	codes := GetErrorCodes()
+	sorted := make([]RPCErrorCode, 0, len(codes))
+	for code := range codes {
+		sorted = append(sorted, code)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	errs := make([]*OpenRPCReference, 0, len(sorted))
+	for _, code := range sorted {
+
+		wireCode := int64(code)
+		if converter != nil {
+			wireCode = converter.ConvertErrorCode(code)
+		}
+
+		key := strconv.FormatInt(int64(code), 10)
+		doc.Components.Errors[key] = &OpenRPCError{
+			Code:    wireCode,
+			Message: codes[code],
+		}
+
+		errs = append(errs, &OpenRPCReference{Ref: "#/components/errors/" + key})
+	}
+
+	for _, m := range ra.GetMethods() {
+
+		// Discovery method should not be included in the document
+		if m.Name == DiscoverMethod {
+			continue
+		}
+
+		om := newOpenRPCMethod(m)
+		om.Errors = errs
+		om.Result = &OpenRPCContentDescriptor{
+			Name:   "result",
+			Schema: GenerateSchema(m.ResultType),
+		}
+
+		doc.Methods = append(doc.Methods, om)
+	}
+
+	for _, m := range ra.GetNotificationMethods() {
+		doc.Methods = append(doc.Methods, newOpenRPCMethod(m))
+	}
+
+	for _, m := range sortMethods(ra.events) {
+		doc.Events = append(doc.Events, newOpenRPCMethod(m))
+	}
+
+	return doc
+}
+
+func (ra *RPCAdapter) discover(c *Context) (interface{}, error) {
+	return ra.GenerateOpenRPC(), nil
+}
+
+func newOpenRPCMethod(m *RPCMethod) *OpenRPCMethod {
+
+	om := &OpenRPCMethod{
+		Name:        m.Name,
+		Description: m.Description,
+		Deprecated:  m.Deprecated,
+		Streaming:   m.Streaming,
+		Params:      make([]*OpenRPCContentDescriptor, 0),
+	}
+
+	schema := m.GetParamsSchema()
+	if schema == nil {
+		return om
+	}
+
+	// Struct without fields accepts no params
+	if m.ParamsSchema == nil && indirectType(m.ParamsType).Kind() == reflect.Struct && len(schema.Properties) == 0 {
+		return om
+	}
+
+	// Struct fields are able to be given by position in declaration order or by name
+	if schema.Type == "object" && len(schema.Properties) > 0 {
+
+		om.ParamStructure = "by-name"
+
+		names := make([]string, 0, len(schema.Properties))
+		for name := range schema.Properties {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		if m.ParamsSchema == nil && indirectType(m.ParamsType).Kind() == reflect.Struct {
+			om.ParamStructure = "either"
+			names = positionalFields(indirectType(m.ParamsType))
+		}
+
+		required := make(map[string]bool, len(schema.Required))
+		for _, name := range schema.Required {
+			required[name] = true
+		}
+
+		for _, name := range names {
+
+			ps := *schema.Properties[name]
+			desc := ps.Description
+			ps.Description = ""
+
+			om.Params = append(om.Params, &OpenRPCContentDescriptor{
+				Name:        name,
+				Description: desc,
+				Required:    required[name],
+				Schema:      &ps,
+			})
+		}
+
+		return om
+	}
+
+	om.ParamStructure = "by-position"
+	om.Params = append(om.Params, &OpenRPCContentDescriptor{
+		Name:     "params",
+		Required: true,
+		Schema:   schema,
+	})
+
+	return om
+}
@@ -0,0 +1,117 @@
+package websocket_server_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+type movement struct {
+	X int `json:"x" validate:"required" description:"Horizontal offset"`
+	Y int `json:"y"`
+}
+
+func newDocumentedAdapter() *websocket_server.RPCAdapter {
+
+	ra := newJSONRPCAdapter(websocket_server.WithOpenRPCInfo(&websocket_server.OpenRPCInfo{
+		Title:   "Game",
+		Version: "2.0.0",
+	}))
+
+	websocket_server.RegisterTyped(ra, "Move", func(c *websocket_server.Context, m *movement) (bool, error) {
+		return true, nil
+	}, websocket_server.WithDescription("Moves player"))
+
+	websocket_server.RegisterTyped(ra, "Label", func(c *websocket_server.Context, l *labelled) (string, error) {
+		return l.Label, nil
+	}, websocket_server.WithDeprecated())
+
+	ra.Register("Sum", func(c *websocket_server.Context) (interface{}, error) {
+		return nil, nil
+	}, websocket_server.WithMethodTypes(reflect.TypeOf([]int{}), reflect.TypeOf(0)))
+
+	ra.RegisterNotification("Log", func(c *websocket_server.Context) error {
+		return nil
+	})
+
+	ra.RegisterEvent("Moved", reflect.TypeOf(&movement{}))
+
+	return ra
+}
+
+func TestGenerateOpenRPC(t *testing.T) {
+
+	doc := newDocumentedAdapter().GenerateOpenRPC()
+
+	if doc.OpenRPC != websocket_server.OpenRPCVersion || doc.Info.Title != "Game" || doc.Info.Version != "2.0.0" {
+		t.Fatalf("unexpected document %+v", doc)
+	}
+
+	methods := make(map[string]*websocket_server.OpenRPCMethod)
+	for _, m := range doc.Methods {
+		methods[m.Name] = m
+	}
+
+	if len(doc.Methods) != 4 || methods[websocket_server.DiscoverMethod] != nil {
+		t.Fatalf("unexpected methods %d", len(doc.Methods))
+	}
+
+	// Struct params are able to be given by position in declaration order
+	move := methods["Move"]
+	if move.ParamStructure != "either" || len(move.Params) != 2 || move.Description != "Moves player" {
+		t.Fatalf("unexpected method %+v", move)
+	}
+
+	x := move.Params[0]
+	if x.Name != "x" || !x.Required || x.Description != "Horizontal offset" || x.Schema.Description != "" || x.Schema.Type != "integer" {
+		t.Fatalf("unexpected param %+v", x)
+	}
+
+	if move.Result.Schema.Type != "boolean" || len(move.Errors) == 0 {
+		t.Fatalf("unexpected result %+v", move.Result)
+	}
+
+	// Promoted fields take positions of embedded struct
+	label := methods["Label"]
+	if label.ParamStructure != "either" || !label.Deprecated || label.Params[0].Name != "id" || label.Params[1].Name != "label" {
+		t.Fatalf("unexpected method %+v", label)
+	}
+
+	sum := methods["Sum"]
+	if sum.ParamStructure != "by-position" || len(sum.Params) != 1 || sum.Params[0].Schema.Type != "array" || sum.Result.Schema.Type != "integer" {
+		t.Fatalf("unexpected method %+v", sum)
+	}
+
+	// Notifications have no result
+	if log := methods["Log"]; log.Result != nil || log.Errors != nil || len(log.Params) != 0 {
+		t.Fatalf("unexpected notification %+v", log)
+	}
+
+	if len(doc.Events) != 1 || doc.Events[0].Name != "Moved" || len(doc.Events[0].Params) != 2 {
+		t.Fatalf("unexpected events %+v", doc.Events)
+	}
+
+	// Error codes are the ones which are seen by clients
+	notFound := doc.Components.Errors["2000"]
+	if notFound == nil || notFound.Code != -32601 || notFound.Message != "Method not found" {
+		t.Fatalf("unexpected error %+v", notFound)
+	}
+}
+
+func TestRPCDiscover(t *testing.T) {
+
+	p := connect(t, newDocumentedAdapter())
+
+	p.send(`{"jsonrpc":"2.0","id":1,"method":"rpc.discover"}`)
+
+	msg, ok := p.read().(map[string]interface{})
+	if !ok {
+		t.Fatal("invalid response")
+	}
+
+	result, ok := msg["result"].(map[string]interface{})
+	if !ok || result["openrpc"] != websocket_server.OpenRPCVersion || len(result["methods"].([]interface{})) != 4 {
+		t.Fatalf("unexpected response %v", msg)
+	}
+}
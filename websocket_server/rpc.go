@@ -1,34 +1,95 @@
 package websocket_server
 
 import (
+	"context"
 	"errors"
+	"reflect"
+	"sync"
+	"time"
 
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
+const CancelRequestMethod = "$/cancelRequest"
+
+const DefaultMaxStrikes = 10
+
 var (
 	ErrMethodNotFound = errors.New("rpc: method not found")
+	ErrTooManyStrikes = errors.New("rpc: too many malformed messages")
 )
 
 type RPCAdapterOpt func(*RPCAdapter)
+type RPCMethodOpt func(*RPCMethod)
 type RPCFunc func(*Context) (interface{}, error)
+type NotificationFunc func(*Context) error
 
 type RPCRequest struct {
-	ID     int64
+	ID     ID
 	Method string
+
+	// Positional params which is always []interface{}
 	Params interface{}
+
+	// By-name params, nil if params were given by position
+	NamedParams map[string]interface{}
+
+	Timeout time.Duration
+
+	// Notification expects no response
+	Notification bool
+
+	// Backend reports invalid request here, it will be responded without invoking handler
+	Error error
+
+	// Response to call which was initiated by server, other fields are unused if it is set
+	Response *RPCResponse
+
+	// Members of batch, other fields are unused if it is not empty
+	Batch []*RPCRequest
+}
+
+func (req *RPCRequest) HasNamedParams() bool {
+	return req.NamedParams != nil
 }
 
 type RPCResponse struct {
-	ID     int64
+	ID     ID
 	Error  error
 	Result interface{}
 }
 
+type RPCMethod struct {
+	Name         string
+	Handler      RPCFunc
+	Timeout      time.Duration
+	ParamsType   reflect.Type
+	ResultType   reflect.Type
+	Streaming    bool
+	StreamWindow int
+	Description  string
+	Deprecated   bool
+
+	// Overrides the schema which is derived from ParamsType
+	ParamsSchema *Schema
+}
+
 type RPCAdapter struct {
-	backend      Backend
-	requestQueue *RequestQueue
-	methods      map[string]RPCFunc
+	callSeq int64
+
+	backend        Backend
+	requestQueue   *RequestQueue
+	methods        map[string]*RPCMethod
+	notifications  map[string]*RPCMethod
+	events         map[string]*RPCMethod
+	info           *OpenRPCInfo
+	defaultTimeout time.Duration
+	maxStrikes     int
+	callTimeout    time.Duration
+	sessions       map[uuid.UUID]*rpcSession
+	controls       map[string]func(*Context) error
+	sessionMutex   sync.RWMutex
 }
 
 func WithRPCBackend(b Backend) RPCAdapterOpt {
@@ -37,11 +98,55 @@ func WithRPCBackend(b Backend) RPCAdapterOpt {
 	}
 }
 
+func WithRPCTimeout(timeout time.Duration) RPCAdapterOpt {
+	return func(a *RPCAdapter) {
+		a.defaultTimeout = timeout
+	}
+}
+
+// WithRPCMaxStrikes sets how many malformed messages are tolerated before disconnecting, zero or negative means unlimited
+func WithRPCMaxStrikes(strikes int) RPCAdapterOpt {
+	return func(a *RPCAdapter) {
+		a.maxStrikes = strikes
+	}
+}
+
+func WithMethodTimeout(timeout time.Duration) RPCMethodOpt {
+	return func(m *RPCMethod) {
+		m.Timeout = timeout
+	}
+}
+
+func WithDescription(desc string) RPCMethodOpt {
+	return func(m *RPCMethod) {
+		m.Description = desc
+	}
+}
+
+func WithDeprecated() RPCMethodOpt {
+	return func(m *RPCMethod) {
+		m.Deprecated = true
+	}
+}
+
+func WithParamsSchema(schema *Schema) RPCMethodOpt {
+	return func(m *RPCMethod) {
+		m.ParamsSchema = schema
+	}
+}
+
 func NewRPCAdapter(opts ...RPCAdapterOpt) *RPCAdapter {
 
 	ra := &RPCAdapter{
-		requestQueue: NewRequestQueue(),
-		methods:      make(map[string]RPCFunc),
+		requestQueue:  NewRequestQueue(),
+		methods:       make(map[string]*RPCMethod),
+		notifications: make(map[string]*RPCMethod),
+		events:        make(map[string]*RPCMethod),
+		info:          &OpenRPCInfo{Title: DefaultOpenRPCTitle, Version: DefaultOpenRPCVersion},
+		sessions:      make(map[uuid.UUID]*rpcSession),
+		controls:      make(map[string]func(*Context) error),
+		maxStrikes:    DefaultMaxStrikes,
+		callTimeout:   DefaultCallTimeout,
 	}
 
 	for _, o := range opts {
@@ -52,6 +157,17 @@ func NewRPCAdapter(opts ...RPCAdapterOpt) *RPCAdapter {
 		ra.backend = NewBackend()
 	}
 
+	ra.controls[CancelRequestMethod] = ra.cancelRequest
+	ra.controls[StreamAckMethod] = ra.ackStream
+
+	// Registered without logging since logger may not be ready yet
+	ra.methods[DiscoverMethod] = &RPCMethod{
+		Name:        DiscoverMethod,
+		Handler:     ra.discover,
+		Description: "Returns the OpenRPC document of this endpoint",
+		ResultType:  reflect.TypeOf(&OpenRPCDocument{}),
+	}
+
 	ra.requestQueue.Consume(ra.consume)
 
 	return ra
@@ -59,50 +175,140 @@ func NewRPCAdapter(opts ...RPCAdapterOpt) *RPCAdapter {
 
 func (ra *RPCAdapter) consume(c *Context) error {
 
-	err := ra.handleRequest(c)
-	if err != nil {
+	defer func() {
+		c.Cancel()
+		c.session.untrack(c)
+	}()
 
-		if errors.Is(err, ErrMethodNotFound) {
-			err = NewError(ErrorCode_NotFound, nil)
-		}
+	returnedValue, err := ra.handleRequest(c)
+
+	// No more chunks after completion
+	c.stream.close()
+
+	// Client is gone, nobody is waiting for response
+	if c.GetClient().GetContext().Err() != nil {
+		return err
+	}
+
+	if err != nil {
 
 		// Error
 		res := &RPCResponse{
 			ID:     c.GetRequest().ID,
-			Error:  err,
+			Error:  ToRPCError(err),
 			Result: "",
 		}
 
 		return ra.respond(c, res)
 	}
 
-	return nil
-}
+	// Response with returned value
+	res := &RPCResponse{
+		ID:     c.GetRequest().ID,
+		Result: returnedValue,
+	}
 
-func (ra *RPCAdapter) handleRequest(c *Context) error {
+	return ra.respond(c, res)
+}
 
-	method := c.GetRequest().Method
+func (ra *RPCAdapter) handleRequest(c *Context) (interface{}, error) {
 
-	fn, ok := ra.methods[method]
+	m, ok := ra.getMethod(c.GetRequest())
 	if !ok {
-		return ErrMethodNotFound
+		return nil, ErrMethodNotFound
 	}
 
 	// Invoke
-	returnedValue, err := fn(c)
+	return ra.invoke(c, m)
+}
 
-	// Response with returned value
-	res := &RPCResponse{
-		ID:     c.GetRequest().ID,
-		Error:  err,
-		Result: returnedValue,
+func (ra *RPCAdapter) getMethod(req *RPCRequest) (*RPCMethod, bool) {
+
+	// Handlers which are registered for notification only are not callable by requests
+	if req.Notification {
+		if m, ok := ra.notifications[req.Method]; ok {
+			return m, true
+		}
 	}
 
-	return ra.respond(c, res)
+	m, ok := ra.methods[req.Method]
+
+	return m, ok
+}
+
+func (ra *RPCAdapter) getTimeout(m *RPCMethod, req *RPCRequest) time.Duration {
+
+	timeout := ra.defaultTimeout
+	if m.Timeout > 0 {
+		timeout = m.Timeout
+	}
+
+	// Client is allowed to shorten deadline only
+	if req.Timeout > 0 && (timeout <= 0 || req.Timeout < timeout) {
+		timeout = req.Timeout
+	}
+
+	return timeout
+}
+
+func (ra *RPCAdapter) invoke(c *Context, m *RPCMethod) (interface{}, error) {
+
+	// Request was cancelled or expired while waiting in the queue
+	if c.Err() != nil {
+		return nil, ra.contextError(c)
+	}
+
+	type result struct {
+		value interface{}
+		err   error
+	}
+
+	done := make(chan result, 1)
+
+	go func() {
+		returnedValue, err := m.Handler(c)
+		done <- result{
+			value: returnedValue,
+			err:   err,
+		}
+	}()
+
+	select {
+	case r := <-done:
+		if c.Err() != nil && (errors.Is(r.err, context.DeadlineExceeded) || errors.Is(r.err, context.Canceled)) {
+			return nil, ra.contextError(c)
+		}
+
+		return r.value, r.err
+	case <-c.Done():
+		return nil, ra.contextError(c)
+	}
+}
+
+func (ra *RPCAdapter) contextError(c *Context) error {
+
+	if c.IsAborted() {
+		return NewError(ErrorCode_Cancelled, nil)
+	}
+
+	return ToRPCError(c.Err())
 }
 
 func (ra *RPCAdapter) respond(c *Context, res *RPCResponse) error {
 
+	// Nothing is sent back for notification, including errors
+	if c.GetRequest().Notification {
+		res = nil
+	}
+
+	if c.batch != nil {
+		return ra.respondBatch(c, res)
+	}
+
+	if res == nil {
+		return nil
+	}
+
 	data, err := ra.PrepareResponse(res)
 	if err != nil {
 		return err
@@ -118,12 +324,52 @@ func (ra *RPCAdapter) HandleMessage(c Client) error {
 	// Parse message
 	req, err := ra.backend.ParseRequest(r)
 	if err != nil {
-		// Ignora unrecognized message
-		return err
+
+		// Transport failure rather than malformed message
+		var rpcErr *RPCError
+		if !errors.As(err, &rpcErr) {
+			return err
+		}
+
+		return ra.reject(c, &RPCResponse{
+			ID:    NullID,
+			Error: rpcErr,
+		})
+	}
+
+	if len(req.Batch) > 0 {
+		return ra.handleBatch(c, req)
+	}
+
+	if req.Response != nil {
+		ra.handleResponse(c, req.Response)
+		return nil
+	}
+
+	if req.Error != nil {
+
+		// Nothing is sent back for notification even if it is malformed
+		if req.Notification {
+			return ra.strike(c)
+		}
+
+		return ra.reject(c, &RPCResponse{
+			ID:    req.ID,
+			Error: req.Error,
+		})
 	}
 
 	// Preparing context
 	ctx := NewContext(c, req)
+	ctx.session = ra.getSession(c)
+
+	// Control messages should not wait in the queue behind the requests they target
+	if fn, ok := ra.controls[req.Method]; ok {
+		defer ctx.Cancel()
+		return fn(ctx)
+	}
+
+	ra.prepare(ctx)
 
 	// Push to queue for processing
 	ra.requestQueue.Push(ctx)
@@ -131,9 +377,131 @@ func (ra *RPCAdapter) HandleMessage(c Client) error {
 	return nil
 }
 
-func (ra *RPCAdapter) Register(method string, fn RPCFunc) error {
+// reject responds to malformed message and disconnects client if it keeps sending such messages
+func (ra *RPCAdapter) reject(c Client, res *RPCResponse) error {
+
+	data, err := ra.PrepareResponse(res)
+	if err != nil {
+		return err
+	}
+
+	if err := c.Send(data); err != nil {
+		return err
+	}
+
+	return ra.strike(c)
+}
+
+func (ra *RPCAdapter) strike(c Client) error {
+
+	strikes := ra.getSession(c).strike()
+	if ra.maxStrikes > 0 && strikes >= ra.maxStrikes {
+		return ErrTooManyStrikes
+	}
+
+	return nil
+}
+
+func (ra *RPCAdapter) prepare(c *Context) {
+
+	req := c.GetRequest()
+
+	// Apply deadline
+	if m, ok := ra.getMethod(req); ok {
+		c.applyTimeout(ra.getTimeout(m, req))
+
+		if m.Streaming && !req.Notification {
+			c.stream = newStream(c, m.StreamWindow)
+		}
+	}
+
+	// Notification cannot be cancelled without ID
+	if !req.Notification {
+		c.session.track(c)
+	}
+}
+
+func (ra *RPCAdapter) cancelRequest(c *Context) error {
+
+	target, ok := c.LookupParam(0, "id")
+	if !ok {
+		return ra.respond(c, &RPCResponse{
+			ID:    c.GetRequest().ID,
+			Error: NewError(ErrorCode_InvalidParams_Insufficient_Arguments, nil),
+		})
+	}
+
+	id, err := NewIDFromValue(target)
+	if err != nil || id.IsNull() {
+		return ra.respond(c, &RPCResponse{
+			ID:    c.GetRequest().ID,
+			Error: NewError(ErrorCode_InvalidParams_Invalid_Arguments, nil),
+		})
+	}
+
+	return ra.respond(c, &RPCResponse{
+		ID:     c.GetRequest().ID,
+		Result: c.session.abort(id),
+	})
+}
+
+func (ra *RPCAdapter) ackStream(c *Context) error {
+
+	target, ok := c.LookupParam(0, "id")
+	if !ok {
+		return ra.respond(c, &RPCResponse{
+			ID:    c.GetRequest().ID,
+			Error: NewError(ErrorCode_InvalidParams_Insufficient_Arguments, nil),
+		})
+	}
+
+	id, err := NewIDFromValue(target)
+	if err != nil || id.IsNull() {
+		return ra.respond(c, &RPCResponse{
+			ID:    c.GetRequest().ID,
+			Error: NewError(ErrorCode_InvalidParams_Invalid_Arguments, nil),
+		})
+	}
+
+	val, _ := c.LookupParam(1, "seq")
+	seq, ok := toInt64(val)
+	if !ok {
+		return ra.respond(c, &RPCResponse{
+			ID:    c.GetRequest().ID,
+			Error: NewError(ErrorCode_InvalidParams_Invalid_Arguments, nil),
+		})
+	}
+
+	return ra.respond(c, &RPCResponse{
+		ID:     c.GetRequest().ID,
+		Result: c.session.ack(id, seq),
+	})
+}
+
+func toInt64(v interface{}) (int64, bool) {
+
+	n, err := NewIDFromValue(v)
+	if err != nil {
+		return 0, false
+	}
+
+	return n.Int64()
+}
+
+func (ra *RPCAdapter) Register(method string, fn RPCFunc, opts ...RPCMethodOpt) error {
 	logger.Info("Registering", zap.String("method", method))
-	ra.methods[method] = fn
+
+	m := &RPCMethod{
+		Name:    method,
+		Handler: fn,
+	}
+
+	for _, o := range opts {
+		o(m)
+	}
+
+	ra.methods[method] = m
+
 	return nil
 }
 
@@ -141,6 +509,70 @@ func (ra *RPCAdapter) Unregister(method string) {
 	delete(ra.methods, method)
 }
 
+func (ra *RPCAdapter) RegisterNotification(method string, fn NotificationFunc, opts ...RPCMethodOpt) error {
+	logger.Info("Registering notification", zap.String("method", method))
+
+	m := &RPCMethod{
+		Name: method,
+		Handler: func(c *Context) (interface{}, error) {
+			return nil, fn(c)
+		},
+	}
+
+	for _, o := range opts {
+		o(m)
+	}
+
+	ra.notifications[method] = m
+
+	return nil
+}
+
+// RegisterStream registers method which is able to emit partial results by stream of context
+func (ra *RPCAdapter) RegisterStream(method string, fn RPCFunc, opts ...RPCMethodOpt) error {
+	opts = append([]RPCMethodOpt{withStreaming()}, opts...)
+	return ra.Register(method, fn, opts...)
+}
+
+func withStreaming() RPCMethodOpt {
+	return func(m *RPCMethod) {
+		m.Streaming = true
+	}
+}
+
+func (ra *RPCAdapter) UnregisterNotification(method string) {
+	delete(ra.notifications, method)
+}
+
+// RegisterEvent describes notification which is sent to clients, it is used for documentation only
+func (ra *RPCAdapter) RegisterEvent(eventName string, payloadType reflect.Type, opts ...RPCMethodOpt) error {
+
+	m := &RPCMethod{
+		Name:       eventName,
+		ParamsType: payloadType,
+	}
+
+	for _, o := range opts {
+		o(m)
+	}
+
+	ra.events[eventName] = m
+
+	return nil
+}
+
+func (ra *RPCAdapter) UnregisterEvent(eventName string) {
+	delete(ra.events, eventName)
+}
+
+func (ra *RPCAdapter) GetBackend() Backend {
+	return ra.backend
+}
+
+func (ra *RPCAdapter) IsBinary() bool {
+	return ra.backend.IsBinary()
+}
+
 func (ra *RPCAdapter) PrepareResponse(res *RPCResponse) ([]byte, error) {
 	return ra.backend.PrepareResponse(res)
 }
@@ -0,0 +1,111 @@
+package websocket_server
+
+import (
+	"sync"
+)
+
+type rpcBatch struct {
+	mutex     sync.Mutex
+	remaining int
+	responses []*RPCResponse
+}
+
+func newRPCBatch(size int) *rpcBatch {
+	return &rpcBatch{
+		remaining: size,
+		responses: make([]*RPCResponse, 0, size),
+	}
+}
+
+// complete collects response of a member, returns all responses once the last member is done
+func (b *rpcBatch) complete(res *RPCResponse) ([]*RPCResponse, bool) {
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if res != nil {
+		b.responses = append(b.responses, res)
+	}
+
+	b.remaining--
+
+	return b.responses, b.remaining == 0
+}
+
+func (ra *RPCAdapter) handleBatch(c Client, req *RPCRequest) error {
+
+	// Responses to calls of server are never answered so they are not members of reply
+	size := 0
+	for _, r := range req.Batch {
+		if r.Response == nil {
+			size++
+		}
+	}
+
+	b := newRPCBatch(size)
+	session := ra.getSession(c)
+	malformed := false
+
+	for _, r := range req.Batch {
+
+		if r.Response != nil {
+			ra.handleResponse(c, r.Response)
+			continue
+		}
+
+		ctx := NewContext(c, r)
+		ctx.session = session
+		ctx.batch = b
+
+		if r.Error != nil {
+			malformed = true
+
+			err := ra.respond(ctx, &RPCResponse{
+				ID:    r.ID,
+				Error: r.Error,
+			})
+			ctx.Cancel()
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if fn, ok := ra.controls[r.Method]; ok {
+			err := fn(ctx)
+			ctx.Cancel()
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		ra.prepare(ctx)
+
+		// Members are executed concurrently by request queue
+		ra.requestQueue.Push(ctx)
+	}
+
+	if malformed {
+		return ra.strike(c)
+	}
+
+	return nil
+}
+
+func (ra *RPCAdapter) respondBatch(c *Context, res *RPCResponse) error {
+
+	responses, done := c.batch.complete(res)
+	if !done || len(responses) == 0 {
+		return nil
+	}
+
+	data, err := ra.backend.PrepareBatchResponse(responses)
+	if err != nil {
+		return err
+	}
+
+	return c.Send(data)
+}
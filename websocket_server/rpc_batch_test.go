@@ -0,0 +1,85 @@
+package websocket_server_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+func TestRPCBatch(t *testing.T) {
+
+	notified := make(chan interface{}, 1)
+
+	ra := newJSONRPCAdapter()
+	ra.Register("Echo", func(c *websocket_server.Context) (interface{}, error) {
+		return c.Param(0), nil
+	})
+	ra.RegisterNotification("Log", func(c *websocket_server.Context) error {
+		notified <- c.Param(0)
+		return nil
+	})
+
+	p := connect(t, ra)
+
+	p.send(`[
+		{"jsonrpc":"2.0","id":1,"method":"Echo","params":["a"]},
+		{"jsonrpc":"2.0","method":"Log","params":["b"]},
+		{"jsonrpc":"2.0","id":2,"method":"Nope"},
+		1,
+		{"jsonrpc":"2.0","id":"3","method":"Echo","params":["c"]}
+	]`)
+
+	// Members are executed concurrently so responses are in any order
+	expected := map[string]string{
+		"1":     `{"jsonrpc":"2.0","id":1,"result":"a"}`,
+		"2":     `{"jsonrpc":"2.0","id":2,"error":{"code":-32601,"message":"Method not found"}}`,
+		"<nil>": `{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"Invalid Request","data":"invalid request object"}}`,
+		"3":     `{"jsonrpc":"2.0","id":"3","result":"c"}`,
+	}
+
+	responses, ok := p.read().([]interface{})
+	if !ok || len(responses) != len(expected) {
+		t.Fatalf("unexpected responses %v", responses)
+	}
+
+	for _, res := range responses {
+		key := fmt.Sprint(res.(map[string]interface{})["id"])
+		if !equalJSON(res, expected[key]) {
+			t.Fatalf("unexpected response %v", res)
+		}
+	}
+
+	if v := <-notified; v != "b" {
+		t.Fatalf("unexpected notification %v", v)
+	}
+}
+
+func TestRPCBatchNotifications(t *testing.T) {
+
+	notified := make(chan interface{}, 2)
+
+	ra := newJSONRPCAdapter()
+	ra.RegisterNotification("Log", func(c *websocket_server.Context) error {
+		notified <- c.Param(0)
+		return nil
+	})
+
+	p := connect(t, ra)
+
+	// Nothing is sent back if batch has notifications only
+	p.send(`[{"jsonrpc":"2.0","method":"Log","params":[1]},{"jsonrpc":"2.0","method":"Log","params":[2]}]`)
+	<-notified
+	<-notified
+
+	p.send(`{"jsonrpc":"2.0","id":1,"method":"Nope"}`)
+	p.expect(`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"Method not found"}}`)
+}
+
+func TestRPCEmptyBatch(t *testing.T) {
+
+	p := connect(t, newJSONRPCAdapter())
+
+	p.send(`[]`)
+	p.expect(`{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"Invalid Request","data":"empty batch"}}`)
+}
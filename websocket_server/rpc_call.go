@@ -0,0 +1,83 @@
+package websocket_server
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+const DefaultCallTimeout = 30 * time.Second
+
+var (
+	ErrCallTimeout = errors.New("rpc: call timeout")
+)
+
+func WithRPCCallTimeout(timeout time.Duration) RPCAdapterOpt {
+	return func(a *RPCAdapter) {
+		a.callTimeout = timeout
+	}
+}
+
+// Call sends request to client and waits for its response. Params should be either an array
+// or an object which is able to be encoded by backend.
+func (ra *RPCAdapter) Call(ctx context.Context, c Client, method string, params interface{}) (interface{}, error) {
+
+	// Apply default timeout if caller has no deadline
+	if _, ok := ctx.Deadline(); !ok && ra.callTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, ra.callTimeout)
+		defer cancel()
+	}
+
+	req := &RPCRequest{
+		ID:     NewIntID(atomic.AddInt64(&ra.callSeq, 1)),
+		Method: method,
+		Params: params,
+	}
+
+	session := ra.getSession(c)
+
+	ch, ok := session.await(req.ID)
+	if !ok {
+		return nil, ErrConnectionClosed
+	}
+
+	defer session.cancelAwait(req.ID)
+
+	data, err := ra.backend.PrepareRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.Send(data); err != nil {
+		return nil, err
+	}
+
+	select {
+	case res, ok := <-ch:
+		if !ok {
+			return nil, ErrConnectionClosed
+		}
+
+		if res.Error != nil {
+			return nil, res.Error
+		}
+
+		return res.Result, nil
+	case <-c.GetContext().Done():
+		return nil, ErrConnectionClosed
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, ErrCallTimeout
+		}
+
+		return nil, ctx.Err()
+	}
+}
+
+func (ra *RPCAdapter) handleResponse(c Client, res *RPCResponse) {
+
+	// Response is ignored if caller has gone
+	ra.getSession(c).resolve(res)
+}
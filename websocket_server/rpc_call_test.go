@@ -0,0 +1,110 @@
+package websocket_server_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+func newCallingAdapter(opts ...websocket_server.RPCAdapterOpt) *websocket_server.RPCAdapter {
+
+	ra := newJSONRPCAdapter(opts...)
+	ra.Register("Ask", func(c *websocket_server.Context) (interface{}, error) {
+		return ra.Call(c, c.GetClient(), "Client.Sum", []interface{}{1, 2})
+	})
+
+	return ra
+}
+
+// expectCall reads call which is initiated by server and returns its id
+func (p *peer) expectCall(method string, params string) string {
+
+	p.t.Helper()
+
+	msg, ok := p.read().(map[string]interface{})
+	if !ok || msg["method"] != method || !equalJSON(msg["params"], params) {
+		p.t.Fatalf("unexpected call %v", msg)
+	}
+
+	id, err := json.Marshal(msg["id"])
+	if err != nil {
+		p.t.Fatal(err)
+	}
+
+	return string(id)
+}
+
+func TestRPCCall(t *testing.T) {
+
+	p := connect(t, newCallingAdapter())
+
+	p.send(`{"jsonrpc":"2.0","id":"q","method":"Ask"}`)
+	id := p.expectCall("Client.Sum", `[1,2]`)
+
+	p.send(`{"jsonrpc":"2.0","id":` + id + `,"result":3}`)
+	p.expect(`{"jsonrpc":"2.0","id":"q","result":3}`)
+
+	// Error of client is passed to caller
+	p.send(`{"jsonrpc":"2.0","id":"r","method":"Ask"}`)
+	id = p.expectCall("Client.Sum", `[1,2]`)
+
+	p.send(`{"jsonrpc":"2.0","id":` + id + `,"error":{"code":-32601,"message":"Method not found"}}`)
+	p.expect(`{"jsonrpc":"2.0","id":"r","error":{"code":-32601,"message":"Method not found"}}`)
+
+	// Responses which are unexpected are ignored
+	p.send(`{"jsonrpc":"2.0","id":` + id + `,"result":3}`)
+	p.expectNothing()
+}
+
+func TestRPCCallTimeout(t *testing.T) {
+
+	p := connect(t, newCallingAdapter(websocket_server.WithRPCCallTimeout(20*time.Millisecond)))
+
+	p.send(`{"jsonrpc":"2.0","id":1,"method":"Ask"}`)
+	p.expectCall("Client.Sum", `[1,2]`)
+	p.expect(`{"jsonrpc":"2.0","id":1,"error":{"code":-32603,"message":"Internal error","data":"rpc: call timeout"}}`)
+}
+
+func TestRPCCallDisconnected(t *testing.T) {
+
+	result := make(chan error, 1)
+
+	ra := newJSONRPCAdapter()
+	ra.Register("Ask", func(c *websocket_server.Context) (interface{}, error) {
+		// Caller without deadline still returns once client is gone
+		_, err := ra.Call(context.Background(), c.GetClient(), "Client.Sum", nil)
+		result <- err
+		return nil, err
+	})
+
+	p := connect(t, ra)
+
+	p.send(`{"jsonrpc":"2.0","id":1,"method":"Ask"}`)
+	p.expectCall("Client.Sum", `null`)
+	p.conn.Close()
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, websocket_server.ErrConnectionClosed) {
+			t.Fatalf("unexpected error %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("call was not aborted")
+	}
+}
+
+func TestRPCCallBatchResponse(t *testing.T) {
+
+	p := connect(t, newCallingAdapter())
+
+	p.send(`{"jsonrpc":"2.0","id":"q","method":"Ask"}`)
+	id := p.expectCall("Client.Sum", `[1,2]`)
+
+	// Member which is answered before response member still gets batch reply
+	p.send(`[1,{"jsonrpc":"2.0","id":` + id + `,"result":3}]`)
+	p.expect(`[{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"Invalid Request","data":"invalid request object"}}]`)
+	p.expect(`{"jsonrpc":"2.0","id":"q","result":3}`)
+}
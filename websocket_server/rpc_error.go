@@ -1,29 +1,58 @@
 package websocket_server
 
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/go-playground/validator/v10"
+)
+
 type RPCErrorCode int32
 
 const (
 	ErrorCode_InvalidRequest                       RPCErrorCode = 1000
+	ErrorCode_ParseError                                        = 1001
 	ErrorCode_NotFound                                          = 2000
 	ErrorCode_InvalidParams                                     = 3000
 	ErrorCode_InvalidParams_Invalid_Arguments                   = 3001
 	ErrorCode_InvalidParams_Insufficient_Arguments              = 3002
 	ErrorCode_InternalError                                     = 4000
 	ErrorCode_ServerError                                       = 5000
+	ErrorCode_Timeout                                           = 6000
+	ErrorCode_Cancelled                                         = 6001
+)
+
+var (
+	ErrErrorCodeExists = errors.New("rpc: error code exists")
 )
 
 var (
 	errorMsgMap = map[RPCErrorCode]string{
 		ErrorCode_InvalidRequest:                       "Invalid Request",
+		ErrorCode_ParseError:                           "Parse error",
 		ErrorCode_NotFound:                             "Method not found",
 		ErrorCode_InvalidParams:                        "Invalid params",
 		ErrorCode_InvalidParams_Invalid_Arguments:      "Invalid arguments",
 		ErrorCode_InvalidParams_Insufficient_Arguments: "Insufficient arguments",
 		ErrorCode_InternalError:                        "Internal error",
 		ErrorCode_ServerError:                          "Server error",
+		ErrorCode_Timeout:                              "Request timeout",
+		ErrorCode_Cancelled:                            "Request cancelled",
+	}
+	errorMsgMutex sync.RWMutex
+
+	errorMappers = []ErrorMapper{
+		mapContextError,
+		mapValidationError,
+		mapMethodNotFound,
 	}
+	errorMapperMutex sync.RWMutex
 )
 
+// ErrorMapper converts arbitrary error into RPC error, returns nil if the error is not recognized
+type ErrorMapper func(err error) *RPCError
+
 type RPCError struct {
 	Code    RPCErrorCode `json:"code"`
 	Message string       `json:"message"`
@@ -37,7 +66,108 @@ func (e *RPCError) Error() string {
 func NewError(code RPCErrorCode, data interface{}) *RPCError {
 	return &RPCError{
 		Code:    code,
-		Message: errorMsgMap[code],
+		Message: GetErrorMessage(code),
 		Data:    data,
 	}
 }
+
+// RegisterErrorCode registers application error code with its default message
+func RegisterErrorCode(code RPCErrorCode, message string) error {
+
+	errorMsgMutex.Lock()
+	defer errorMsgMutex.Unlock()
+
+	if _, ok := errorMsgMap[code]; ok {
+		return ErrErrorCodeExists
+	}
+
+	errorMsgMap[code] = message
+
+	return nil
+}
+
+func GetErrorMessage(code RPCErrorCode) string {
+
+	errorMsgMutex.RLock()
+	defer errorMsgMutex.RUnlock()
+
+	return errorMsgMap[code]
+}
+
+func GetErrorCodes() map[RPCErrorCode]string {
+
+	errorMsgMutex.RLock()
+	defer errorMsgMutex.RUnlock()
+
+	codes := make(map[RPCErrorCode]string, len(errorMsgMap))
+	for code, msg := range errorMsgMap {
+		codes[code] = msg
+	}
+
+	return codes
+}
+
+// RegisterErrorMapper adds mapper which has priority over mappers registered before
+func RegisterErrorMapper(fn ErrorMapper) {
+
+	errorMapperMutex.Lock()
+	defer errorMapperMutex.Unlock()
+
+	errorMappers = append(errorMappers, fn)
+}
+
+// ToRPCError converts any error into RPC error
+func ToRPCError(err error) *RPCError {
+
+	if err == nil {
+		return nil
+	}
+
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		return rpcErr
+	}
+
+	errorMapperMutex.RLock()
+	defer errorMapperMutex.RUnlock()
+
+	for i := len(errorMappers) - 1; i >= 0; i-- {
+		if e := errorMappers[i](err); e != nil {
+			return e
+		}
+	}
+
+	return NewError(ErrorCode_InternalError, err.Error())
+}
+
+func mapContextError(err error) *RPCError {
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return NewError(ErrorCode_Timeout, nil)
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return NewError(ErrorCode_Cancelled, nil)
+	}
+
+	return nil
+}
+
+func mapValidationError(err error) *RPCError {
+
+	var verrs validator.ValidationErrors
+	if errors.As(err, &verrs) {
+		return newValidationError(verrs)
+	}
+
+	return nil
+}
+
+func mapMethodNotFound(err error) *RPCError {
+
+	if errors.Is(err, ErrMethodNotFound) {
+		return NewError(ErrorCode_NotFound, nil)
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package websocket_server_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+const errorCodeInsufficientFunds websocket_server.RPCErrorCode = 9100
+
+var errInsufficientFunds = errors.New("insufficient funds")
+
+func init() {
+	websocket_server.RegisterErrorCode(errorCodeInsufficientFunds, "Insufficient funds")
+	websocket_server.RegisterErrorMapper(func(err error) *websocket_server.RPCError {
+		if errors.Is(err, errInsufficientFunds) {
+			return websocket_server.NewError(errorCodeInsufficientFunds, nil)
+		}
+
+		return nil
+	})
+}
+
+func TestRegisterErrorCode(t *testing.T) {
+
+	if err := websocket_server.RegisterErrorCode(errorCodeInsufficientFunds, "again"); !errors.Is(err, websocket_server.ErrErrorCodeExists) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if err := websocket_server.RegisterErrorCode(websocket_server.ErrorCode_NotFound, "again"); !errors.Is(err, websocket_server.ErrErrorCodeExists) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if msg := websocket_server.GetErrorMessage(errorCodeInsufficientFunds); msg != "Insufficient funds" {
+		t.Fatalf("unexpected message %s", msg)
+	}
+
+	if msg := websocket_server.GetErrorCodes()[websocket_server.ErrorCode_Timeout]; msg != "Request timeout" {
+		t.Fatalf("unexpected message %s", msg)
+	}
+}
+
+func TestToRPCError(t *testing.T) {
+
+	cases := []struct {
+		err  error
+		code websocket_server.RPCErrorCode
+	}{
+		{websocket_server.NewError(websocket_server.ErrorCode_InvalidParams, nil), websocket_server.ErrorCode_InvalidParams},
+		{fmt.Errorf("wrapped: %w", websocket_server.NewError(websocket_server.ErrorCode_ServerError, nil)), websocket_server.ErrorCode_ServerError},
+		{context.DeadlineExceeded, websocket_server.ErrorCode_Timeout},
+		{context.Canceled, websocket_server.ErrorCode_Cancelled},
+		{websocket_server.ErrMethodNotFound, websocket_server.ErrorCode_NotFound},
+		{fmt.Errorf("transfer: %w", errInsufficientFunds), errorCodeInsufficientFunds},
+		{errors.New("boom"), websocket_server.ErrorCode_InternalError},
+	}
+
+	for _, c := range cases {
+		if e := websocket_server.ToRPCError(c.err); e.Code != c.code {
+			t.Fatalf("%v: unexpected code %d", c.err, e.Code)
+		}
+	}
+
+	if e := websocket_server.ToRPCError(errors.New("boom")); e.Data != "boom" || e.Message != "Internal error" {
+		t.Fatalf("unexpected error %+v", e)
+	}
+
+	if websocket_server.ToRPCError(nil) != nil {
+		t.Fatal("nil error was converted")
+	}
+}
+
+func TestRPCCustomErrorCode(t *testing.T) {
+
+	ra := newJSONRPCAdapter()
+	ra.Register("Transfer", func(c *websocket_server.Context) (interface{}, error) {
+		return nil, errInsufficientFunds
+	})
+
+	p := connect(t, ra)
+
+	// Codes which are not mapped by backend are sent as they are
+	p.send(`{"jsonrpc":"2.0","id":1,"method":"Transfer"}`)
+	p.expect(`{"jsonrpc":"2.0","id":1,"error":{"code":9100,"message":"Insufficient funds"}}`)
+}
@@ -0,0 +1,81 @@
+package websocket_server
+
+import (
+	"sort"
+)
+
+// Capabilities describes features which are supported by RPC adapter
+type Capabilities struct {
+	Binary         bool  `json:"binary"`
+	Batch          bool  `json:"batch"`
+	Cancellation   bool  `json:"cancellation"`
+	Streaming      bool  `json:"streaming"`
+	ServerCalls    bool  `json:"serverCalls"`
+	DefaultTimeout int64 `json:"defaultTimeout"`
+	MaxStrikes     int   `json:"maxStrikes"`
+}
+
+// GetParamsSchema returns schema of params, nil if it is unknown
+func (m *RPCMethod) GetParamsSchema() *Schema {
+
+	if m.ParamsSchema != nil {
+		return m.ParamsSchema
+	}
+
+	if m.ParamsType == nil {
+		return nil
+	}
+
+	return GenerateSchema(m.ParamsType)
+}
+
+// GetResultSchema returns schema of result, nil if it is unknown
+func (m *RPCMethod) GetResultSchema() *Schema {
+
+	if m.ResultType == nil {
+		return nil
+	}
+
+	return GenerateSchema(m.ResultType)
+}
+
+// GetMethods returns registered methods sorted by name
+func (ra *RPCAdapter) GetMethods() []*RPCMethod {
+	return sortMethods(ra.methods)
+}
+
+// GetNotificationMethods returns registered notification handlers sorted by name
+func (ra *RPCAdapter) GetNotificationMethods() []*RPCMethod {
+	return sortMethods(ra.notifications)
+}
+
+func (ra *RPCAdapter) GetMethod(method string) (*RPCMethod, bool) {
+	m, ok := ra.methods[method]
+	return m, ok
+}
+
+func (ra *RPCAdapter) GetCapabilities() *Capabilities {
+	return &Capabilities{
+		Binary:         ra.backend.IsBinary(),
+		Batch:          true,
+		Cancellation:   true,
+		Streaming:      true,
+		ServerCalls:    true,
+		DefaultTimeout: ra.defaultTimeout.Milliseconds(),
+		MaxStrikes:     ra.maxStrikes,
+	}
+}
+
+func sortMethods(methods map[string]*RPCMethod) []*RPCMethod {
+
+	list := make([]*RPCMethod, 0, len(methods))
+	for _, m := range methods {
+		list = append(list, m)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	return list
+}
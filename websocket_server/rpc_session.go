@@ -0,0 +1,164 @@
+package websocket_server
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+type rpcSession struct {
+	mutex    sync.Mutex
+	inflight map[ID]*Context
+	pending  map[ID]chan *RPCResponse
+	strikes  int32
+	closed   bool
+}
+
+func newRPCSession() *rpcSession {
+	return &rpcSession{
+		inflight: make(map[ID]*Context),
+		pending:  make(map[ID]chan *RPCResponse),
+	}
+}
+
+func (s *rpcSession) track(c *Context) {
+	s.mutex.Lock()
+	s.inflight[c.GetRequest().ID] = c
+	s.mutex.Unlock()
+}
+
+func (s *rpcSession) untrack(c *Context) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Request ID might be reused by a newer request
+	if cur, ok := s.inflight[c.GetRequest().ID]; ok && cur == c {
+		delete(s.inflight, c.GetRequest().ID)
+	}
+}
+
+func (s *rpcSession) ack(id ID, seq int64) bool {
+
+	s.mutex.Lock()
+	c, ok := s.inflight[id]
+	s.mutex.Unlock()
+
+	if !ok || c.stream == nil {
+		return false
+	}
+
+	c.stream.ack(seq)
+
+	return true
+}
+
+func (s *rpcSession) strike() int {
+	return int(atomic.AddInt32(&s.strikes, 1))
+}
+
+func (s *rpcSession) abort(id ID) bool {
+
+	s.mutex.Lock()
+	c, ok := s.inflight[id]
+	s.mutex.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	c.abort()
+
+	return true
+}
+
+func (s *rpcSession) close() {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.closed = true
+
+	for id, c := range s.inflight {
+		c.abort()
+		delete(s.inflight, id)
+	}
+
+	// Wake up callers which are waiting for responses
+	for id, ch := range s.pending {
+		close(ch)
+		delete(s.pending, id)
+	}
+}
+
+func (s *rpcSession) await(id ID) (chan *RPCResponse, bool) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return nil, false
+	}
+
+	ch := make(chan *RPCResponse, 1)
+	s.pending[id] = ch
+
+	return ch, true
+}
+
+func (s *rpcSession) cancelAwait(id ID) {
+	s.mutex.Lock()
+	delete(s.pending, id)
+	s.mutex.Unlock()
+}
+
+func (s *rpcSession) resolve(res *RPCResponse) bool {
+
+	s.mutex.Lock()
+	ch, ok := s.pending[res.ID]
+	delete(s.pending, res.ID)
+	s.mutex.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	ch <- res
+
+	return true
+}
+
+func (ra *RPCAdapter) getSession(c Client) *rpcSession {
+
+	ra.sessionMutex.RLock()
+	s, ok := ra.sessions[c.GetClientID()]
+	ra.sessionMutex.RUnlock()
+
+	if ok {
+		return s
+	}
+
+	ra.sessionMutex.Lock()
+	defer ra.sessionMutex.Unlock()
+
+	s, ok = ra.sessions[c.GetClientID()]
+	if !ok {
+		s = newRPCSession()
+		ra.sessions[c.GetClientID()] = s
+	}
+
+	return s
+}
+
+func (ra *RPCAdapter) Release(c Client) {
+
+	ra.sessionMutex.Lock()
+	s, ok := ra.sessions[c.GetClientID()]
+	delete(ra.sessions, c.GetClientID())
+	ra.sessionMutex.Unlock()
+
+	if !ok {
+		return
+	}
+
+	s.close()
+}
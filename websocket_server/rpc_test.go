@@ -0,0 +1,318 @@
+package websocket_server_test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws/wsutil"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/weedbox/websocket-modules/jsonrpc"
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+// peer speaks raw JSON-RPC frames with adapter over in-memory connection
+type peer struct {
+	t    *testing.T
+	conn net.Conn
+}
+
+func newJSONRPCAdapter(opts ...websocket_server.RPCAdapterOpt) *websocket_server.RPCAdapter {
+	opts = append([]websocket_server.RPCAdapterOpt{websocket_server.WithRPCBackend(&jsonrpc.JSONRPC{})}, opts...)
+	return websocket_server.NewRPCAdapter(opts...)
+}
+
+func connect(t *testing.T, ra *websocket_server.RPCAdapter) *peer {
+
+	options := websocket_server.NewOptions()
+	options.Adapter = ra
+
+	conn, end := net.Pipe()
+	c := websocket_server.NewClient(options, conn)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for c.Resume() == nil {
+		}
+		c.Close()
+	}()
+
+	t.Cleanup(func() {
+		end.Close()
+		<-done
+	})
+
+	return &peer{
+		t:    t,
+		conn: end,
+	}
+}
+
+func (p *peer) send(msg string) {
+	if err := wsutil.WriteClientText(p.conn, []byte(msg)); err != nil {
+		p.t.Fatal(err)
+	}
+}
+
+func (p *peer) readText() []byte {
+
+	p.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	data, err := wsutil.ReadServerText(p.conn)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+
+	return data
+}
+
+func (p *peer) read() interface{} {
+
+	data := p.readText()
+
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		p.t.Fatalf("invalid message %s", data)
+	}
+
+	return v
+}
+
+// expect reads the next message which should be equal to the given JSON
+func (p *peer) expect(expected string) {
+
+	p.t.Helper()
+
+	msg := p.read()
+	if !equalJSON(msg, expected) {
+		data, _ := json.Marshal(msg)
+		p.t.Fatalf("unexpected message %s, expected %s", data, expected)
+	}
+}
+
+// expectNothing makes sure that nothing is sent for a while
+func (p *peer) expectNothing() {
+
+	p.t.Helper()
+
+	p.conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+
+	if data, err := wsutil.ReadServerText(p.conn); err == nil {
+		p.t.Fatalf("unexpected message %s", data)
+	}
+}
+
+func equalJSON(v interface{}, expected string) bool {
+
+	var e interface{}
+	if err := json.Unmarshal([]byte(expected), &e); err != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(v, e)
+}
+
+func TestRPCMethodTimeout(t *testing.T) {
+
+	ra := newJSONRPCAdapter(websocket_server.WithRPCTimeout(time.Minute))
+	ra.Register("Slow", func(c *websocket_server.Context) (interface{}, error) {
+		<-c.Done()
+		return nil, c.Err()
+	}, websocket_server.WithMethodTimeout(20*time.Millisecond))
+
+	p := connect(t, ra)
+
+	p.send(`{"jsonrpc":"2.0","id":1,"method":"Slow"}`)
+	p.expect(`{"jsonrpc":"2.0","id":1,"error":{"code":6000,"message":"Request timeout"}}`)
+}
+
+func TestRPCRequestTimeout(t *testing.T) {
+
+	ra := newJSONRPCAdapter(websocket_server.WithRPCTimeout(time.Minute))
+	ra.Register("Slow", func(c *websocket_server.Context) (interface{}, error) {
+		// Handler which ignores context is abandoned
+		time.Sleep(time.Second)
+		return true, nil
+	})
+
+	p := connect(t, ra)
+
+	// Client is allowed to shorten deadline
+	p.send(`{"jsonrpc":"2.0","id":"a","method":"Slow","timeout":20}`)
+	p.expect(`{"jsonrpc":"2.0","id":"a","error":{"code":6000,"message":"Request timeout"}}`)
+}
+
+func TestRPCCancelOnDisconnect(t *testing.T) {
+
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+
+	ra := newJSONRPCAdapter()
+	ra.Register("Wait", func(c *websocket_server.Context) (interface{}, error) {
+		close(started)
+		<-c.Done()
+		close(cancelled)
+		return nil, c.Err()
+	})
+
+	p := connect(t, ra)
+
+	p.send(`{"jsonrpc":"2.0","id":1,"method":"Wait"}`)
+	<-started
+
+	p.conn.Close()
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("context was not canceled after disconnection")
+	}
+}
+
+func TestRPCCancelRequest(t *testing.T) {
+
+	ra := newJSONRPCAdapter()
+	ra.Register("Slow", func(c *websocket_server.Context) (interface{}, error) {
+		<-c.Done()
+		return nil, c.Err()
+	})
+
+	p := connect(t, ra)
+
+	p.send(`{"jsonrpc":"2.0","id":1,"method":"Slow"}`)
+	p.send(`{"jsonrpc":"2.0","method":"$/cancelRequest","params":{"id":1}}`)
+	p.expect(`{"jsonrpc":"2.0","id":1,"error":{"code":6001,"message":"Request cancelled"}}`)
+
+	// Request which is unknown is unable to be cancelled
+	p.send(`{"jsonrpc":"2.0","id":2,"method":"$/cancelRequest","params":[99]}`)
+	p.expect(`{"jsonrpc":"2.0","id":2,"result":false}`)
+
+	p.send(`{"jsonrpc":"2.0","id":3,"method":"$/cancelRequest","params":{}}`)
+	p.expect(`{"jsonrpc":"2.0","id":3,"error":{"code":-32602,"message":"Insufficient arguments"}}`)
+
+	p.send(`{"jsonrpc":"2.0","id":4,"method":"$/cancelRequest","params":{"id":null}}`)
+	p.expect(`{"jsonrpc":"2.0","id":4,"error":{"code":-32602,"message":"Invalid arguments"}}`)
+}
+
+func TestRPCCancelRequestByStringID(t *testing.T) {
+
+	started := make(chan struct{})
+
+	ra := newJSONRPCAdapter()
+	ra.Register("Slow", func(c *websocket_server.Context) (interface{}, error) {
+		close(started)
+		<-c.Done()
+		return nil, c.Err()
+	})
+
+	p := connect(t, ra)
+
+	p.send(`{"jsonrpc":"2.0","id":"job","method":"Slow"}`)
+	<-started
+
+	p.send(`{"jsonrpc":"2.0","id":1,"method":"$/cancelRequest","params":{"id":"job"}}`)
+
+	// Responses are sent by different goroutines
+	expected := map[string]string{
+		"job": `{"jsonrpc":"2.0","id":"job","error":{"code":6001,"message":"Request cancelled"}}`,
+		"1":   `{"jsonrpc":"2.0","id":1,"result":true}`,
+	}
+
+	for i := 0; i < 2; i++ {
+		msg := p.read().(map[string]interface{})
+		key := fmt.Sprint(msg["id"])
+		if !equalJSON(msg, expected[key]) {
+			t.Fatalf("unexpected message %v", msg)
+		}
+
+		delete(expected, key)
+	}
+}
+
+func TestRPCNotification(t *testing.T) {
+
+	received := make(chan string, 3)
+
+	ra := newJSONRPCAdapter()
+	ra.Register("Echo", func(c *websocket_server.Context) (interface{}, error) {
+		received <- "Echo"
+		return c.Param(0), nil
+	})
+	ra.Register("Fail", func(c *websocket_server.Context) (interface{}, error) {
+		received <- "Fail"
+		return nil, errors.New("failed")
+	})
+	ra.RegisterNotification("Log", func(c *websocket_server.Context) error {
+		received <- "Log"
+		return nil
+	})
+
+	p := connect(t, ra)
+
+	// Nothing is sent back for notifications even if they failed
+	p.send(`{"jsonrpc":"2.0","method":"Echo","params":[1]}`)
+	p.send(`{"jsonrpc":"2.0","method":"Fail"}`)
+	p.send(`{"jsonrpc":"2.0","method":"Log"}`)
+	p.send(`{"jsonrpc":"2.0","method":"Nope"}`)
+	p.send(`{"jsonrpc":"2.0","method":"Echo","params":"invalid"}`)
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-received:
+		case <-time.After(time.Second):
+			t.Fatal("notification was not handled")
+		}
+	}
+
+	p.expectNothing()
+
+	// Notification handlers are not callable by requests
+	p.send(`{"jsonrpc":"2.0","id":1,"method":"Log"}`)
+	p.expect(`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"Method not found"}}`)
+}
+
+func TestRPCMalformed(t *testing.T) {
+
+	p := connect(t, newJSONRPCAdapter())
+
+	cases := []struct {
+		msg      string
+		expected string
+	}{
+		{`{"jsonrpc":"2.0","id":1,`, `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"Parse error"}}`},
+		{`  `, `{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"Parse error"}}`},
+		{`{"jsonrpc":"2.0","id":1}`, `{"jsonrpc":"2.0","id":1,"error":{"code":-32600,"message":"Invalid Request","data":"method is required"}}`},
+		{`{"jsonrpc":"1.0","id":2,"method":"m"}`, `{"jsonrpc":"2.0","id":2,"error":{"code":-32600,"message":"Invalid Request","data":"unsupported jsonrpc version"}}`},
+		{`{"jsonrpc":"2.0","id":3,"method":1}`, `{"jsonrpc":"2.0","id":3,"error":{"code":-32600,"message":"Invalid Request","data":"invalid request object"}}`},
+		{`{"jsonrpc":"2.0","id":4,"method":"m","params":5}`, `{"jsonrpc":"2.0","id":4,"error":{"code":-32600,"message":"Invalid Request","data":"params must be an array or object"}}`},
+		{`"x"`, `{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"Invalid Request","data":"invalid request object"}}`},
+	}
+
+	for _, c := range cases {
+		p.send(c.msg)
+		p.expect(c.expected)
+	}
+}
+
+func TestRPCMaxStrikes(t *testing.T) {
+
+	p := connect(t, newJSONRPCAdapter(websocket_server.WithRPCMaxStrikes(2)))
+
+	p.send(`{`)
+	p.expect(`{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"Parse error"}}`)
+
+	// Client is disconnected after the last error response
+	p.send(`{`)
+	p.expect(`{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"Parse error"}}`)
+
+	p.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := wsutil.ReadServerText(p.conn); err == nil {
+		t.Fatal("client was not disconnected")
+	}
+}
@@ -0,0 +1,45 @@
+package websocket_server
+
+import (
+	"reflect"
+)
+
+type TypedRPCFunc[P any, R any] func(*Context, P) (R, error)
+
+func WithMethodTypes(params reflect.Type, result reflect.Type) RPCMethodOpt {
+	return func(m *RPCMethod) {
+		m.ParamsType = params
+		m.ResultType = result
+	}
+}
+
+// RegisterTyped registers a handler whose params are decoded and validated into P automatically.
+// Positional params are bound to struct fields in declaration order, named params by JSON field name.
+func RegisterTyped[P any, R any](a Adapter, method string, fn TypedRPCFunc[P, R], opts ...RPCMethodOpt) error {
+
+	paramsType := reflect.TypeOf((*P)(nil)).Elem()
+	resultType := reflect.TypeOf((*R)(nil)).Elem()
+
+	handler := func(c *Context) (interface{}, error) {
+
+		var params P
+
+		// Allocate underlying value if params is a pointer
+		target := interface{}(&params)
+		if paramsType.Kind() == reflect.Ptr {
+			v := reflect.New(paramsType.Elem())
+			reflect.ValueOf(&params).Elem().Set(v)
+			target = params
+		}
+
+		if err := BindParams(c.GetRequest(), target); err != nil {
+			return nil, err
+		}
+
+		return fn(c, params)
+	}
+
+	opts = append([]RPCMethodOpt{WithMethodTypes(paramsType, resultType)}, opts...)
+
+	return a.Register(method, handler, opts...)
+}
@@ -0,0 +1,142 @@
+package websocket_server
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Schema is a subset of JSON Schema which is able to describe params and results of handlers
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+var (
+	timeType  = reflect.TypeOf(time.Time{})
+	bytesType = reflect.TypeOf([]byte(nil))
+)
+
+// GenerateSchema derives schema from Go type by JSON field names, fields with "required" validation
+// are listed as required and "oneof" becomes enum. Description is read from "description" tag.
+func GenerateSchema(t reflect.Type) *Schema {
+
+	if t == nil {
+		return &Schema{}
+	}
+
+	return generateSchema(t, make(map[reflect.Type]bool))
+}
+
+func generateSchema(t reflect.Type, visiting map[reflect.Type]bool) *Schema {
+
+	t = indirectType(t)
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case bytesType:
+		return &Schema{Type: "string", Format: "byte"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: generateSchema(t.Elem(), visiting)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: generateSchema(t.Elem(), visiting)}
+	case reflect.Struct:
+
+		// Recursive types are not expanded again
+		if visiting[t] {
+			return &Schema{Type: "object"}
+		}
+
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		s := &Schema{
+			Type:       "object",
+			Properties: make(map[string]*Schema),
+		}
+
+		addProperties(s, t, visiting)
+		sort.Strings(s.Required)
+
+		return s
+	}
+
+	// Interfaces accept any value
+	return &Schema{}
+}
+
+func addProperties(s *Schema, t reflect.Type, visiting map[reflect.Type]bool) {
+
+	for i := 0; i < t.NumField(); i++ {
+
+		f := t.Field(i)
+
+		// Fields of embedded struct are promoted like encoding/json does
+		if f.Anonymous && len(f.Tag.Get("json")) == 0 && indirectType(f.Type).Kind() == reflect.Struct {
+			addProperties(s, indirectType(f.Type), visiting)
+			continue
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+
+		name := fieldName(f)
+		if len(name) == 0 {
+			continue
+		}
+
+		fs := generateSchema(f.Type, visiting)
+		fs.Description = f.Tag.Get("description")
+
+		for _, rule := range strings.Split(f.Tag.Get("validate"), ",") {
+			switch {
+			case rule == "required":
+				s.Required = append(s.Required, name)
+			case strings.HasPrefix(rule, "oneof="):
+				fs.Enum = enumValues(fs.Type, strings.Fields(strings.TrimPrefix(rule, "oneof=")))
+			}
+		}
+
+		s.Properties[name] = fs
+	}
+}
+
+func enumValues(typ string, values []string) []interface{} {
+
+	enum := make([]interface{}, 0, len(values))
+	for _, v := range values {
+
+		if typ == "integer" || typ == "number" {
+			var n interface{}
+			if err := json.Unmarshal([]byte(v), &n); err == nil {
+				enum = append(enum, n)
+				continue
+			}
+		}
+
+		enum = append(enum, v)
+	}
+
+	return enum
+}
@@ -0,0 +1,67 @@
+package websocket_server_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+type node struct {
+	Base
+	Name     string            `json:"name" description:"Name of node"`
+	Kind     string            `json:"kind" validate:"required,oneof=leaf branch"`
+	Weight   int               `json:"weight" validate:"oneof=1 2"`
+	Children []*node           `json:"children,omitempty"`
+	Labels   map[string]string `json:"labels"`
+	Created  time.Time         `json:"created"`
+	Raw      []byte            `json:"raw"`
+	Extra    interface{}       `json:"extra"`
+	Ignored  string            `json:"-"`
+	hidden   string
+}
+
+func TestGenerateSchema(t *testing.T) {
+
+	s := websocket_server.GenerateSchema(reflect.TypeOf(&node{}))
+
+	if s.Type != "object" || !reflect.DeepEqual(s.Required, []string{"id", "kind"}) {
+		t.Fatalf("unexpected schema %+v", s)
+	}
+
+	names := make([]string, 0)
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+
+	if len(names) != 9 || s.Properties["Ignored"] != nil || s.Properties["hidden"] != nil {
+		t.Fatalf("unexpected properties %v", names)
+	}
+
+	cases := map[string]*websocket_server.Schema{
+		"id":      {Type: "string"},
+		"name":    {Type: "string", Description: "Name of node"},
+		"kind":    {Type: "string", Enum: []interface{}{"leaf", "branch"}},
+		"weight":  {Type: "integer", Enum: []interface{}{float64(1), float64(2)}},
+		"labels":  {Type: "object", AdditionalProperties: &websocket_server.Schema{Type: "string"}},
+		"created": {Type: "string", Format: "date-time"},
+		"raw":     {Type: "string", Format: "byte"},
+		"extra":   {},
+	}
+
+	for name, expected := range cases {
+		if !reflect.DeepEqual(s.Properties[name], expected) {
+			t.Fatalf("%s: unexpected schema %+v", name, s.Properties[name])
+		}
+	}
+
+	// Recursive type is not expanded again
+	if children := s.Properties["children"]; children.Type != "array" || !reflect.DeepEqual(children.Items, &websocket_server.Schema{Type: "object"}) {
+		t.Fatalf("unexpected children %+v", children)
+	}
+
+	if s := websocket_server.GenerateSchema(nil); !reflect.DeepEqual(s, &websocket_server.Schema{}) {
+		t.Fatalf("unexpected schema %+v", s)
+	}
+}
@@ -0,0 +1,133 @@
+package websocket_server
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	StreamChunkEvent = "$/stream"
+	StreamAckMethod  = "$/streamAck"
+)
+
+var (
+	ErrStreamUnavailable = errors.New("stream: unavailable")
+	ErrStreamClosed      = errors.New("stream: closed")
+)
+
+// StreamChunk is sent to client as notification for each partial result
+type StreamChunk struct {
+	ID   ID          `json:"id"`
+	Seq  int64       `json:"seq"`
+	Data interface{} `json:"data"`
+}
+
+// Stream emits partial results of a request, the final result is the value returned by handler.
+// If window is set, client must acknowledge received chunks with $/streamAck, otherwise sending
+// will be blocked once there are too many unacknowledged chunks.
+type Stream struct {
+	acked int64
+
+	// seq is written with mutex held, and read by ack without it
+	seq int64
+
+	ctx    *Context
+	window int64
+	mutex  sync.Mutex
+	ackCh  chan struct{}
+	closed bool
+}
+
+func newStream(c *Context, window int) *Stream {
+	return &Stream{
+		ctx:    c,
+		window: int64(window),
+		ackCh:  make(chan struct{}, 1),
+	}
+}
+
+func WithStreamWindow(window int) RPCMethodOpt {
+	return func(m *RPCMethod) {
+		m.StreamWindow = window
+	}
+}
+
+func (s *Stream) Send(data interface{}) error {
+
+	if s == nil {
+		return ErrStreamUnavailable
+	}
+
+	s.mutex.Lock()
+
+	// Wait for credits
+	for !s.closed && s.window > 0 && atomic.LoadInt64(&s.seq)-atomic.LoadInt64(&s.acked) >= s.window {
+		s.mutex.Unlock()
+
+		select {
+		case <-s.ackCh:
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		}
+
+		s.mutex.Lock()
+	}
+
+	if s.closed {
+		s.mutex.Unlock()
+		return ErrStreamClosed
+	}
+
+	defer s.mutex.Unlock()
+
+	chunk := &StreamChunk{
+		ID:   s.ctx.GetRequest().ID,
+		Seq:  atomic.AddInt64(&s.seq, 1),
+		Data: data,
+	}
+
+	return s.ctx.Notify(StreamChunkEvent, chunk)
+}
+
+// Sent returns the number of chunks which were emitted
+func (s *Stream) Sent() int64 {
+
+	if s == nil {
+		return 0
+	}
+
+	return atomic.LoadInt64(&s.seq)
+}
+
+func (s *Stream) ack(seq int64) {
+
+	// Chunks which were not sent yet are unable to be acknowledged
+	if sent := atomic.LoadInt64(&s.seq); seq > sent {
+		seq = sent
+	}
+
+	// Acknowledgement never blocks poller even if stream is sending
+	for {
+		acked := atomic.LoadInt64(&s.acked)
+		if seq <= acked || atomic.CompareAndSwapInt64(&s.acked, acked, seq) {
+			break
+		}
+	}
+
+	select {
+	case s.ackCh <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Stream) close() {
+
+	if s == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	s.closed = true
+	s.mutex.Unlock()
+}
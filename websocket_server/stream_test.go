@@ -0,0 +1,163 @@
+package websocket_server_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+func (p *peer) expectChunk(id string, seq int, data string) {
+	p.t.Helper()
+	p.expect(fmt.Sprintf(`{"jsonrpc":"2.0","method":"$/stream","params":{"id":%s,"seq":%d,"data":%s}}`, id, seq, data))
+}
+
+func TestStreamFlowControl(t *testing.T) {
+
+	ra := newJSONRPCAdapter()
+	ra.RegisterStream("Count", func(c *websocket_server.Context) (interface{}, error) {
+		for i := 0; i < 5; i++ {
+			if err := c.GetStream().Send(i); err != nil {
+				return nil, err
+			}
+		}
+
+		return c.GetStream().Sent(), nil
+	}, websocket_server.WithStreamWindow(2))
+
+	p := connect(t, ra)
+
+	p.send(`{"jsonrpc":"2.0","id":1,"method":"Count"}`)
+	p.expectChunk("1", 1, "0")
+	p.expectChunk("1", 2, "1")
+
+	// Sending is blocked until chunks are acknowledged
+	p.expectNothing()
+
+	p.send(`{"jsonrpc":"2.0","method":"$/streamAck","params":{"id":1,"seq":2}}`)
+	p.expectChunk("1", 3, "2")
+	p.expectChunk("1", 4, "3")
+	p.expectNothing()
+
+	// Acknowledgement which is outdated gives no more credits
+	p.send(`{"jsonrpc":"2.0","method":"$/streamAck","params":[1,1]}`)
+	p.expectNothing()
+
+	p.send(`{"jsonrpc":"2.0","method":"$/streamAck","params":[1,3]}`)
+	p.expectChunk("1", 5, "4")
+	p.expect(`{"jsonrpc":"2.0","id":1,"result":5}`)
+
+	// Stream which is completed is unable to be acknowledged
+	p.send(`{"jsonrpc":"2.0","id":2,"method":"$/streamAck","params":{"id":1,"seq":5}}`)
+	p.expect(`{"jsonrpc":"2.0","id":2,"result":false}`)
+
+	p.send(`{"jsonrpc":"2.0","id":3,"method":"$/streamAck","params":{"id":1}}`)
+	p.expect(`{"jsonrpc":"2.0","id":3,"error":{"code":-32602,"message":"Invalid arguments"}}`)
+}
+
+func TestStreamAckBeyondSent(t *testing.T) {
+
+	ra := newJSONRPCAdapter()
+	ra.RegisterStream("Count", func(c *websocket_server.Context) (interface{}, error) {
+		for i := 0; i < 5; i++ {
+			if err := c.GetStream().Send(i); err != nil {
+				return nil, err
+			}
+		}
+
+		return c.GetStream().Sent(), nil
+	}, websocket_server.WithStreamWindow(2))
+
+	p := connect(t, ra)
+
+	p.send(`{"jsonrpc":"2.0","id":1,"method":"Count"}`)
+	p.expectChunk("1", 1, "0")
+	p.expectChunk("1", 2, "1")
+
+	// Chunks which were not sent give no credits
+	p.send(`{"jsonrpc":"2.0","method":"$/streamAck","params":{"id":1,"seq":100}}`)
+	p.expectChunk("1", 3, "2")
+	p.expectChunk("1", 4, "3")
+	p.expectNothing()
+
+	p.send(`{"jsonrpc":"2.0","method":"$/streamAck","params":{"id":1,"seq":4}}`)
+	p.expectChunk("1", 5, "4")
+	p.expect(`{"jsonrpc":"2.0","id":1,"result":5}`)
+}
+
+func TestStreamWithoutWindow(t *testing.T) {
+
+	ra := newJSONRPCAdapter()
+	ra.RegisterStream("Count", func(c *websocket_server.Context) (interface{}, error) {
+		for i := 0; i < 3; i++ {
+			if err := c.GetStream().Send(i); err != nil {
+				return nil, err
+			}
+		}
+
+		return "done", nil
+	})
+
+	p := connect(t, ra)
+
+	p.send(`{"jsonrpc":"2.0","id":"s","method":"Count"}`)
+	p.expectChunk(`"s"`, 1, "0")
+	p.expectChunk(`"s"`, 2, "1")
+	p.expectChunk(`"s"`, 3, "2")
+	p.expect(`{"jsonrpc":"2.0","id":"s","result":"done"}`)
+}
+
+func TestStreamCancelled(t *testing.T) {
+
+	result := make(chan error, 1)
+
+	ra := newJSONRPCAdapter()
+	ra.RegisterStream("Count", func(c *websocket_server.Context) (interface{}, error) {
+		for {
+			if err := c.GetStream().Send(0); err != nil {
+				result <- err
+				return nil, err
+			}
+		}
+	}, websocket_server.WithStreamWindow(1))
+
+	p := connect(t, ra)
+
+	p.send(`{"jsonrpc":"2.0","id":1,"method":"Count"}`)
+	p.expectChunk("1", 1, "0")
+
+	// Sender which is waiting for credits is released
+	p.send(`{"jsonrpc":"2.0","method":"$/cancelRequest","params":{"id":1}}`)
+	p.expect(`{"jsonrpc":"2.0","id":1,"error":{"code":6001,"message":"Request cancelled"}}`)
+
+	if err := <-result; err == nil {
+		t.Fatal("stream was not interrupted")
+	}
+}
+
+func TestStreamUnavailable(t *testing.T) {
+
+	ra := newJSONRPCAdapter()
+	ra.Register("Plain", func(c *websocket_server.Context) (interface{}, error) {
+		return errors.Is(c.GetStream().Send(1), websocket_server.ErrStreamUnavailable), nil
+	})
+
+	// Notifications have nobody to receive chunks
+	result := make(chan error, 1)
+	ra.RegisterStream("Count", func(c *websocket_server.Context) (interface{}, error) {
+		err := c.GetStream().Send(1)
+		result <- err
+		return nil, err
+	})
+
+	p := connect(t, ra)
+
+	p.send(`{"jsonrpc":"2.0","id":1,"method":"Plain"}`)
+	p.expect(`{"jsonrpc":"2.0","id":1,"result":true}`)
+
+	p.send(`{"jsonrpc":"2.0","method":"Count"}`)
+	if err := <-result; !errors.Is(err, websocket_server.ErrStreamUnavailable) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -2,6 +2,8 @@ package websocket_server
 
 import (
 	"context"
+	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/weedbox/common-modules/http_server"
@@ -9,7 +11,8 @@ import (
 	"go.uber.org/zap"
 )
 
-var logger *zap.Logger
+// Replaced by module logger, adapters are usable without module in tests
+var logger = zap.NewNop()
 
 type WebSocketServer struct {
 	params    Params
@@ -84,6 +87,13 @@ func (wss *WebSocketServer) CreateEndpoint(uri string, opts *Options) (*Endpoint
 		ep.Establish(c)
 	})
 
+	// OpenRPC document is served next to endpoint
+	if ra, ok := opts.Adapter.(*RPCAdapter); ok {
+		wss.params.HTTPServer.GetRouter().GET(strings.TrimSuffix(uri, "/")+"/openrpc.json", func(c *gin.Context) {
+			c.JSON(http.StatusOK, ra.GenerateOpenRPC())
+		})
+	}
+
 	wss.endpoints[uri] = ep
 
 	return ep, nil
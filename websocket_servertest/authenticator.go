@@ -0,0 +1,120 @@
+package websocket_servertest
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/weedbox/websocket-modules/auth_rpc"
+)
+
+// Authenticator accepts tokens which were added or generated, and counts attempts
+type Authenticator struct {
+	mutex    sync.Mutex
+	tokens   map[string]map[string]interface{}
+	seq      int
+	attempts int
+}
+
+func NewAuthenticator() *Authenticator {
+	return &Authenticator{
+		tokens: make(map[string]map[string]interface{}),
+	}
+}
+
+// Add accepts token which authenticates client with data
+func (a *Authenticator) Add(token string, data map[string]interface{}) {
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.tokens[token] = data
+}
+
+func (a *Authenticator) Remove(token string) {
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	delete(a.tokens, token)
+}
+
+func (a *Authenticator) Authenticate(token string) (*auth_rpc.AuthenticationInfo, error) {
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.attempts++
+
+	data, ok := a.tokens[token]
+	if !ok {
+		return nil, auth_rpc.ErrInvalidToken
+	}
+
+	info := &auth_rpc.AuthenticationInfo{
+		Data: make(map[string]interface{}, len(data)),
+	}
+
+	for k, v := range data {
+		info.Data[k] = v
+	}
+
+	return info, nil
+}
+
+func (a *Authenticator) GenerateToken(info *auth_rpc.AuthenticationInfo) (string, error) {
+
+	a.mutex.Lock()
+	a.seq++
+	token := fmt.Sprintf("token-%d", a.seq)
+	a.mutex.Unlock()
+
+	var data map[string]interface{}
+	if info != nil {
+		data = info.Data
+	}
+
+	a.Add(token, data)
+
+	return token, nil
+}
+
+// GetAttempts returns the number of times Authenticate was called
+func (a *Authenticator) GetAttempts() int {
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return a.attempts
+}
+
+// AuthenticatorFunc turns function into authenticator which is unable to generate tokens
+type AuthenticatorFunc func(token string) (*auth_rpc.AuthenticationInfo, error)
+
+func (fn AuthenticatorFunc) Authenticate(token string) (*auth_rpc.AuthenticationInfo, error) {
+	return fn(token)
+}
+
+func (fn AuthenticatorFunc) GenerateToken(info *auth_rpc.AuthenticationInfo) (string, error) {
+	return "", ErrNotSupported
+}
+
+// AllowAll accepts any non-empty token with the same data
+func AllowAll(data map[string]interface{}) AuthenticatorFunc {
+	return func(token string) (*auth_rpc.AuthenticationInfo, error) {
+
+		if len(token) == 0 {
+			return nil, auth_rpc.ErrInvalidToken
+		}
+
+		return &auth_rpc.AuthenticationInfo{
+			Data: data,
+		}, nil
+	}
+}
+
+// DenyAll rejects every token
+func DenyAll() AuthenticatorFunc {
+	return func(token string) (*auth_rpc.AuthenticationInfo, error) {
+		return nil, auth_rpc.ErrInvalidToken
+	}
+}
@@ -0,0 +1,77 @@
+package websocket_servertest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/weedbox/websocket-modules/auth_rpc"
+)
+
+func TestAuthenticator(t *testing.T) {
+
+	a := NewAuthenticator()
+	a.Add("good", map[string]interface{}{"user": "bob"})
+
+	info, err := a.Authenticate("good")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Data["user"] != "bob" {
+		t.Fatalf("unexpected data %v", info.Data)
+	}
+
+	if _, err := a.Authenticate("bad"); !errors.Is(err, auth_rpc.ErrInvalidToken) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	token, err := a.GenerateToken(&auth_rpc.AuthenticationInfo{Data: map[string]interface{}{"user": "carol"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err = a.Authenticate(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Data["user"] != "carol" {
+		t.Fatalf("unexpected data %v", info.Data)
+	}
+
+	a.Remove(token)
+
+	if _, err := a.Authenticate(token); !errors.Is(err, auth_rpc.ErrInvalidToken) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if a.GetAttempts() != 4 {
+		t.Fatalf("unexpected attempts %d", a.GetAttempts())
+	}
+}
+
+func TestAuthenticatorFunc(t *testing.T) {
+
+	allow := AllowAll(map[string]interface{}{"user": "any"})
+
+	if _, err := allow.Authenticate(""); !errors.Is(err, auth_rpc.ErrInvalidToken) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	info, err := allow.Authenticate("x")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Data["user"] != "any" {
+		t.Fatalf("unexpected data %v", info.Data)
+	}
+
+	if _, err := DenyAll().Authenticate("x"); !errors.Is(err, auth_rpc.ErrInvalidToken) {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if _, err := allow.GenerateToken(nil); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
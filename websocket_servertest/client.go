@@ -0,0 +1,386 @@
+package websocket_servertest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/gobwas/ws"
+	"github.com/google/uuid"
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+var (
+	ErrNotRPCAdapter = errors.New("servertest: adapter is not RPC adapter")
+	ErrNotSupported  = errors.New("servertest: not supported")
+)
+
+type ClientOpt func(*Client)
+
+// CallHandler answers calls which are initiated by server with Client.Call
+type CallHandler func(method string, params interface{}) (interface{}, error)
+
+type Notification struct {
+	Event   string
+	Payload interface{}
+}
+
+type CloseStatus struct {
+	Code   ws.StatusCode
+	Reason string
+}
+
+// Client is a fake client without socket, it records everything sent by server and feeds
+// messages to adapter as if they were received from connection.
+type Client struct {
+	seq int64
+
+	options     *websocket_server.Options
+	backend     websocket_server.Backend
+	id          uuid.UUID
+	meta        *websocket_server.Metadata
+	ctx         context.Context
+	cancel      context.CancelFunc
+	conn        net.Conn
+	peer        net.Conn
+	reader      io.Reader
+	runners     []*websocket_server.Runner
+	callHandler CallHandler
+	closeOnce   sync.Once
+
+	// Messages are handled one by one like the poller does
+	receiveMutex sync.Mutex
+
+	mutex         sync.Mutex
+	frames        [][]byte
+	notifications []*Notification
+	closeStatus   *CloseStatus
+	pending       map[websocket_server.ID]*invocation
+}
+
+func WithMeta(key string, value interface{}) ClientOpt {
+	return func(c *Client) {
+		c.meta.Set(key, value)
+	}
+}
+
+func WithCallHandler(fn CallHandler) ClientOpt {
+	return func(c *Client) {
+		c.callHandler = fn
+	}
+}
+
+func WithOnMessage(fn func(websocket_server.Client) error) ClientOpt {
+	return func(c *Client) {
+		c.options.OnMessage = fn
+	}
+}
+
+func NewClient(adapter websocket_server.Adapter, opts ...ClientOpt) *Client {
+
+	options := websocket_server.NewOptions()
+	options.Adapter = adapter
+
+	c := &Client{
+		options: options,
+		id:      uuid.New(),
+		meta:    websocket_server.NewMetadata(),
+		runners: make([]*websocket_server.Runner, 0),
+		reader:  bytes.NewReader(nil),
+		pending: make(map[websocket_server.ID]*invocation),
+	}
+
+	if ra, ok := adapter.(*websocket_server.RPCAdapter); ok {
+		c.backend = ra.GetBackend()
+	}
+
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+
+	// Adapters which close connection directly expect the client to be cleaned up
+	c.conn, c.peer = net.Pipe()
+	go func() {
+		io.Copy(io.Discard, c.peer)
+		c.Close()
+	}()
+
+	for _, o := range opts {
+		o(c)
+	}
+
+	if a, ok := adapter.(websocket_server.Attacher); ok {
+		a.Attach(c)
+	}
+
+	return c
+}
+
+func (c *Client) GetOptions() *websocket_server.Options {
+	return c.options
+}
+
+func (c *Client) GetContext() context.Context {
+	return c.ctx
+}
+
+func (c *Client) GetConnection() net.Conn {
+	return c.conn
+}
+
+func (c *Client) GetClientID() uuid.UUID {
+	return c.id
+}
+
+func (c *Client) GetMeta() *websocket_server.Metadata {
+	return c.meta
+}
+
+func (c *Client) GetReader() io.Reader {
+	return c.reader
+}
+
+func (c *Client) Send(data []byte) error {
+
+	if c.ctx.Err() != nil {
+		return websocket_server.ErrConnectionClosed
+	}
+
+	frame := make([]byte, len(data))
+	copy(frame, data)
+
+	c.mutex.Lock()
+	c.frames = append(c.frames, frame)
+	c.mutex.Unlock()
+
+	if c.backend != nil {
+		c.dispatch(frame)
+	}
+
+	return nil
+}
+
+func (c *Client) Respond(res *websocket_server.RPCResponse) error {
+
+	data, err := c.options.Adapter.PrepareResponse(res)
+	if err != nil {
+		return err
+	}
+
+	return c.Send(data)
+}
+
+func (c *Client) Notify(eventName string, payload interface{}) error {
+
+	if c.ctx.Err() != nil {
+		return websocket_server.ErrConnectionClosed
+	}
+
+	c.mutex.Lock()
+	c.notifications = append(c.notifications, &Notification{
+		Event:   eventName,
+		Payload: payload,
+	})
+	c.mutex.Unlock()
+
+	if chunk, ok := payload.(*websocket_server.StreamChunk); ok {
+		c.receiveChunk(chunk)
+	}
+
+	data, err := c.options.Adapter.PrepareNotification(eventName, payload)
+	if err != nil {
+		return err
+	}
+
+	return c.Send(data)
+}
+
+func (c *Client) Call(ctx context.Context, method string, params interface{}) (interface{}, error) {
+	return c.options.Adapter.Call(ctx, c, method, params)
+}
+
+// Resume is driven by poller which does not exist for fake client, use Receive instead
+func (c *Client) Resume() error {
+	return c.options.Adapter.HandleMessage(c)
+}
+
+func (c *Client) CreateRunner(fn func(*websocket_server.Runner)) *websocket_server.Runner {
+	r := websocket_server.NewRunner(fn)
+	c.runners = append(c.runners, r)
+	return r
+}
+
+func (c *Client) Release() {
+
+	for _, r := range c.runners {
+		r.Stop()
+	}
+
+	c.runners = make([]*websocket_server.Runner, 0)
+}
+
+func (c *Client) Close() {
+	c.closeOnce.Do(func() {
+		c.cancel()
+		c.Release()
+		c.options.Adapter.Release(c)
+		c.conn.Close()
+		c.peer.Close()
+	})
+}
+
+func (c *Client) CloseWithStatus(code ws.StatusCode, reason string) error {
+
+	c.mutex.Lock()
+	if c.closeStatus == nil {
+		c.closeStatus = &CloseStatus{
+			Code:   code,
+			Reason: reason,
+		}
+	}
+	c.mutex.Unlock()
+
+	c.Close()
+
+	return nil
+}
+
+// Receive hands message to adapter as if it was received from connection
+func (c *Client) Receive(data []byte) error {
+
+	if c.ctx.Err() != nil {
+		return websocket_server.ErrConnectionClosed
+	}
+
+	c.receiveMutex.Lock()
+	defer c.receiveMutex.Unlock()
+
+	c.reader = bytes.NewReader(data)
+
+	return c.options.Adapter.HandleMessage(c)
+}
+
+func (c *Client) IsClosed() bool {
+	return c.ctx.Err() != nil
+}
+
+// GetCloseStatus returns status sent by CloseWithStatus, nil if client was closed without status
+func (c *Client) GetCloseStatus() *CloseStatus {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.closeStatus
+}
+
+// GetFrames returns all messages sent to client including responses
+func (c *Client) GetFrames() [][]byte {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	frames := make([][]byte, len(c.frames))
+	copy(frames, c.frames)
+
+	return frames
+}
+
+// GetNotifications returns notifications with their original payloads in the order they were sent
+func (c *Client) GetNotifications() []*Notification {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	notifications := make([]*Notification, len(c.notifications))
+	copy(notifications, c.notifications)
+
+	return notifications
+}
+
+// GetNotificationsByEvent returns payloads of specific event
+func (c *Client) GetNotificationsByEvent(eventName string) []interface{} {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	payloads := make([]interface{}, 0)
+	for _, n := range c.notifications {
+		if n.Event == eventName {
+			payloads = append(payloads, n.Payload)
+		}
+	}
+
+	return payloads
+}
+
+// Reset clears recorded frames and notifications
+func (c *Client) Reset() {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.frames = nil
+	c.notifications = nil
+}
+
+func (c *Client) dispatch(data []byte) {
+
+	req, err := c.backend.ParseRequest(bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+
+	if len(req.Batch) > 0 {
+		for _, r := range req.Batch {
+			c.dispatchRequest(r)
+		}
+
+		return
+	}
+
+	c.dispatchRequest(req)
+}
+
+func (c *Client) dispatchRequest(req *websocket_server.RPCRequest) {
+
+	if req.Response != nil {
+		c.resolve(req.Response)
+		return
+	}
+
+	// Notifications are recorded by Notify
+	if req.Notification || req.Error != nil {
+		return
+	}
+
+	// Server is waiting for response in the same goroutine which is sending the request
+	go c.answer(req)
+}
+
+func (c *Client) answer(req *websocket_server.RPCRequest) {
+
+	res := &websocket_server.RPCResponse{
+		ID: req.ID,
+	}
+
+	params := req.Params
+	if req.HasNamedParams() {
+		params = req.NamedParams
+	}
+
+	if c.callHandler == nil {
+		res.Error = websocket_server.NewError(websocket_server.ErrorCode_NotFound, nil)
+	} else if result, err := c.callHandler(req.Method, params); err != nil {
+		res.Error = websocket_server.ToRPCError(err)
+	} else {
+		res.Result = result
+	}
+
+	data, err := c.backend.PrepareResponse(res)
+	if err != nil {
+		return
+	}
+
+	c.Receive(data)
+}
@@ -0,0 +1,176 @@
+package websocket_servertest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/weedbox/websocket-modules/jsonrpc"
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+type addParams struct {
+	A int `json:"a" validate:"required"`
+	B int `json:"b"`
+}
+
+type tickEvent struct {
+	N int `json:"n"`
+}
+
+func setup() *websocket_server.RPCAdapter {
+
+	ra := websocket_server.NewRPCAdapter(websocket_server.WithRPCBackend(&jsonrpc.JSONRPC{}))
+
+	websocket_server.RegisterTyped(ra, "Math.Add", func(c *websocket_server.Context, p *addParams) (int, error) {
+		c.Notify("tick", &tickEvent{N: p.A})
+		return p.A + p.B, nil
+	})
+
+	ra.RegisterStream("Count", func(c *websocket_server.Context) (interface{}, error) {
+		for i := 0; i < 5; i++ {
+			if err := c.GetStream().Send(i); err != nil {
+				return nil, err
+			}
+		}
+
+		return "done", nil
+	}, websocket_server.WithStreamWindow(1))
+
+	ra.Register("Ask", func(c *websocket_server.Context) (interface{}, error) {
+		return c.GetClient().Call(c, "Client.Name", []interface{}{"x"})
+	})
+
+	ra.Register("Slow", func(c *websocket_server.Context) (interface{}, error) {
+		<-c.Done()
+		return nil, c.Err()
+	})
+
+	ra.Register("Who", func(c *websocket_server.Context) (interface{}, error) {
+		return c.GetMeta().Get("user"), nil
+	})
+
+	return ra
+}
+
+func TestInvoke(t *testing.T) {
+
+	ra := setup()
+	ctx := context.Background()
+
+	var sum int
+	if err := InvokeInto(ctx, ra, "Math.Add", map[string]interface{}{"a": 2, "b": 3}, &sum); err != nil {
+		t.Fatal(err)
+	}
+
+	if sum != 5 {
+		t.Fatalf("unexpected sum %d", sum)
+	}
+
+	var rpcErr *websocket_server.RPCError
+
+	_, err := Invoke(ctx, ra, "Math.Add", map[string]interface{}{"b": 3})
+	if !errors.As(err, &rpcErr) || rpcErr.Code != websocket_server.ErrorCode_InvalidParams {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	_, err = Invoke(ctx, ra, "Nope", nil)
+	if !errors.As(err, &rpcErr) || rpcErr.Code != websocket_server.ErrorCode_NotFound {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestClient(t *testing.T) {
+
+	c := NewClient(setup(), WithMeta("user", "alice"), WithCallHandler(func(method string, params interface{}) (interface{}, error) {
+		return method + ":ok", nil
+	}))
+	defer c.Close()
+
+	ctx := context.Background()
+
+	res, err := c.Invoke(ctx, "Math.Add", []interface{}{1, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res != float64(2) {
+		t.Fatalf("unexpected result %v", res)
+	}
+
+	ns := c.GetNotificationsByEvent("tick")
+	if len(ns) != 1 || ns[0].(*tickEvent).N != 1 {
+		t.Fatalf("unexpected notifications %v", ns)
+	}
+
+	res, err = c.Invoke(ctx, "Ask", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res != "Client.Name:ok" {
+		t.Fatalf("unexpected result %v", res)
+	}
+
+	res, err = c.Invoke(ctx, "Who", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res != "alice" {
+		t.Fatalf("unexpected result %v", res)
+	}
+
+	c.Close()
+
+	if !c.IsClosed() {
+		t.Fatal("client was not closed")
+	}
+
+	if _, err := c.Invoke(ctx, "Who", nil); !errors.Is(err, websocket_server.ErrConnectionClosed) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestClientStream(t *testing.T) {
+
+	c := NewClient(setup())
+	defer c.Close()
+
+	chunks := make([]interface{}, 0)
+	res, err := c.InvokeStream(context.Background(), "Count", nil, func(data interface{}, seq int64) {
+		if seq != int64(len(chunks)+1) {
+			t.Errorf("unexpected seq %d", seq)
+		}
+
+		chunks = append(chunks, data)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res != "done" || len(chunks) != 5 {
+		t.Fatalf("unexpected result %v with %d chunks", res, len(chunks))
+	}
+
+	// Chunks are passed as they were sent
+	for i, data := range chunks {
+		if data != i {
+			t.Fatalf("unexpected chunk %v at %d", data, i)
+		}
+	}
+}
+
+func TestClientCancel(t *testing.T) {
+
+	c := NewClient(setup())
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := c.Invoke(ctx, "Slow", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package websocket_servertest
+
+import (
+	"context"
+	"sync/atomic"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+// ChunkHandler receives partial results of streaming method with their original values
+type ChunkHandler func(data interface{}, seq int64)
+
+type invocation struct {
+	ch      chan *websocket_server.RPCResponse
+	onChunk ChunkHandler
+}
+
+// Invoke calls method with a fresh client which is closed afterwards
+func Invoke(ctx context.Context, adapter websocket_server.Adapter, method string, params interface{}, opts ...ClientOpt) (interface{}, error) {
+
+	c := NewClient(adapter, opts...)
+	defer c.Close()
+
+	return c.Invoke(ctx, method, params)
+}
+
+// InvokeInto calls method with a fresh client then decodes result into out
+func InvokeInto(ctx context.Context, adapter websocket_server.Adapter, method string, params interface{}, out interface{}, opts ...ClientOpt) error {
+
+	c := NewClient(adapter, opts...)
+	defer c.Close()
+
+	return c.InvokeInto(ctx, method, params, out)
+}
+
+// Invoke encodes request with backend and waits for its response, so params and result go
+// through the same pipeline as they do with real connections.
+func (c *Client) Invoke(ctx context.Context, method string, params interface{}) (interface{}, error) {
+	return c.InvokeStream(ctx, method, params, nil)
+}
+
+func (c *Client) InvokeInto(ctx context.Context, method string, params interface{}, out interface{}) error {
+
+	result, err := c.Invoke(ctx, method, params)
+	if err != nil {
+		return err
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, out)
+}
+
+// InvokeStream calls streaming method, chunks are acknowledged automatically
+func (c *Client) InvokeStream(ctx context.Context, method string, params interface{}, onChunk ChunkHandler) (interface{}, error) {
+
+	if c.backend == nil {
+		return nil, ErrNotRPCAdapter
+	}
+
+	req := &websocket_server.RPCRequest{
+		ID:     websocket_server.NewIntID(atomic.AddInt64(&c.seq, 1)),
+		Method: method,
+		Params: params,
+	}
+
+	data, err := c.backend.PrepareRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	inv := &invocation{
+		ch:      make(chan *websocket_server.RPCResponse, 1),
+		onChunk: onChunk,
+	}
+
+	c.mutex.Lock()
+	c.pending[req.ID] = inv
+	c.mutex.Unlock()
+
+	defer func() {
+		c.mutex.Lock()
+		delete(c.pending, req.ID)
+		c.mutex.Unlock()
+	}()
+
+	if err := c.Receive(data); err != nil {
+		return nil, err
+	}
+
+	select {
+	case res := <-inv.ch:
+		if res.Error != nil {
+			return nil, res.Error
+		}
+
+		return res.Result, nil
+	case <-c.ctx.Done():
+		return nil, websocket_server.ErrConnectionClosed
+	case <-ctx.Done():
+		c.InvokeNotification(websocket_server.CancelRequestMethod, map[string]interface{}{
+			"id": req.ID.Value(),
+		})
+
+		return nil, ctx.Err()
+	}
+}
+
+// InvokeNotification sends notification which expects no response
+func (c *Client) InvokeNotification(method string, params interface{}) error {
+
+	if c.backend == nil {
+		return ErrNotRPCAdapter
+	}
+
+	data, err := c.backend.PrepareNotification(method, params)
+	if err != nil {
+		return err
+	}
+
+	return c.Receive(data)
+}
+
+func (c *Client) resolve(res *websocket_server.RPCResponse) {
+
+	c.mutex.Lock()
+	inv, ok := c.pending[res.ID]
+	c.mutex.Unlock()
+
+	if !ok {
+		return
+	}
+
+	select {
+	case inv.ch <- res:
+	default:
+	}
+}
+
+func (c *Client) receiveChunk(chunk *websocket_server.StreamChunk) {
+
+	c.mutex.Lock()
+	inv, ok := c.pending[chunk.ID]
+	c.mutex.Unlock()
+
+	if !ok {
+		return
+	}
+
+	if inv.onChunk != nil {
+		inv.onChunk(chunk.Data, chunk.Seq)
+	}
+
+	// Stream is locked while the chunk is being sent
+	go c.InvokeNotification(websocket_server.StreamAckMethod, map[string]interface{}{
+		"id":  chunk.ID.Value(),
+		"seq": chunk.Seq,
+	})
+}
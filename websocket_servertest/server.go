@@ -0,0 +1,161 @@
+package websocket_servertest
+
+import (
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/weedbox/websocket-modules/websocket_client"
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+var (
+	ErrServerClosed = errors.New("servertest: server closed")
+	ErrTooManyConns = errors.New("servertest: too many connections")
+)
+
+// Server runs real clients over in-memory connections, it behaves like an endpoint except
+// that HTTP upgrade is skipped and each connection is served by its own goroutine.
+type Server struct {
+	options *websocket_server.Options
+	mutex   sync.Mutex
+	clients map[websocket_server.Client]struct{}
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+func NewServer(options *websocket_server.Options) *Server {
+
+	if options == nil {
+		options = websocket_server.NewOptions()
+	}
+
+	return &Server{
+		options: options,
+		clients: make(map[websocket_server.Client]struct{}),
+	}
+}
+
+// NewRPCServer creates server with RPC adapter which is able to be obtained by GetRPCAdapter
+func NewRPCServer(opts ...websocket_server.RPCAdapterOpt) *Server {
+
+	options := websocket_server.NewOptions()
+	options.Adapter = websocket_server.NewRPCAdapter(opts...)
+
+	return NewServer(options)
+}
+
+func (s *Server) GetOptions() *websocket_server.Options {
+	return s.options
+}
+
+func (s *Server) GetAdapter() websocket_server.Adapter {
+	return s.options.Adapter
+}
+
+// GetRPCAdapter returns nil if adapter is not RPC adapter
+func (s *Server) GetRPCAdapter() *websocket_server.RPCAdapter {
+	ra, _ := s.options.Adapter.(*websocket_server.RPCAdapter)
+	return ra
+}
+
+// Connect establishes connection whose handshake is already done, the returned end speaks
+// websocket frames from client side.
+func (s *Server) Connect() (net.Conn, error) {
+
+	s.mutex.Lock()
+
+	if s.closed {
+		s.mutex.Unlock()
+		return nil, ErrServerClosed
+	}
+
+	if len(s.clients) >= s.options.MaxClients {
+		s.mutex.Unlock()
+		return nil, ErrTooManyConns
+	}
+
+	conn, peer := net.Pipe()
+	c := websocket_server.NewClient(s.options, conn)
+	s.clients[c] = struct{}{}
+	s.wg.Add(1)
+
+	s.mutex.Unlock()
+
+	if a, ok := s.options.Adapter.(websocket_server.Attacher); ok {
+		a.Attach(c)
+	}
+
+	go s.serve(c)
+
+	// Emit event
+	s.options.OnConnected(c)
+
+	return peer, nil
+}
+
+// Dial connects with JSON-RPC client
+func (s *Server) Dial(opts ...websocket_client.ClientOpt) (*websocket_client.Client, error) {
+
+	conn, err := s.Connect()
+	if err != nil {
+		return nil, err
+	}
+
+	return websocket_client.New(conn, opts...), nil
+}
+
+// GetClients returns server side clients which are connected
+func (s *Server) GetClients() []websocket_server.Client {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	clients := make([]websocket_server.Client, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+
+	return clients
+}
+
+// Close disconnects all clients and waits for them to be cleaned up
+func (s *Server) Close() {
+
+	s.mutex.Lock()
+
+	s.closed = true
+
+	clients := make([]websocket_server.Client, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+
+	s.mutex.Unlock()
+
+	for _, c := range clients {
+		c.Close()
+	}
+
+	s.wg.Wait()
+}
+
+func (s *Server) serve(c websocket_server.Client) {
+
+	defer s.wg.Done()
+
+	for {
+		if err := c.Resume(); err != nil {
+			break
+		}
+	}
+
+	c.Close()
+
+	s.mutex.Lock()
+	delete(s.clients, c)
+	s.mutex.Unlock()
+
+	// Emit event
+	s.options.OnDisconnected(c)
+}
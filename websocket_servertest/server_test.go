@@ -0,0 +1,137 @@
+package websocket_servertest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/weedbox/websocket-modules/websocket_server"
+)
+
+func TestServer(t *testing.T) {
+
+	s := NewServer(nil)
+	s.GetOptions().Adapter = setup()
+
+	connected := make(chan websocket_server.Client, 1)
+	disconnected := make(chan websocket_server.Client, 1)
+	s.GetOptions().OnConnected = func(c websocket_server.Client) error {
+		connected <- c
+		return nil
+	}
+	s.GetOptions().OnDisconnected = func(c websocket_server.Client) error {
+		disconnected <- c
+		return nil
+	}
+
+	c, err := s.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-connected
+
+	ticks := make(chan int, 1)
+	c.On("tick", func(params jsoniter.RawMessage) {
+		var e tickEvent
+		json.Unmarshal(params, &e)
+		ticks <- e.N
+	})
+	c.Handle("Client.Name", func(ctx context.Context, params jsoniter.RawMessage) (interface{}, error) {
+		return "real", nil
+	})
+
+	ctx := context.Background()
+
+	var sum int
+	if err := c.Call(ctx, "Math.Add", []int{4, 5}, &sum); err != nil {
+		t.Fatal(err)
+	}
+
+	if sum != 9 {
+		t.Fatalf("unexpected sum %d", sum)
+	}
+
+	if n := <-ticks; n != 4 {
+		t.Fatalf("unexpected tick %d", n)
+	}
+
+	var name string
+	if err := c.Call(ctx, "Ask", nil, &name); err != nil {
+		t.Fatal(err)
+	}
+
+	if name != "real" {
+		t.Fatalf("unexpected name %s", name)
+	}
+
+	chunks := 0
+	var done string
+	err = c.CallStream(ctx, "Count", nil, &done, func(data jsoniter.RawMessage, seq int64) error {
+		chunks++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if chunks != 5 || done != "done" {
+		t.Fatalf("unexpected result %s with %d chunks", done, chunks)
+	}
+
+	if len(s.GetClients()) != 1 {
+		t.Fatalf("unexpected clients %d", len(s.GetClients()))
+	}
+
+	c.Close()
+
+	select {
+	case <-disconnected:
+	case <-time.After(time.Second):
+		t.Fatal("OnDisconnected was not emitted")
+	}
+
+	if len(s.GetClients()) != 0 {
+		t.Fatal("client was not removed")
+	}
+}
+
+func TestServerClose(t *testing.T) {
+
+	s := NewRPCServer()
+
+	c, err := s.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Close()
+
+	select {
+	case <-c.Done():
+	case <-time.After(time.Second):
+		t.Fatal("client was not closed")
+	}
+
+	if _, err := s.Dial(); !errors.Is(err, ErrServerClosed) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestServerMaxClients(t *testing.T) {
+
+	s := NewRPCServer()
+	defer s.Close()
+
+	s.GetOptions().MaxClients = 1
+
+	if _, err := s.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Connect(); !errors.Is(err, ErrTooManyConns) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}